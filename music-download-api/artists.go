@@ -0,0 +1,587 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Grupos de lançamento aceitos pelo endpoint de álbuns de artista do Spotify.
+const (
+	albumGroupAlbum       = "album"
+	albumGroupSingle      = "single"
+	albumGroupCompilation = "compilation"
+	albumGroupAppearsOn   = "appears_on"
+)
+
+const followsFile = "follows.json"
+
+// defaultAlbumGroups são os tipos seguidos quando o pedido não informa nenhum.
+var defaultAlbumGroups = []string{albumGroupAlbum, albumGroupSingle}
+
+// spotifyArtistAlbum é um item de /v1/artists/{id}/albums; AlbumGroup indica a relação
+// do artista com o lançamento (appears_on quando ele só participa).
+type spotifyArtistAlbum struct {
+	spotifyAlbumRef
+	AlbumGroup string `json:"album_group"`
+}
+
+// getSpotifyArtistAlbums percorre todas as páginas de lançamentos do artista nos grupos pedidos.
+func getSpotifyArtistAlbums(artistID string, groups []string) ([]spotifyArtistAlbum, error) {
+	var albums []spotifyArtistAlbum
+	next := fmt.Sprintf(
+		"https://api.spotify.com/v1/artists/%s/albums?include_groups=%s&limit=50",
+		artistID, url.QueryEscape(strings.Join(groups, ",")),
+	)
+	for next != "" {
+		var page struct {
+			Items []spotifyArtistAlbum `json:"items"`
+			Next  string               `json:"next"`
+		}
+		if err := spotifyGet(next, &page); err != nil {
+			return nil, err
+		}
+		albums = append(albums, page.Items...)
+		next = page.Next
+	}
+	return albums, nil
+}
+
+// parseAlbumGroups valida os tipos de lançamento pedidos.
+func parseAlbumGroups(groups []string) ([]string, error) {
+	if len(groups) == 0 {
+		return defaultAlbumGroups, nil
+	}
+	var parsed []string
+	seen := make(map[string]bool)
+	for _, group := range groups {
+		group = strings.ToLower(strings.TrimSpace(group))
+		switch group {
+		case albumGroupAlbum, albumGroupSingle, albumGroupCompilation, albumGroupAppearsOn:
+		default:
+			return nil, fmt.Errorf("invalid album type %q (use album, single, compilation or appears_on)", group)
+		}
+		if !seen[group] {
+			seen[group] = true
+			parsed = append(parsed, group)
+		}
+	}
+	return parsed, nil
+}
+
+// discographyAlbumGroups são os tipos listados em /process-urls para escolha do usuário.
+var discographyAlbumGroups = []string{albumGroupAlbum, albumGroupSingle, albumGroupCompilation}
+
+// editionPattern remove marcações de edição ("(Deluxe Edition)", "- Expanded", "[Remastered]")
+// para agrupar as versões de um mesmo lançamento. "Version" sozinho não conta: regravações
+// como "1989 (Taylor's Version)" são lançamentos distintos, enquanto "Deluxe Version" ou
+// "Remastered Version" já casam pelo qualificador.
+var editionPattern = regexp.MustCompile(
+	`(?i)\s*(-\s*[^-]*\b(deluxe|edition|expanded|anniversary|remaster(ed)?|bonus|special)\b[^-]*$|` +
+		`[\(\[][^\)\]]*\b(deluxe|edition|expanded|anniversary|remaster(ed)?|bonus|special)\b[^\)\]]*[\)\]])`,
+)
+
+// ArtistRelease é um lançamento da discografia com as edições equivalentes agrupadas.
+// ID é o álbum escolhido para download; Editions lista os demais IDs do grupo.
+type ArtistRelease struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	AlbumGroup  string   `json:"album_group"`
+	ReleaseDate string   `json:"release_date,omitempty"`
+	TotalTracks int      `json:"total_tracks"`
+	Thumbnail   string   `json:"thumbnail,omitempty"`
+	URL         string   `json:"url"`
+	Editions    []string `json:"editions,omitempty"`
+}
+
+// getArtistDiscography lista os lançamentos do artista, deduplicando edições regionais e
+// deluxe: de cada grupo (mesmo tipo e nome sem a marcação de edição) fica a edição com
+// mais faixas, ou a mais antiga em caso de empate. O resultado vem do mais recente ao
+// mais antigo.
+func getArtistDiscography(artistID string, groups []string) ([]ArtistRelease, error) {
+	albums, err := getSpotifyArtistAlbums(artistID, groups)
+	if err != nil {
+		return nil, err
+	}
+	return groupArtistReleases(albums), nil
+}
+
+// groupArtistReleases agrupa as edições de cada lançamento como em getArtistDiscography.
+func groupArtistReleases(albums []spotifyArtistAlbum) []ArtistRelease {
+	byKey := make(map[string]*ArtistRelease)
+	var order []string
+	for _, album := range albums {
+		key := album.AlbumGroup + "|" + normalizeText(editionPattern.ReplaceAllString(album.Name, ""))
+		release, ok := byKey[key]
+		if !ok {
+			release = &ArtistRelease{}
+			byKey[key] = release
+			order = append(order, key)
+		} else {
+			better := album.TotalTracks > release.TotalTracks ||
+				(album.TotalTracks == release.TotalTracks && album.ReleaseDate < release.ReleaseDate)
+			if !better {
+				release.Editions = append(release.Editions, album.ID)
+				continue
+			}
+			release.Editions = append(release.Editions, release.ID)
+		}
+
+		release.ID = album.ID
+		release.Name = album.Name
+		release.AlbumGroup = album.AlbumGroup
+		release.ReleaseDate = album.ReleaseDate
+		release.TotalTracks = album.TotalTracks
+		release.URL = fmt.Sprintf("https://open.spotify.com/album/%s", album.ID)
+		release.Thumbnail = ""
+		if len(album.Images) > 0 {
+			release.Thumbnail = album.Images[0].URL
+		}
+	}
+
+	releases := make([]ArtistRelease, 0, len(order))
+	for _, key := range order {
+		releases = append(releases, *byKey[key])
+	}
+	sort.SliceStable(releases, func(i, j int) bool {
+		return releases[i].ReleaseDate > releases[j].ReleaseDate
+	})
+	return releases
+}
+
+// isSpotifyArtistURL indica se a URL é de um artista do Spotify.
+func isSpotifyArtistURL(urlStr string) bool {
+	if !isSpotifyURL(urlStr) {
+		return false
+	}
+	itemType, _, err := extractSpotifyID(urlStr)
+	return err == nil && itemType == "artist"
+}
+
+// runArtistDownload baixa a discografia do artista do job, um sub-job por álbum. selected
+// restringe os lançamentos aos IDs escolhidos em /process-urls (ID do grupo ou de qualquer
+// edição); vazio baixa todos os álbuns e singles.
+func runArtistDownload(job *DownloadJob, selected []string, opts downloadOptions, emit func(string)) error {
+	updateJob(job, func(j *DownloadJob) { j.Status = jobRunning })
+
+	_, artistID, err := extractSpotifyID(job.URL)
+	if err != nil {
+		return err
+	}
+
+	groups := defaultAlbumGroups
+	if len(selected) > 0 {
+		groups = []string{albumGroupAlbum, albumGroupSingle, albumGroupCompilation, albumGroupAppearsOn}
+	}
+	releases, err := getArtistDiscography(artistID, groups)
+	if err != nil {
+		return fmt.Errorf("failed to list artist releases: %w", err)
+	}
+
+	if len(selected) > 0 {
+		wanted := make(map[string]bool, len(selected))
+		for _, id := range selected {
+			wanted[id] = true
+		}
+		var filtered []ArtistRelease
+		for _, release := range releases {
+			match := wanted[release.ID]
+			for _, edition := range release.Editions {
+				match = match || wanted[edition]
+			}
+			if match {
+				filtered = append(filtered, release)
+			}
+		}
+		releases = filtered
+	}
+	if len(releases) == 0 {
+		return fmt.Errorf("no releases to download")
+	}
+
+	emit(fmt.Sprintf("Found %d releases", len(releases)))
+	failed := 0
+	for i, release := range releases {
+		emit(fmt.Sprintf("Downloading %s %q (%d/%d)", release.AlbumGroup, release.Name, i+1, len(releases)))
+
+		child := runJob(release.URL, opts)
+		childSnapshot := snapshotJob(child)
+		updateJob(job, func(j *DownloadJob) { j.SubJobs = append(j.SubJobs, child.ID) })
+		for _, track := range childSnapshot.Tracks {
+			addJobTrack(job, track)
+		}
+
+		if childSnapshot.Error != "" {
+			failed++
+			emit(fmt.Sprintf("Job %s failed for %q: %s", child.ID, release.Name, childSnapshot.Error))
+		} else {
+			emit(fmt.Sprintf("Job %s completed %q with %d files", child.ID, release.Name, len(childSnapshot.Files)))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d releases failed", failed, len(releases))
+	}
+	return nil
+}
+
+// FollowedRelease é um lançamento detectado depois que o artista passou a ser seguido.
+type FollowedRelease struct {
+	AlbumID     string    `json:"album_id"`
+	Name        string    `json:"name"`
+	AlbumGroup  string    `json:"album_group"`
+	ReleaseDate string    `json:"release_date,omitempty"`
+	URL         string    `json:"url"`
+	JobID       string    `json:"job_id,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DetectedAt  time.Time `json:"detected_at"`
+}
+
+// FollowedArtist é um artista do Spotify verificado periodicamente: os lançamentos novos
+// dos tipos em AlbumGroups são baixados como álbuns.
+type FollowedArtist struct {
+	ID           string            `json:"id"`
+	ArtistID     string            `json:"artist_id"`
+	URL          string            `json:"url"`
+	Name         string            `json:"name"`
+	Schedule     string            `json:"schedule"`
+	AlbumGroups  []string          `json:"album_groups"`
+	PathTemplate string            `json:"path_template,omitempty"`
+	OnConflict   string            `json:"on_conflict,omitempty"`
+	Known        []string          `json:"known,omitempty"`
+	Releases     []FollowedRelease `json:"releases,omitempty"`
+	Checking     bool              `json:"checking"`
+	LastCheckAt  *time.Time        `json:"last_check_at,omitempty"`
+	NextCheckAt  time.Time         `json:"next_check_at"`
+	LastError    string            `json:"last_error,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+}
+
+// FollowRequest é o corpo aceito por POST /follows.
+type FollowRequest struct {
+	URL          string   `json:"url"`
+	Schedule     string   `json:"schedule"`
+	AlbumTypes   []string `json:"album_types,omitempty"`
+	PathTemplate string   `json:"path_template,omitempty"`
+	OnConflict   string   `json:"on_conflict,omitempty"`
+}
+
+var (
+	follows   = make(map[string]*FollowedArtist)
+	followsMu sync.Mutex
+)
+
+// loadFollows carrega os artistas seguidos salvos em DATA_DIR.
+func loadFollows() {
+	followsMu.Lock()
+	defer followsMu.Unlock()
+	if err := loadJSONFile(followsFile, &follows); err != nil {
+		log.WithError(err).Error("Failed to load followed artists")
+	}
+	for _, follow := range follows {
+		follow.Checking = false
+	}
+}
+
+// saveFollows persiste os artistas seguidos; deve ser chamada com followsMu travado.
+func saveFollows() {
+	if err := saveJSONFile(followsFile, follows); err != nil {
+		log.WithError(err).Error("Failed to save followed artists")
+	}
+}
+
+// runDueFollows inicia a verificação dos artistas cujo horário chegou.
+func runDueFollows(now time.Time) {
+	followsMu.Lock()
+	var due []*FollowedArtist
+	for _, follow := range follows {
+		if !follow.Checking && !now.Before(follow.NextCheckAt) {
+			follow.Checking = true
+			due = append(due, follow)
+		}
+	}
+	followsMu.Unlock()
+
+	for _, follow := range due {
+		go checkFollowedArtist(follow)
+	}
+}
+
+// checkFollowedArtist lista os lançamentos do artista e baixa, um álbum por job, os que
+// ainda não eram conhecidos. O chamador deve ter marcado follow.Checking.
+func checkFollowedArtist(follow *FollowedArtist) {
+	followsMu.Lock()
+	artistID := follow.ArtistID
+	groups := follow.AlbumGroups
+	known := make(map[string]bool, len(follow.Known))
+	for _, id := range follow.Known {
+		known[id] = true
+	}
+	tpl, conflict, err := resolvePathOptions(follow.PathTemplate, follow.OnConflict)
+	followsMu.Unlock()
+
+	var albums []spotifyArtistAlbum
+	if err == nil {
+		albums, err = getSpotifyArtistAlbums(artistID, groups)
+	}
+	if err != nil {
+		log.WithError(err).Errorf("Failed to check followed artist %s", follow.ID)
+		finishFollowCheck(follow, nil, err)
+		return
+	}
+
+	opts := downloadOptions{PathTemplate: tpl, OnConflict: conflict}
+	for _, album := range newFollowReleases(albums, known) {
+		release := FollowedRelease{
+			AlbumID:     album.ID,
+			Name:        album.Name,
+			AlbumGroup:  album.AlbumGroup,
+			ReleaseDate: album.ReleaseDate,
+			URL:         album.URL,
+			DetectedAt:  time.Now(),
+		}
+		log.Infof("New release from followed artist %s: %s", follow.ID, album.Name)
+
+		job := runJob(release.URL, opts)
+		release.JobID = job.ID
+		if snapshot := snapshotJob(job); snapshot.Error != "" {
+			release.Error = snapshot.Error
+		}
+		// Lançamentos que falharam continuam desconhecidos e são tentados na próxima verificação
+		if release.Error == "" {
+			known[album.ID] = true
+			for _, edition := range album.Editions {
+				known[edition] = true
+			}
+		}
+		recordFollowRelease(follow, release)
+	}
+
+	finishFollowCheck(follow, known, nil)
+}
+
+// newFollowReleases agrupa as edições dos álbuns listados e devolve os lançamentos ainda
+// não conhecidos. Uma nova edição (regional, deluxe) de um lançamento já conhecido não é
+// baixada de novo: seus IDs apenas passam a constar em known.
+func newFollowReleases(albums []spotifyArtistAlbum, known map[string]bool) []ArtistRelease {
+	var releases []ArtistRelease
+	for _, release := range groupArtistReleases(albums) {
+		ids := append([]string{release.ID}, release.Editions...)
+		seen := false
+		for _, id := range ids {
+			seen = seen || known[id]
+		}
+		if !seen {
+			releases = append(releases, release)
+			continue
+		}
+		for _, id := range ids {
+			known[id] = true
+		}
+	}
+	return releases
+}
+
+// recordFollowRelease registra um lançamento assim que o job termina, para que a lista
+// fique atualizada mesmo durante verificações longas. Uma nova tentativa substitui o
+// registro anterior do mesmo álbum.
+func recordFollowRelease(follow *FollowedArtist, release FollowedRelease) {
+	followsMu.Lock()
+	defer followsMu.Unlock()
+	if release.Error == "" && !slices.Contains(follow.Known, release.AlbumID) {
+		follow.Known = append(follow.Known, release.AlbumID)
+	}
+	for i := range follow.Releases {
+		if follow.Releases[i].AlbumID == release.AlbumID {
+			follow.Releases[i] = release
+			saveFollows()
+			return
+		}
+	}
+	follow.Releases = append(follow.Releases, release)
+	saveFollows()
+}
+
+// finishFollowCheck grava o resultado da verificação e agenda a próxima.
+func finishFollowCheck(follow *FollowedArtist, known map[string]bool, err error) {
+	followsMu.Lock()
+	defer followsMu.Unlock()
+
+	now := time.Now()
+	follow.Checking = false
+	follow.LastCheckAt = &now
+	follow.LastError = ""
+	if err != nil {
+		follow.LastError = err.Error()
+	} else {
+		follow.Known = make([]string, 0, len(known))
+		for id := range known {
+			follow.Known = append(follow.Known, id)
+		}
+		sort.Strings(follow.Known)
+	}
+	if schedule, err := parseSchedule(follow.Schedule); err == nil {
+		follow.NextCheckAt = schedule.Next(now)
+	}
+	saveFollows()
+}
+
+// createFollow passa a seguir um artista do Spotify. Os lançamentos já existentes são
+// registrados como conhecidos; apenas os que surgirem depois serão baixados.
+func createFollow(c *gin.Context) {
+	var request FollowRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.WithError(err).Error("Invalid request body")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	itemType, artistID, err := extractSpotifyID(request.URL)
+	if err != nil || itemType != "artist" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A Spotify artist URL is required"})
+		return
+	}
+	if request.Schedule == "" {
+		request.Schedule = "@daily"
+	}
+	schedule, err := parseSchedule(request.Schedule)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	groups, err := parseAlbumGroups(request.AlbumTypes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, _, err := resolvePathOptions(request.PathTemplate, request.OnConflict); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var artist struct {
+		Name string `json:"name"`
+	}
+	if err := spotifyGet(fmt.Sprintf("https://api.spotify.com/v1/artists/%s", artistID), &artist); err != nil {
+		log.WithError(err).Error("Failed to get artist")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to get artist"})
+		return
+	}
+	albums, err := getSpotifyArtistAlbums(artistID, groups)
+	if err != nil {
+		log.WithError(err).Error("Failed to list artist albums")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to list artist albums"})
+		return
+	}
+
+	follow := &FollowedArtist{
+		ID:           newJobID(),
+		ArtistID:     artistID,
+		URL:          request.URL,
+		Name:         artist.Name,
+		Schedule:     request.Schedule,
+		AlbumGroups:  groups,
+		PathTemplate: request.PathTemplate,
+		OnConflict:   request.OnConflict,
+		NextCheckAt:  schedule.Next(time.Now()),
+		CreatedAt:    time.Now(),
+	}
+	for _, album := range albums {
+		follow.Known = append(follow.Known, album.ID)
+	}
+
+	followsMu.Lock()
+	follows[follow.ID] = follow
+	saveFollows()
+	copied := copyFollow(follow)
+	followsMu.Unlock()
+
+	c.JSON(http.StatusCreated, copied)
+}
+
+// copyFollow copia o artista seguido e suas listas para serializar fora do mutex; deve ser
+// chamada com followsMu travado.
+func copyFollow(follow *FollowedArtist) FollowedArtist {
+	copied := *follow
+	copied.AlbumGroups = append([]string(nil), follow.AlbumGroups...)
+	copied.Known = append([]string(nil), follow.Known...)
+	copied.Releases = append([]FollowedRelease(nil), follow.Releases...)
+	return copied
+}
+
+// listFollows retorna todos os artistas seguidos.
+func listFollows(c *gin.Context) {
+	followsMu.Lock()
+	list := make([]FollowedArtist, 0, len(follows))
+	for _, follow := range follows {
+		list = append(list, copyFollow(follow))
+	}
+	followsMu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	c.JSON(http.StatusOK, gin.H{"follows": list})
+}
+
+// getFollow retorna um artista seguido pelo ID.
+func getFollow(c *gin.Context) {
+	followsMu.Lock()
+	follow, ok := follows[c.Param("id")]
+	var copied FollowedArtist
+	if ok {
+		copied = copyFollow(follow)
+	}
+	followsMu.Unlock()
+
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Followed artist not found"})
+		return
+	}
+	c.JSON(http.StatusOK, copied)
+}
+
+// deleteFollow deixa de seguir o artista (os álbuns já baixados são mantidos).
+func deleteFollow(c *gin.Context) {
+	followsMu.Lock()
+	defer followsMu.Unlock()
+
+	if _, ok := follows[c.Param("id")]; !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Followed artist not found"})
+		return
+	}
+	delete(follows, c.Param("id"))
+	saveFollows()
+	c.Status(http.StatusNoContent)
+}
+
+// checkFollowNow dispara uma verificação imediata em segundo plano.
+func checkFollowNow(c *gin.Context) {
+	followsMu.Lock()
+	follow, ok := follows[c.Param("id")]
+	if ok && follow.Checking {
+		followsMu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "Artist is already being checked"})
+		return
+	}
+	if ok {
+		follow.Checking = true
+	}
+	followsMu.Unlock()
+
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Followed artist not found"})
+		return
+	}
+
+	go checkFollowedArtist(follow)
+	c.JSON(http.StatusAccepted, gin.H{"status": "checking"})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEditionPattern(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Album (Deluxe Edition)", "Album"},
+		{"Album [Remastered]", "Album"},
+		{"Album - Expanded", "Album"},
+		{"Album (Deluxe Version)", "Album"},
+		{"Album (2011 Remastered Version)", "Album"},
+		{"Album (10th Anniversary Edition)", "Album"},
+		{"1989 (Taylor's Version)", "1989 (Taylor's Version)"},
+		{"Red (Taylor's Version)", "Red (Taylor's Version)"},
+		{"1989 (Taylor's Version) [Deluxe]", "1989 (Taylor's Version)"},
+		{"Live Version", "Live Version"},
+	}
+	for _, tt := range tests {
+		if got := editionPattern.ReplaceAllString(tt.name, ""); got != tt.want {
+			t.Errorf("editionPattern removes %q to %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func testArtistAlbum(id, name, group, date string, tracks int) spotifyArtistAlbum {
+	return spotifyArtistAlbum{
+		spotifyAlbumRef: spotifyAlbumRef{ID: id, Name: name, ReleaseDate: date, TotalTracks: tracks},
+		AlbumGroup:      group,
+	}
+}
+
+func TestNewFollowReleases(t *testing.T) {
+	albums := []spotifyArtistAlbum{
+		testArtistAlbum("old", "Album", albumGroupAlbum, "2020-01-01", 10),
+		testArtistAlbum("old-deluxe", "Album (Deluxe Edition)", albumGroupAlbum, "2021-01-01", 14),
+		testArtistAlbum("new-br", "Second", albumGroupAlbum, "2024-05-01", 12),
+		testArtistAlbum("new-us", "Second", albumGroupAlbum, "2024-05-01", 12),
+		testArtistAlbum("single", "Second", albumGroupSingle, "2024-04-01", 1),
+	}
+	known := map[string]bool{"old": true}
+
+	releases := newFollowReleases(albums, known)
+	var ids []string
+	for _, release := range releases {
+		ids = append(ids, release.ID)
+	}
+	// A edição deluxe de um álbum conhecido não é baixada; as duas edições regionais do
+	// lançamento novo viram um único download
+	if want := []string{"new-br", "single"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("newFollowReleases = %v, want %v", ids, want)
+	}
+	if !known["old-deluxe"] {
+		t.Error("new edition of a known album was not marked as known")
+	}
+	if known["new-br"] || known["new-us"] {
+		t.Error("releases not downloaded yet were marked as known")
+	}
+	if !reflect.DeepEqual(releases[0].Editions, []string{"new-us"}) {
+		t.Errorf("editions = %v, want the other regional ID", releases[0].Editions)
+	}
+}
+
+func TestRecordFollowReleaseRetry(t *testing.T) {
+	useTestDataDir(t)
+	follow := &FollowedArtist{ID: "follow"}
+	recordFollowRelease(follow, FollowedRelease{AlbumID: "a1", Error: "download failed"})
+	recordFollowRelease(follow, FollowedRelease{AlbumID: "a1"})
+	recordFollowRelease(follow, FollowedRelease{AlbumID: "a1"})
+
+	if !reflect.DeepEqual(follow.Known, []string{"a1"}) {
+		t.Errorf("Known = %v, want a1 once", follow.Known)
+	}
+	if len(follow.Releases) != 1 || follow.Releases[0].Error != "" {
+		t.Errorf("Releases = %+v, want the retry to replace the failed release", follow.Releases)
+	}
+}
+
+func TestCopyFollowDetachesLists(t *testing.T) {
+	follow := &FollowedArtist{
+		Known:    []string{"a1"},
+		Releases: []FollowedRelease{{AlbumID: "a1", Error: "failed"}},
+	}
+	copied := copyFollow(follow)
+	follow.Known[0] = "changed"
+	follow.Releases[0] = FollowedRelease{AlbumID: "a1"}
+
+	if copied.Known[0] != "a1" || copied.Releases[0].Error != "failed" {
+		t.Errorf("copyFollow shares lists with the follow: %+v", copied)
+	}
+}
+
+func TestParseAlbumGroups(t *testing.T) {
+	tests := []struct {
+		name    string
+		groups  []string
+		want    []string
+		wantErr bool
+	}{
+		{"empty uses the defaults", nil, defaultAlbumGroups, false},
+		{"trims, lowercases and dedupes", []string{" Album", "single", "ALBUM"}, []string{"album", "single"}, false},
+		{"all groups", []string{"album", "single", "compilation", "appears_on"}, []string{"album", "single", "compilation", "appears_on"}, false},
+		{"unknown group", []string{"album", "live"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAlbumGroups(tt.groups)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAlbumGroups(%v) error = %v, want error %v", tt.groups, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAlbumGroups(%v) = %v, want %v", tt.groups, got, tt.want)
+			}
+		})
+	}
+}
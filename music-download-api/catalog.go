@@ -0,0 +1,595 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const libraryIndexFile = "library-index.json"
+
+// libraryRefreshInterval limita a frequência com que a biblioteca é percorrida; downloads
+// concluídos invalidam o intervalo para que os arquivos novos apareçam na hora.
+var libraryRefreshInterval = time.Duration(getEnvFloat("LIBRARY_REFRESH_SECONDS", 30)) * time.Second
+
+// LibraryTrack é um arquivo de áudio da biblioteca com os dados técnicos e as tags lidas
+// pelo ffprobe. Path é relativo à raiz da biblioteca; ID é derivado dele.
+type LibraryTrack struct {
+	ID          string            `json:"id"`
+	Path        string            `json:"path"`
+	Size        int64             `json:"size"`
+	ModTime     time.Time         `json:"mod_time"`
+	Format      string            `json:"format"`
+	Codec       string            `json:"codec,omitempty"`
+	BitRate     int               `json:"bit_rate"`
+	SampleRate  int               `json:"sample_rate,omitempty"`
+	DurationSec float64           `json:"duration_sec"`
+	Title       string            `json:"title"`
+	Artist      string            `json:"artist,omitempty"`
+	AlbumArtist string            `json:"album_artist,omitempty"`
+	Album       string            `json:"album,omitempty"`
+	TrackNumber int               `json:"track_number,omitempty"`
+	DiscNumber  int               `json:"disc_number,omitempty"`
+	Year        string            `json:"year,omitempty"`
+	Genre       string            `json:"genre,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+// LibraryAlbum agrupa as faixas pelo artista do álbum e nome do álbum.
+type LibraryAlbum struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Artist      string  `json:"artist"`
+	Year        string  `json:"year,omitempty"`
+	Genre       string  `json:"genre,omitempty"`
+	Directory   string  `json:"directory"`
+	TrackCount  int     `json:"track_count"`
+	DurationSec float64 `json:"duration_sec"`
+	Size        int64   `json:"size"`
+}
+
+// LibraryArtist agrupa os álbuns e faixas pelo artista do álbum.
+type LibraryArtist struct {
+	Name        string  `json:"name"`
+	AlbumCount  int     `json:"album_count"`
+	TrackCount  int     `json:"track_count"`
+	DurationSec float64 `json:"duration_sec"`
+}
+
+// libraryIndex guarda as faixas chaveadas pelo caminho relativo. A cada atualização só
+// os arquivos com tamanho ou data de modificação diferentes são lidos de novo. O mapa
+// nunca é alterado depois de publicado: cada atualização monta um novo e o troca sob
+// libraryIndexMu, enquanto libraryRefreshMu garante uma única varredura por vez.
+var (
+	libraryIndex         = make(map[string]*LibraryTrack)
+	libraryIndexMu       sync.Mutex
+	libraryRefreshMu     sync.Mutex
+	libraryIndexLoaded   bool
+	libraryLastRefreshAt time.Time
+	// libraryRefreshing evita empilhar atualizações em segundo plano (ver cachedLibraryTracks)
+	libraryRefreshing atomic.Bool
+)
+
+// libraryID gera um identificador estável a partir de um caminho ou chave.
+func libraryID(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// invalidateLibraryIndex força que a próxima consulta percorra a biblioteca.
+func invalidateLibraryIndex() {
+	libraryIndexMu.Lock()
+	libraryLastRefreshAt = time.Time{}
+	libraryIndexMu.Unlock()
+}
+
+// ensureLibraryIndex carrega o índice salvo em DATA_DIR na primeira utilização.
+// Deve ser chamada com libraryIndexMu travado.
+func ensureLibraryIndex() {
+	if libraryIndexLoaded {
+		return
+	}
+	libraryIndexLoaded = true
+	if err := loadJSONFile(libraryIndexFile, &libraryIndex); err != nil {
+		log.WithError(err).Error("Failed to load library index")
+	}
+}
+
+// refreshLibraryIndex sincroniza o índice com o disco: lê arquivos novos ou alterados e
+// descarta os que sumiram. Sem force, respeita LIBRARY_REFRESH_SECONDS. A varredura e o
+// ffprobe rodam fora de libraryIndexMu, que só é travado para trocar o resultado.
+func refreshLibraryIndex(force bool) {
+	libraryRefreshMu.Lock()
+	defer libraryRefreshMu.Unlock()
+
+	libraryIndexMu.Lock()
+	ensureLibraryIndex()
+	if !force && time.Since(libraryLastRefreshAt) < libraryRefreshInterval {
+		libraryIndexMu.Unlock()
+		return
+	}
+	// Uma invalidação durante a varredura zera este horário e força a próxima
+	libraryLastRefreshAt = time.Now()
+	previous := libraryIndex
+	libraryIndexMu.Unlock()
+
+	changed := false
+	next := make(map[string]*LibraryTrack, len(previous))
+	filepath.WalkDir(libraryDir, func(p string, d fs.DirEntry, err error) error {
+		if skip := skipHiddenDir(libraryDir, p, d); skip != nil {
+			return skip
+		}
+		// Arquivos ocultos incluem os temporários de tagging e normalização
+		if err != nil || d.IsDir() || !isAudioFile(p) || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(libraryDir, p)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if track, ok := previous[rel]; ok && track.Size == info.Size() && track.ModTime.Equal(info.ModTime()) {
+			next[rel] = track
+			return nil
+		}
+		track, err := readLibraryTrack(rel, info)
+		if err != nil {
+			log.WithError(err).Warnf("Failed to read %s", rel)
+			return nil
+		}
+		next[rel] = track
+		changed = true
+		return nil
+	})
+
+	// Sem leituras novas, next só perde entradas de previous se algum arquivo sumiu
+	if !changed && len(next) == len(previous) {
+		return
+	}
+
+	libraryIndexMu.Lock()
+	libraryIndex = next
+	libraryIndexMu.Unlock()
+
+	if err := saveJSONFile(libraryIndexFile, next); err != nil {
+		log.WithError(err).Error("Failed to save library index")
+	}
+}
+
+// readLibraryTrack monta a entrada do índice para um arquivo a partir do ffprobe.
+func readLibraryTrack(rel string, info fs.FileInfo) (*LibraryTrack, error) {
+	probe, err := probeAudio(absLibraryPath(rel))
+	if err != nil {
+		return nil, err
+	}
+
+	track := &LibraryTrack{
+		ID:          libraryID(rel),
+		Path:        rel,
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		Format:      probe.Format,
+		Codec:       probe.Codec,
+		BitRate:     probe.BitRate,
+		SampleRate:  probe.SampleRate,
+		DurationSec: probe.DurationSec,
+		Title:       probe.Tags["title"],
+		Artist:      probe.Tags["artist"],
+		AlbumArtist: probe.Tags["album_artist"],
+		Album:       probe.Tags["album"],
+		Genre:       probe.Tags["genre"],
+		Tags:        probe.Tags,
+	}
+	if track.Title == "" {
+		track.Title = strings.TrimSuffix(path.Base(rel), path.Ext(rel))
+	}
+	if track.AlbumArtist == "" {
+		track.AlbumArtist = probe.Tags["albumartist"]
+	}
+	if track.AlbumArtist == "" {
+		track.AlbumArtist = track.Artist
+	}
+	// "3/12" → 3
+	track.TrackNumber, _ = strconv.Atoi(strings.Split(probe.Tags["track"], "/")[0])
+	track.DiscNumber, _ = strconv.Atoi(strings.Split(probe.Tags["disc"], "/")[0])
+	if date := probe.Tags["date"]; len(date) >= 4 {
+		track.Year = date[:4]
+	}
+	return track, nil
+}
+
+// libraryTracks retorna uma cópia das faixas indexadas, atualizando o índice se preciso.
+func libraryTracks(force bool) []LibraryTrack {
+	refreshLibraryIndex(force)
+
+	libraryIndexMu.Lock()
+	defer libraryIndexMu.Unlock()
+	return copyLibraryIndex()
+}
+
+// cachedLibraryTracks retorna uma cópia do índice como está, sem esperar a varredura:
+// vencido, ele é atualizado em segundo plano e a atualização vale para as próximas
+// consultas. É o que a busca e as sugestões usam, para não rodar ffprobe na requisição.
+func cachedLibraryTracks() []LibraryTrack {
+	libraryIndexMu.Lock()
+	ensureLibraryIndex()
+	stale := time.Since(libraryLastRefreshAt) >= libraryRefreshInterval
+	tracks := copyLibraryIndex()
+	libraryIndexMu.Unlock()
+
+	if stale && libraryRefreshing.CompareAndSwap(false, true) {
+		go func() {
+			defer libraryRefreshing.Store(false)
+			refreshLibraryIndex(false)
+		}()
+	}
+	return tracks
+}
+
+// copyLibraryIndex copia as faixas do índice ordenadas pelo caminho.
+// Deve ser chamada com libraryIndexMu travado.
+func copyLibraryIndex() []LibraryTrack {
+	tracks := make([]LibraryTrack, 0, len(libraryIndex))
+	for _, track := range libraryIndex {
+		tracks = append(tracks, *track)
+	}
+	sort.Slice(tracks, func(i, j int) bool { return tracks[i].Path < tracks[j].Path })
+	return tracks
+}
+
+// findLibraryTrack procura uma faixa indexada pelo ID.
+func findLibraryTrack(id string) (LibraryTrack, bool) {
+	for _, track := range libraryTracks(false) {
+		if track.ID == id {
+			return track, true
+		}
+	}
+	return LibraryTrack{}, false
+}
+
+// libraryAlbumKey identifica o álbum da faixa (artista do álbum e nome normalizados).
+func libraryAlbumKey(track LibraryTrack) string {
+	return normalizeText(track.AlbumArtist) + "|" + normalizeText(track.Album)
+}
+
+// libraryAlbums agrupa as faixas em álbuns.
+func libraryAlbums(tracks []LibraryTrack) []LibraryAlbum {
+	byKey := make(map[string]*LibraryAlbum)
+	for _, track := range tracks {
+		key := libraryAlbumKey(track)
+		album, ok := byKey[key]
+		if !ok {
+			album = &LibraryAlbum{
+				ID:        libraryID(key),
+				Name:      track.Album,
+				Artist:    track.AlbumArtist,
+				Directory: path.Dir(track.Path),
+			}
+			byKey[key] = album
+		}
+		album.TrackCount++
+		album.DurationSec += track.DurationSec
+		album.Size += track.Size
+		if album.Year == "" {
+			album.Year = track.Year
+		}
+		if album.Genre == "" {
+			album.Genre = track.Genre
+		}
+	}
+
+	albums := make([]LibraryAlbum, 0, len(byKey))
+	for _, album := range byKey {
+		albums = append(albums, *album)
+	}
+	sort.Slice(albums, func(i, j int) bool { return albums[i].ID < albums[j].ID })
+	return albums
+}
+
+// libraryArtists agrupa as faixas pelo artista do álbum.
+func libraryArtists(tracks []LibraryTrack) []LibraryArtist {
+	byKey := make(map[string]*LibraryArtist)
+	albums := make(map[string]map[string]bool)
+	for _, track := range tracks {
+		key := normalizeText(track.AlbumArtist)
+		artist, ok := byKey[key]
+		if !ok {
+			artist = &LibraryArtist{Name: track.AlbumArtist}
+			byKey[key] = artist
+			albums[key] = make(map[string]bool)
+		}
+		artist.TrackCount++
+		artist.DurationSec += track.DurationSec
+		albums[key][normalizeText(track.Album)] = true
+	}
+
+	artists := make([]LibraryArtist, 0, len(byKey))
+	for key, artist := range byKey {
+		artist.AlbumCount = len(albums[key])
+		artists = append(artists, *artist)
+	}
+	sort.Slice(artists, func(i, j int) bool { return artists[i].Name < artists[j].Name })
+	return artists
+}
+
+// libraryPage lê limit/offset da query string (limit padrão 50, máximo 500).
+func libraryPage(c *gin.Context) (int, int, error) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		return 0, 0, fmt.Errorf("limit must be between 1 and 500")
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+	}
+	return limit, offset, nil
+}
+
+// pageBounds recorta [offset, offset+limit) dentro de total itens.
+func pageBounds(total, limit, offset int) (int, int) {
+	start := offset
+	if start > total {
+		start = total
+	}
+	end := start + limit
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+// matchesFilter compara um valor com o filtro da query string, ignorando acentos e caixa.
+// Filtro vazio casa com tudo.
+func matchesFilter(value, filter string) bool {
+	return filter == "" || normalizeText(value) == normalizeText(filter)
+}
+
+// containsQuery indica se algum dos campos contém o termo de busca q.
+func containsQuery(q string, fields ...string) bool {
+	if q == "" {
+		return true
+	}
+	q = normalizeText(q)
+	for _, field := range fields {
+		if strings.Contains(normalizeText(field), q) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareText ordena textos sem diferenciar acentos e caixa.
+func compareText(a, b string) int {
+	return strings.Compare(normalizeText(a), normalizeText(b))
+}
+
+// sortLibrary ordena com less conforme order ("asc" ou "desc"). A ordenação é estável
+// para que a paginação não embaralhe itens empatados.
+func sortLibrary(n int, order string, less func(i, j int) bool, swap func(i, j int)) error {
+	switch order {
+	case "", "asc":
+		sort.Stable(librarySorter{n, less, swap})
+	case "desc":
+		sort.Stable(sort.Reverse(librarySorter{n, less, swap}))
+	default:
+		return fmt.Errorf("order must be asc or desc")
+	}
+	return nil
+}
+
+type librarySorter struct {
+	n    int
+	less func(i, j int) bool
+	swap func(i, j int)
+}
+
+func (s librarySorter) Len() int           { return s.n }
+func (s librarySorter) Less(i, j int) bool { return s.less(i, j) }
+func (s librarySorter) Swap(i, j int)      { s.swap(i, j) }
+
+// listLibraryTracks lista as faixas. Filtros: q, artist, album, genre, year, format.
+// Ordenação (sort): artist (padrão: artista, álbum, disco, faixa), title, album, year,
+// duration, size, bitrate, added; order=asc|desc. refresh=true força a releitura do disco.
+func listLibraryTracks(c *gin.Context) {
+	limit, offset, err := libraryPage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var tracks []LibraryTrack
+	for _, track := range libraryTracks(c.Query("refresh") == "true") {
+		if containsQuery(c.Query("q"), track.Title, track.Artist, track.AlbumArtist, track.Album) &&
+			(matchesFilter(track.Artist, c.Query("artist")) || matchesFilter(track.AlbumArtist, c.Query("artist"))) &&
+			matchesFilter(track.Album, c.Query("album")) &&
+			matchesFilter(track.Genre, c.Query("genre")) &&
+			matchesFilter(track.Year, c.Query("year")) &&
+			matchesFilter(track.Format, c.Query("format")) {
+			tracks = append(tracks, track)
+		}
+	}
+
+	var less func(i, j int) bool
+	switch c.DefaultQuery("sort", "artist") {
+	case "artist":
+		less = func(i, j int) bool {
+			a, b := tracks[i], tracks[j]
+			if cmp := compareText(a.AlbumArtist, b.AlbumArtist); cmp != 0 {
+				return cmp < 0
+			}
+			if cmp := compareText(a.Album, b.Album); cmp != 0 {
+				return cmp < 0
+			}
+			if a.DiscNumber != b.DiscNumber {
+				return a.DiscNumber < b.DiscNumber
+			}
+			if a.TrackNumber != b.TrackNumber {
+				return a.TrackNumber < b.TrackNumber
+			}
+			return a.Path < b.Path
+		}
+	case "title":
+		less = func(i, j int) bool { return compareText(tracks[i].Title, tracks[j].Title) < 0 }
+	case "album":
+		less = func(i, j int) bool { return compareText(tracks[i].Album, tracks[j].Album) < 0 }
+	case "year":
+		less = func(i, j int) bool { return tracks[i].Year < tracks[j].Year }
+	case "duration":
+		less = func(i, j int) bool { return tracks[i].DurationSec < tracks[j].DurationSec }
+	case "size":
+		less = func(i, j int) bool { return tracks[i].Size < tracks[j].Size }
+	case "bitrate":
+		less = func(i, j int) bool { return tracks[i].BitRate < tracks[j].BitRate }
+	case "added":
+		less = func(i, j int) bool { return tracks[i].ModTime.Before(tracks[j].ModTime) }
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort field"})
+		return
+	}
+	swap := func(i, j int) { tracks[i], tracks[j] = tracks[j], tracks[i] }
+	if err := sortLibrary(len(tracks), c.Query("order"), less, swap); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	start, end := pageBounds(len(tracks), limit, offset)
+	c.JSON(http.StatusOK, gin.H{
+		"tracks":   append([]LibraryTrack{}, tracks[start:end]...),
+		"total":    len(tracks),
+		"limit":    limit,
+		"offset":   offset,
+		"has_next": end < len(tracks),
+	})
+}
+
+// getLibraryTrack retorna uma faixa da biblioteca pelo ID.
+func getLibraryTrack(c *gin.Context) {
+	track, ok := findLibraryTrack(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Track not found"})
+		return
+	}
+	c.JSON(http.StatusOK, track)
+}
+
+// listLibraryAlbums lista os álbuns. Filtros: q, artist, genre, year.
+// Ordenação (sort): artist (padrão), name, year, tracks, duration; order=asc|desc.
+func listLibraryAlbums(c *gin.Context) {
+	limit, offset, err := libraryPage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var albums []LibraryAlbum
+	for _, album := range libraryAlbums(libraryTracks(c.Query("refresh") == "true")) {
+		if containsQuery(c.Query("q"), album.Name, album.Artist) &&
+			matchesFilter(album.Artist, c.Query("artist")) &&
+			matchesFilter(album.Genre, c.Query("genre")) &&
+			matchesFilter(album.Year, c.Query("year")) {
+			albums = append(albums, album)
+		}
+	}
+
+	var less func(i, j int) bool
+	switch c.DefaultQuery("sort", "artist") {
+	case "artist":
+		less = func(i, j int) bool {
+			if cmp := compareText(albums[i].Artist, albums[j].Artist); cmp != 0 {
+				return cmp < 0
+			}
+			if albums[i].Year != albums[j].Year {
+				return albums[i].Year < albums[j].Year
+			}
+			return compareText(albums[i].Name, albums[j].Name) < 0
+		}
+	case "name":
+		less = func(i, j int) bool { return compareText(albums[i].Name, albums[j].Name) < 0 }
+	case "year":
+		less = func(i, j int) bool { return albums[i].Year < albums[j].Year }
+	case "tracks":
+		less = func(i, j int) bool { return albums[i].TrackCount < albums[j].TrackCount }
+	case "duration":
+		less = func(i, j int) bool { return albums[i].DurationSec < albums[j].DurationSec }
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort field"})
+		return
+	}
+	swap := func(i, j int) { albums[i], albums[j] = albums[j], albums[i] }
+	if err := sortLibrary(len(albums), c.Query("order"), less, swap); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	start, end := pageBounds(len(albums), limit, offset)
+	c.JSON(http.StatusOK, gin.H{
+		"albums":   append([]LibraryAlbum{}, albums[start:end]...),
+		"total":    len(albums),
+		"limit":    limit,
+		"offset":   offset,
+		"has_next": end < len(albums),
+	})
+}
+
+// listLibraryArtists lista os artistas. Filtro: q.
+// Ordenação (sort): name (padrão), albums, tracks, duration; order=asc|desc.
+func listLibraryArtists(c *gin.Context) {
+	limit, offset, err := libraryPage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var artists []LibraryArtist
+	for _, artist := range libraryArtists(libraryTracks(c.Query("refresh") == "true")) {
+		if containsQuery(c.Query("q"), artist.Name) {
+			artists = append(artists, artist)
+		}
+	}
+
+	var less func(i, j int) bool
+	switch c.DefaultQuery("sort", "name") {
+	case "name":
+		less = func(i, j int) bool { return compareText(artists[i].Name, artists[j].Name) < 0 }
+	case "albums":
+		less = func(i, j int) bool { return artists[i].AlbumCount < artists[j].AlbumCount }
+	case "tracks":
+		less = func(i, j int) bool { return artists[i].TrackCount < artists[j].TrackCount }
+	case "duration":
+		less = func(i, j int) bool { return artists[i].DurationSec < artists[j].DurationSec }
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort field"})
+		return
+	}
+	swap := func(i, j int) { artists[i], artists[j] = artists[j], artists[i] }
+	if err := sortLibrary(len(artists), c.Query("order"), less, swap); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	start, end := pageBounds(len(artists), limit, offset)
+	c.JSON(http.StatusOK, gin.H{
+		"artists":  append([]LibraryArtist{}, artists[start:end]...),
+		"total":    len(artists),
+		"limit":    limit,
+		"offset":   offset,
+		"has_next": end < len(artists),
+	})
+}
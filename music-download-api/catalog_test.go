@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTestLibraryIndex troca o índice da biblioteca por tracks durante o teste, como se
+// tivesse sido atualizado em refreshedAt.
+func useTestLibraryIndex(t *testing.T, tracks []LibraryTrack, refreshedAt time.Time) {
+	t.Helper()
+	index := make(map[string]*LibraryTrack, len(tracks))
+	for i := range tracks {
+		index[tracks[i].Path] = &tracks[i]
+	}
+	libraryIndexMu.Lock()
+	previous, previousLoaded, previousRefresh := libraryIndex, libraryIndexLoaded, libraryLastRefreshAt
+	libraryIndex, libraryIndexLoaded, libraryLastRefreshAt = index, true, refreshedAt
+	libraryIndexMu.Unlock()
+	t.Cleanup(func() {
+		libraryIndexMu.Lock()
+		libraryIndex, libraryIndexLoaded, libraryLastRefreshAt = previous, previousLoaded, previousRefresh
+		libraryIndexMu.Unlock()
+	})
+}
+
+func TestRefreshLibraryIndex(t *testing.T) {
+	useTestLibrary(t)
+	useTestDataDir(t)
+	writeTestFile(t, absLibraryPath("Band/Album/01 - Song.mp3"), "audio")
+	writeTestFile(t, absLibraryPath("Band/Album/cover.jpg"), "image")
+	writeTestFile(t, absLibraryPath("Band/Album/.tagging-01 - Song.mp3"), "temp")
+	writeTestFile(t, absLibraryPath(".staging/job1/Other.mp3"), "staged")
+	info, err := os.Stat(absLibraryPath("Band/Album/01 - Song.mp3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tamanho e data iguais: a entrada é reaproveitada sem rodar o ffprobe
+	useTestLibraryIndex(t, []LibraryTrack{
+		{ID: "l1", Path: "Band/Album/01 - Song.mp3", Size: info.Size(), ModTime: info.ModTime(), Title: "Song"},
+		{ID: "l2", Path: "Band/Album/02 - Gone.mp3", Title: "Gone"},
+	}, time.Now())
+
+	refreshLibraryIndex(false)
+	if tracks := libraryTracks(false); len(tracks) != 2 {
+		t.Fatalf("refresh inside the interval changed the index: %v", tracks)
+	}
+
+	invalidateLibraryIndex()
+	tracks := libraryTracks(false)
+	if len(tracks) != 1 || tracks[0].ID != "l1" || tracks[0].Title != "Song" {
+		t.Fatalf("tracks after refresh = %+v, want only the unchanged song", tracks)
+	}
+
+	var saved map[string]*LibraryTrack
+	if err := loadJSONFile(libraryIndexFile, &saved); err != nil || len(saved) != 1 || saved["Band/Album/01 - Song.mp3"] == nil {
+		t.Errorf("saved index = %v (%v)", saved, err)
+	}
+}
+
+func TestLibraryAlbumsAndArtists(t *testing.T) {
+	tracks := []LibraryTrack{
+		{Path: "Band/Album/01.mp3", AlbumArtist: "Band", Album: "Album", DurationSec: 100, Size: 10, Year: "2010"},
+		{Path: "Band/Album/02.mp3", AlbumArtist: "Band", Album: "ALBUM", DurationSec: 50, Size: 5, Genre: "Rock"},
+		{Path: "Band/Live/01.mp3", AlbumArtist: "Band", Album: "Live", DurationSec: 30},
+		{Path: "Other/Record/01.mp3", AlbumArtist: "Other", Album: "Record", DurationSec: 20},
+	}
+
+	albums := libraryAlbums(tracks)
+	byName := make(map[string]LibraryAlbum)
+	for _, album := range albums {
+		byName[album.Name] = album
+	}
+	if len(albums) != 3 {
+		t.Fatalf("albums = %+v, want 3", albums)
+	}
+	want := LibraryAlbum{
+		ID: libraryID("band|album"), Name: "Album", Artist: "Band", Year: "2010", Genre: "Rock",
+		Directory: "Band/Album", TrackCount: 2, DurationSec: 150, Size: 15,
+	}
+	if got := byName["Album"]; got != want {
+		t.Errorf("album = %+v, want %+v", got, want)
+	}
+
+	artists := libraryArtists(tracks)
+	wantArtists := []LibraryArtist{
+		{Name: "Band", AlbumCount: 2, TrackCount: 3, DurationSec: 180},
+		{Name: "Other", AlbumCount: 1, TrackCount: 1, DurationSec: 20},
+	}
+	if !reflect.DeepEqual(artists, wantArtists) {
+		t.Errorf("artists = %+v, want %+v", artists, wantArtists)
+	}
+}
+
+func TestListLibraryTracks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestLibrary(t)
+	useTestLibraryIndex(t, []LibraryTrack{
+		{ID: "l1", Path: "b.mp3", Title: "Beta", Artist: "Band", AlbumArtist: "Band", Album: "Album", TrackNumber: 2, Format: "mp3"},
+		{ID: "l2", Path: "a.mp3", Title: "Alpha", Artist: "Band", AlbumArtist: "Band", Album: "Album", TrackNumber: 1, Format: "mp3"},
+		{ID: "l3", Path: "c.flac", Title: "Gamma", Artist: "Ótimo", AlbumArtist: "Ótimo", Album: "Disco", Format: "flac"},
+	}, time.Now())
+
+	router := gin.New()
+	router.GET("/library/tracks", listLibraryTracks)
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantIDs   []string
+		wantTotal int
+	}{
+		{"default order is by artist, album and track", "", http.StatusOK, []string{"l2", "l1", "l3"}, 3},
+		{"artist filter ignores accents", "artist=otimo", http.StatusOK, []string{"l3"}, 1},
+		{"title order descending with paging", "sort=title&order=desc&limit=2", http.StatusOK, []string{"l3", "l1"}, 3},
+		{"second page", "sort=title&limit=2&offset=2", http.StatusOK, []string{"l3"}, 3},
+		{"format filter", "format=mp3&q=alp", http.StatusOK, []string{"l2"}, 1},
+		{"invalid sort", "sort=color", http.StatusBadRequest, nil, 0},
+		{"invalid limit", "limit=0", http.StatusBadRequest, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/library/tracks?"+tt.query, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantCode, w.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var body struct {
+				Tracks []LibraryTrack `json:"tracks"`
+				Total  int            `json:"total"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, track := range body.Tracks {
+				ids = append(ids, track.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) || body.Total != tt.wantTotal {
+				t.Errorf("tracks = %v (total %d), want %v (total %d)", ids, body.Total, tt.wantIDs, tt.wantTotal)
+			}
+		})
+	}
+}
+
+// waitLibraryRefresh espera a atualização em segundo plano de cachedLibraryTracks.
+func waitLibraryRefresh(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for libraryRefreshing.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("library refresh did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCachedLibraryTracks(t *testing.T) {
+	tests := []struct {
+		name        string
+		refreshedAt time.Time
+		wantAfter   int
+	}{
+		{"fresh index is served as is", time.Now(), 1},
+		{"stale index is served and refreshed in the background", time.Time{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestLibrary(t)
+			useTestDataDir(t)
+			// O arquivo não existe: uma varredura o tira do índice
+			useTestLibraryIndex(t, []LibraryTrack{{ID: "l1", Path: "Band/Album/01 - Gone.mp3"}}, tt.refreshedAt)
+
+			if tracks := cachedLibraryTracks(); len(tracks) != 1 || tracks[0].ID != "l1" {
+				t.Fatalf("cachedLibraryTracks = %v, want the indexed track", tracks)
+			}
+			waitLibraryRefresh(t)
+			if tracks := cachedLibraryTracks(); len(tracks) != tt.wantAfter {
+				t.Errorf("after refresh got %d tracks, want %d", len(tracks), tt.wantAfter)
+			}
+			waitLibraryRefresh(t)
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// startScheduler carrega as assinaturas e artistas seguidos e verifica a cada minuto
+// quais devem ser sincronizados.
+func startScheduler() {
+	loadSubscriptions()
+	loadFollows()
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			runDueSubscriptions(now)
+			runDueFollows(now)
+		}
+	}()
+}
+
+// Schedule calcula o próximo horário de execução após um instante.
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// everySchedule executa em intervalos fixos ("@every 6h").
+type everySchedule struct {
+	interval time.Duration
+}
+
+func (s everySchedule) Next(after time.Time) time.Time {
+	return after.Add(s.interval)
+}
+
+// cronSchedule é uma expressão cron de 5 campos (minuto hora dia mês dia-da-semana).
+type cronSchedule struct {
+	minutes, hours, days, months, weekdays map[int]bool
+	daysAny, weekdaysAny                   bool
+}
+
+// cronDescriptors são os atalhos aceitos além das expressões de 5 campos.
+var cronDescriptors = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// parseSchedule interpreta "@every <duração>", os atalhos @hourly/@daily/@weekly/@monthly
+// ou uma expressão cron com *, listas (1,2), intervalos (1-5) e passos (*/15).
+func parseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid @every interval: %w", err)
+		}
+		if interval < time.Minute {
+			return nil, fmt.Errorf("@every interval must be at least 1m")
+		}
+		return everySchedule{interval: interval}, nil
+	}
+	if expr, ok := cronDescriptors[spec]; ok {
+		spec = expr
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression must have 5 fields, got %d", len(fields))
+	}
+
+	var s cronSchedule
+	var err error
+	if s.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if s.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	if s.days, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("day of month: %w", err)
+	}
+	if s.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+	if s.weekdays, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("day of week: %w", err)
+	}
+	// 7 também representa domingo
+	if s.weekdays[7] {
+		s.weekdays[0] = true
+	}
+	s.daysAny = fields[2] == "*"
+	s.weekdaysAny = fields[4] == "*"
+
+	return s, nil
+}
+
+// parseCronField expande um campo cron no conjunto de valores permitidos.
+func parseCronField(field string, minValue, maxValue int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = n
+		}
+
+		start, end := minValue, maxValue
+		if rangePart != "*" {
+			lo, hi, isRange := strings.Cut(rangePart, "-")
+			n, err := strconv.Atoi(lo)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", lo)
+			}
+			start, end = n, n
+			if isRange {
+				if end, err = strconv.Atoi(hi); err != nil {
+					return nil, fmt.Errorf("invalid value %q", hi)
+				}
+			} else if hasStep {
+				end = maxValue
+			}
+		}
+		if start < minValue || end > maxValue || start > end {
+			return nil, fmt.Errorf("value out of range in %q", part)
+		}
+
+		for v := start; v <= end; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// Next procura, minuto a minuto, o próximo horário que satisfaz a expressão (até 1 ano).
+// Como no cron tradicional, se dia do mês e dia da semana forem restritos, basta um casar.
+func (s cronSchedule) Next(after time.Time) time.Time {
+	t := time.Date(after.Year(), after.Month(), after.Day(), after.Hour(), after.Minute()+1, 0, 0, after.Location())
+	limit := t.AddDate(1, 0, 0)
+
+	for t.Before(limit) {
+		if !s.months[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		dayMatch := s.days[t.Day()]
+		weekdayMatch := s.weekdays[int(t.Weekday())]
+		var dayOK bool
+		switch {
+		case s.daysAny && s.weekdaysAny:
+			dayOK = true
+		case s.daysAny:
+			dayOK = weekdayMatch
+		case s.weekdaysAny:
+			dayOK = dayMatch
+		default:
+			dayOK = dayMatch || weekdayMatch
+		}
+		if !dayOK {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !s.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return limit
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{"@every 6h", false},
+		{"@every 30s", true},
+		{"@every soon", true},
+		{"@hourly", false},
+		{"@daily", false},
+		{"*/15 * * * *", false},
+		{"0 9-17 * * 1-5", false},
+		{"0 0 1,15 * 7", false},
+		{"* * * *", true},
+		{"60 * * * *", true},
+		{"0 24 * * *", true},
+		{"0 0 0 * *", true},
+		{"0 0 * 13 *", true},
+		{"*/0 * * * *", true},
+		{"5-1 * * * *", true},
+		{"a * * * *", true},
+	}
+	for _, tt := range tests {
+		_, err := parseSchedule(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSchedule(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// Fuso com deslocamento de meia hora: truncar o instante absoluto erraria a hora local
+	kolkata := time.FixedZone("IST", 5*3600+30*60)
+	after := time.Date(2024, 1, 31, 10, 20, 45, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		spec  string
+		after time.Time
+		want  time.Time
+	}{
+		{"every", "@every 6h", after, after.Add(6 * time.Hour)},
+		{"next minute", "* * * * *", after, time.Date(2024, 1, 31, 10, 21, 0, 0, time.UTC)},
+		{"step", "*/15 * * * *", after, time.Date(2024, 1, 31, 10, 30, 0, 0, time.UTC)},
+		{"hourly", "@hourly", after, time.Date(2024, 1, 31, 11, 0, 0, 0, time.UTC)},
+		{"daily", "@daily", after, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"month rollover", "0 0 1 * *", after, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"weekday", "0 9 * * 1", after, time.Date(2024, 2, 5, 9, 0, 0, 0, time.UTC)},
+		{"sunday as 7", "0 9 * * 7", after, time.Date(2024, 2, 4, 9, 0, 0, 0, time.UTC)},
+		{"day or weekday", "0 0 15 * 5", after, time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)},
+		{"leap day", "0 0 29 2 *", after, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{
+			"half hour offset zone",
+			"0 * * * *",
+			time.Date(2024, 1, 31, 10, 20, 0, 0, kolkata),
+			time.Date(2024, 1, 31, 11, 0, 0, 0, kolkata),
+		},
+		{
+			"half hour offset zone daily",
+			"0 3 * * *",
+			time.Date(2024, 1, 31, 10, 20, 0, 0, kolkata),
+			time.Date(2024, 2, 1, 3, 0, 0, 0, kolkata),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := parseSchedule(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.Next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.after, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// spotifyIDPattern valida IDs do Spotify (22 caracteres em base 62).
+var spotifyIDPattern = regexp.MustCompile(`^[0-9A-Za-z]{22}$`)
+
+// spotifyIDParam aceita um ID, URI (spotify:artist:ID) ou URL do Spotify do tipo
+// informado e devolve só o ID.
+func spotifyIDParam(value, itemType string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case spotifyIDPattern.MatchString(value):
+		return value, nil
+	case strings.HasPrefix(value, "spotify:"+itemType+":"):
+		id := strings.TrimPrefix(value, "spotify:"+itemType+":")
+		if spotifyIDPattern.MatchString(id) {
+			return id, nil
+		}
+	case strings.Contains(value, "open.spotify.com"):
+		t, id, err := extractSpotifyID(value)
+		if err == nil && t == itemType && spotifyIDPattern.MatchString(id) {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("invalid Spotify %s: %s", itemType, value)
+}
+
+// spotifyIDList lê uma lista separada por vírgulas de IDs, URIs ou URLs do Spotify.
+func spotifyIDList(value, itemType string) ([]string, error) {
+	var ids []string
+	for _, item := range strings.Split(value, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		id, err := spotifyIDParam(item, itemType)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// discoverResponse cria uma resposta vazia no mesmo formato de /v2/search. As URLs dos
+// resultados podem ser enviadas direto para POST /download.
+func discoverResponse(query string, limit, offset int) SearchResponseV2 {
+	return SearchResponseV2{
+		Version:    searchSchemaVersion,
+		Query:      query,
+		Tracks:     []SearchTrack{},
+		Albums:     []SearchAlbum{},
+		Artists:    []SearchArtist{},
+		Playlists:  []SearchPlaylist{},
+		Pagination: SearchPagination{Limit: limit, Offset: offset},
+	}
+}
+
+// discoverLimit lê o parâmetro limit (1 a 50, padrão 20).
+func discoverLimit(c *gin.Context) (int, error) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 50 {
+		return 0, fmt.Errorf("limit must be between 1 and 50")
+	}
+	return limit, nil
+}
+
+// discoverRelatedArtists lista os artistas relacionados a um artista do Spotify.
+func discoverRelatedArtists(c *gin.Context) {
+	artistID, err := spotifyIDParam(c.Query("artist"), "artist")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A Spotify artist ID, URI or URL is required"})
+		return
+	}
+
+	var result struct {
+		Artists []spotifyArtistObject `json:"artists"`
+	}
+	endpoint := fmt.Sprintf("https://api.spotify.com/v1/artists/%s/related-artists", artistID)
+	if err := spotifyGet(endpoint, &result); err != nil {
+		log.WithError(err).Errorf("Failed to get artists related to %s", artistID)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to get related artists"})
+		return
+	}
+
+	response := discoverResponse("related-artists:"+artistID, len(result.Artists), 0)
+	for _, artist := range result.Artists {
+		response.Artists = append(response.Artists, normalizeSpotifyArtist(artist))
+	}
+	response.Pagination.Total.Artists = len(response.Artists)
+	markInLibrary(&response, libraryTracks(false))
+	c.JSON(http.StatusOK, response)
+}
+
+// discoverRecommendations lista faixas recomendadas a partir de até 5 sementes
+// (seed_tracks, seed_artists e seed_genres somados). market é opcional.
+func discoverRecommendations(c *gin.Context) {
+	seedTracks, err := spotifyIDList(c.Query("seed_tracks"), "track")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	seedArtists, err := spotifyIDList(c.Query("seed_artists"), "artist")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var seedGenres []string
+	for _, genre := range strings.Split(c.Query("seed_genres"), ",") {
+		if genre = strings.TrimSpace(genre); genre != "" {
+			seedGenres = append(seedGenres, genre)
+		}
+	}
+	seeds := len(seedTracks) + len(seedArtists) + len(seedGenres)
+	if seeds == 0 || seeds > 5 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Between 1 and 5 seeds are required (seed_tracks, seed_artists, seed_genres)"})
+		return
+	}
+	limit, err := discoverLimit(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := url.Values{"limit": {strconv.Itoa(limit)}}
+	if len(seedTracks) > 0 {
+		query.Set("seed_tracks", strings.Join(seedTracks, ","))
+	}
+	if len(seedArtists) > 0 {
+		query.Set("seed_artists", strings.Join(seedArtists, ","))
+	}
+	if len(seedGenres) > 0 {
+		query.Set("seed_genres", strings.Join(seedGenres, ","))
+	}
+	if market := strings.ToUpper(c.Query("market")); market != "" {
+		if !marketFilterPattern.MatchString(market) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "market must be a two-letter country code"})
+			return
+		}
+		query.Set("market", market)
+	}
+
+	var result struct {
+		Tracks []spotifyTrackObject `json:"tracks"`
+	}
+	if err := spotifyGet("https://api.spotify.com/v1/recommendations?"+query.Encode(), &result); err != nil {
+		log.WithError(err).Error("Failed to get Spotify recommendations")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to get recommendations"})
+		return
+	}
+
+	response := discoverResponse("recommendations", limit, 0)
+	for _, track := range result.Tracks {
+		response.Tracks = append(response.Tracks, normalizeSpotifyTrack(track))
+	}
+	response.Pagination.Total.Tracks = len(response.Tracks)
+	markInLibrary(&response, libraryTracks(false))
+	c.JSON(http.StatusOK, response)
+}
+
+// discoverNewReleases lista os lançamentos recentes do Spotify, paginados por
+// limit/offset. country filtra por país (código ISO).
+func discoverNewReleases(c *gin.Context) {
+	limit, err := discoverLimit(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+
+	query := url.Values{"limit": {strconv.Itoa(limit)}, "offset": {strconv.Itoa(offset)}}
+	if country := strings.ToUpper(c.Query("country")); country != "" {
+		if !marketFilterPattern.MatchString(country) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "country must be a two-letter country code"})
+			return
+		}
+		query.Set("country", country)
+	}
+
+	var result struct {
+		Albums struct {
+			spotifyPaging
+			Items []spotifyAlbumRef `json:"items"`
+		} `json:"albums"`
+	}
+	if err := spotifyGet("https://api.spotify.com/v1/browse/new-releases?"+query.Encode(), &result); err != nil {
+		log.WithError(err).Error("Failed to get Spotify new releases")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to get new releases"})
+		return
+	}
+
+	response := discoverResponse("new-releases", limit, offset)
+	for _, album := range result.Albums.Items {
+		response.Albums = append(response.Albums, normalizeSpotifyAlbum(album))
+	}
+	response.Pagination.Total.Albums = result.Albums.Total
+	response.Pagination.HasNext = result.Albums.Next != ""
+	response.Pagination.HasPrevious = offset > 0
+	markInLibrary(&response, libraryTracks(false))
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSpotifyIDParam(t *testing.T) {
+	const id = "4Z8W4fKeB5YxbusRsdQVPb"
+	tests := []struct {
+		name     string
+		value    string
+		itemType string
+		want     string
+		wantErr  bool
+	}{
+		{name: "bare id", value: id, itemType: "artist", want: id},
+		{name: "surrounding spaces", value: "  " + id + " ", itemType: "artist", want: id},
+		{name: "uri", value: "spotify:artist:" + id, itemType: "artist", want: id},
+		{name: "url", value: "https://open.spotify.com/artist/" + id, itemType: "artist", want: id},
+		{name: "url with query", value: "https://open.spotify.com/artist/" + id + "?si=abc", itemType: "artist", want: id},
+		{name: "localized url", value: "https://open.spotify.com/intl-pt/track/" + id, itemType: "track", want: id},
+		{name: "uri of another type", value: "spotify:track:" + id, itemType: "artist", wantErr: true},
+		{name: "url of another type", value: "https://open.spotify.com/album/" + id, itemType: "artist", wantErr: true},
+		{name: "short id", value: "4Z8W4fKeB5", itemType: "artist", wantErr: true},
+		{name: "invalid characters", value: "4Z8W4fKeB5YxbusRsdQV-b", itemType: "artist", wantErr: true},
+		{name: "uri with invalid id", value: "spotify:artist:abc", itemType: "artist", wantErr: true},
+		{name: "other site", value: "https://example.com/artist/" + id, itemType: "artist", wantErr: true},
+		{name: "empty", value: "", itemType: "artist", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := spotifyIDParam(tt.value, tt.itemType)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("spotifyIDParam(%q, %q) = %q, want error", tt.value, tt.itemType, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("spotifyIDParam(%q, %q) = %q, %v; want %q", tt.value, tt.itemType, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpotifyIDList(t *testing.T) {
+	const a, b = "4Z8W4fKeB5YxbusRsdQVPb", "3eGXGSSlcUc8EU1ABMxtYZ"
+	got, err := spotifyIDList(a+", spotify:track:"+b+",,", "track")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{a, b}; !reflect.DeepEqual(got, want) {
+		t.Errorf("spotifyIDList = %v, want %v", got, want)
+	}
+	if _, err := spotifyIDList(a+",spotify:album:"+b, "track"); err == nil {
+		t.Error("spotifyIDList accepted an album URI for tracks")
+	}
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"math/bits"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const fingerprintsFile = "fingerprints.json"
+
+var (
+	// duplicateMinSimilarity é a similaridade mínima (0 a 1) para considerar dois arquivos
+	// a mesma gravação; impressões de músicas diferentes ficam perto de 0,5.
+	duplicateMinSimilarity = getEnvFloat("DUPLICATE_MIN_SIMILARITY", 0.85)
+	// duplicateMaxDurationDiff limita a diferença de duração entre candidatos, em segundos.
+	duplicateMaxDurationDiff = getEnvFloat("DUPLICATE_MAX_DURATION_DIFF", 15)
+)
+
+// fingerprintMaxOffset é o deslocamento máximo testado no alinhamento (≈10s), para
+// casar versões com introduções de tamanhos diferentes.
+const fingerprintMaxOffset = 80
+
+// fingerprintsSaveDelay agrupa as alterações do cache de impressões digitais, que pode ter
+// centenas de MB, em uma gravação a cada intervalo em vez de uma por job.
+const fingerprintsSaveDelay = 30 * time.Second
+
+// fingerprintEntry guarda a impressão digital de um arquivo, válida enquanto tamanho e
+// data de modificação não mudarem. Error registra que o fpcalc falhou no arquivo, para
+// que ele não seja reprocessado a cada varredura.
+type fingerprintEntry struct {
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+	DurationSec float64   `json:"duration"`
+	Raw         []uint32  `json:"raw,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+var (
+	fingerprints          = make(map[string]*fingerprintEntry)
+	fingerprintsMu        sync.Mutex
+	fingerprintsLoaded    bool
+	fingerprintsSaveTimer *time.Timer
+)
+
+// DuplicateFile é um arquivo de um grupo de duplicatas com a ação sugerida.
+type DuplicateFile struct {
+	ID              string  `json:"id"`
+	Path            string  `json:"path"`
+	Format          string  `json:"format"`
+	BitRate         int     `json:"bit_rate"`
+	DurationSec     float64 `json:"duration_sec"`
+	Size            int64   `json:"size"`
+	TagCompleteness float64 `json:"tag_completeness"`
+	Similarity      float64 `json:"similarity"`
+	Action          string  `json:"action"`
+}
+
+// DuplicateGroup reúne arquivos com a mesma gravação; o primeiro é o sugerido para manter.
+type DuplicateGroup struct {
+	Files []DuplicateFile `json:"files"`
+}
+
+// losslessFormats recebem bitrate efetivo máximo na escolha do arquivo a manter.
+var losslessFormats = map[string]bool{"flac": true, "wav": true}
+
+// completenessTags são as tags consideradas na nota de completude.
+var completenessTags = []string{"title", "artist", "album", "album_artist", "track", "date", "genre", "isrc"}
+
+// loadFingerprints carrega o cache de impressões digitais na primeira utilização.
+// Deve ser chamada com fingerprintsMu travado.
+func loadFingerprints() {
+	if fingerprintsLoaded {
+		return
+	}
+	fingerprintsLoaded = true
+	if err := loadJSONFile(fingerprintsFile, &fingerprints); err != nil {
+		log.WithError(err).Error("Failed to load fingerprints")
+	}
+}
+
+// fingerprintFresh indica se a impressão digital guardada ainda vale para a faixa.
+// Deve ser chamada com fingerprintsMu travado.
+func fingerprintFresh(track LibraryTrack) bool {
+	entry, ok := fingerprints[track.Path]
+	return ok && entry.Size == track.Size && entry.ModTime.Equal(track.ModTime)
+}
+
+// ensureFingerprints calcula as impressões digitais que faltam ou estão desatualizadas
+// para as faixas informadas e descarta as de arquivos que não existem mais. O fpcalc
+// roda fora de fingerprintsMu, para não travar a leitura do cache.
+func ensureFingerprints(tracks []LibraryTrack, prune bool) {
+	fingerprintsMu.Lock()
+	loadFingerprints()
+	var stale []LibraryTrack
+	for _, track := range tracks {
+		if !fingerprintFresh(track) {
+			stale = append(stale, track)
+		}
+	}
+	fingerprintsMu.Unlock()
+
+	computed := make(map[string]*fingerprintEntry, len(stale))
+	for _, track := range stale {
+		entry := &fingerprintEntry{Size: track.Size, ModTime: track.ModTime}
+		raw, duration, err := rawFingerprint(absLibraryPath(track.Path))
+		if err != nil {
+			log.WithError(err).Warnf("Failed to fingerprint %s", track.Path)
+			entry.Error = err.Error()
+		} else {
+			entry.DurationSec, entry.Raw = duration, raw
+		}
+		computed[track.Path] = entry
+	}
+
+	fingerprintsMu.Lock()
+	defer fingerprintsMu.Unlock()
+
+	changed := len(computed) > 0
+	for rel, entry := range computed {
+		fingerprints[rel] = entry
+	}
+	if prune {
+		present := make(map[string]bool, len(tracks))
+		for _, track := range tracks {
+			present[track.Path] = true
+		}
+		for rel := range fingerprints {
+			if !present[rel] {
+				delete(fingerprints, rel)
+				changed = true
+			}
+		}
+	}
+
+	if changed {
+		scheduleFingerprintsSave()
+	}
+}
+
+// scheduleFingerprintsSave agenda a gravação do cache para daqui a fingerprintsSaveDelay;
+// alterações feitas até lá saem na mesma gravação. Deve ser chamada com fingerprintsMu
+// travado.
+func scheduleFingerprintsSave() {
+	if fingerprintsSaveTimer != nil {
+		return
+	}
+	fingerprintsSaveTimer = time.AfterFunc(fingerprintsSaveDelay, func() {
+		fingerprintsMu.Lock()
+		defer fingerprintsMu.Unlock()
+		fingerprintsSaveTimer = nil
+		if err := saveJSONFile(fingerprintsFile, fingerprints); err != nil {
+			log.WithError(err).Error("Failed to save fingerprints")
+		}
+	})
+}
+
+// FingerprintScan é o estado da varredura de impressões digitais em segundo plano.
+type FingerprintScan struct {
+	Running    bool       `json:"running"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+var (
+	fingerprintScan   FingerprintScan
+	fingerprintScanMu sync.Mutex
+)
+
+// startFingerprintScan calcula em segundo plano as impressões digitais de toda a
+// biblioteca. Retorna false se já houver uma varredura em andamento.
+func startFingerprintScan(refresh bool) bool {
+	fingerprintScanMu.Lock()
+	defer fingerprintScanMu.Unlock()
+	if fingerprintScan.Running {
+		return false
+	}
+	now := time.Now()
+	fingerprintScan = FingerprintScan{Running: true, StartedAt: &now}
+
+	go func() {
+		ensureFingerprints(libraryTracks(refresh), true)
+
+		fingerprintScanMu.Lock()
+		defer fingerprintScanMu.Unlock()
+		finished := time.Now()
+		fingerprintScan.Running = false
+		fingerprintScan.FinishedAt = &finished
+		log.Info("Library fingerprint scan finished")
+	}()
+	return true
+}
+
+// fingerprintJobFiles calcula as impressões digitais das faixas recém-baixadas pelo job,
+// para que a busca de duplicatas não precise processá-las depois.
+func fingerprintJobFiles(job *DownloadJob) {
+	var tracks []LibraryTrack
+	for _, track := range job.Tracks {
+		info, err := os.Stat(absLibraryPath(track.File))
+		if err != nil {
+			continue
+		}
+		tracks = append(tracks, LibraryTrack{Path: track.File, Size: info.Size(), ModTime: info.ModTime()})
+	}
+	if len(tracks) > 0 {
+		ensureFingerprints(tracks, false)
+	}
+}
+
+// fingerprintSimilarity compara duas impressões digitais testando deslocamentos de até
+// fingerprintMaxOffset palavras e devolve a melhor fração de bits iguais.
+func fingerprintSimilarity(a, b []uint32) float64 {
+	best := 0.0
+	for offset := -fingerprintMaxOffset; offset <= fingerprintMaxOffset; offset++ {
+		matching, total := 0, 0
+		for i := range a {
+			j := i + offset
+			if j < 0 {
+				continue
+			}
+			if j >= len(b) {
+				break
+			}
+			matching += 32 - bits.OnesCount32(a[i]^b[j])
+			total += 32
+		}
+		// Sobreposições muito curtas não são conclusivas
+		if total < 32*len(a)/2 && total < 32*len(b)/2 {
+			continue
+		}
+		if score := float64(matching) / float64(total); score > best {
+			best = score
+		}
+	}
+	return best
+}
+
+// tagCompleteness é a fração de completenessTags presentes no arquivo.
+func tagCompleteness(track LibraryTrack) float64 {
+	present := 0
+	for _, key := range completenessTags {
+		value := track.Tags[key]
+		if key == "isrc" && value == "" {
+			value = track.Tags["tsrc"]
+		}
+		if strings.TrimSpace(value) != "" {
+			present++
+		}
+	}
+	return float64(present) / float64(len(completenessTags))
+}
+
+// keepScore ordena os arquivos de um grupo: bitrate (formatos sem perda valem o máximo)
+// pesa 60% e a completude das tags 40%.
+func keepScore(track LibraryTrack) float64 {
+	bitrate := float64(track.BitRate) / 320000
+	if losslessFormats[track.Format] || bitrate > 1 {
+		bitrate = 1
+	}
+	return 0.6*bitrate + 0.4*tagCompleteness(track)
+}
+
+// Parâmetros do pré-filtro: as palavras são reduzidas aos 20 bits mais altos; pares com
+// poucas palavras em comum ou palavras comuns a muitos arquivos (silêncio) são ignorados.
+const (
+	fingerprintKeyShift     = 12
+	fingerprintMinSharedKey = 10
+	fingerprintMaxKeyFiles  = 50
+)
+
+// duplicateCandidatePairs usa um índice invertido das palavras das impressões digitais
+// para encontrar os pares que valem a comparação completa, evitando comparar todos com
+// todos.
+func duplicateCandidatePairs(raws [][]uint32) [][2]int {
+	index := make(map[uint32][]int)
+	for i, raw := range raws {
+		seen := make(map[uint32]bool)
+		for _, word := range raw {
+			key := word >> fingerprintKeyShift
+			if !seen[key] {
+				seen[key] = true
+				index[key] = append(index[key], i)
+			}
+		}
+	}
+
+	shared := make(map[[2]int]int)
+	for _, files := range index {
+		if len(files) < 2 || len(files) > fingerprintMaxKeyFiles {
+			continue
+		}
+		for a := 0; a < len(files); a++ {
+			for b := a + 1; b < len(files); b++ {
+				shared[[2]int{files[a], files[b]}]++
+			}
+		}
+	}
+
+	var pairs [][2]int
+	for pair, count := range shared {
+		if count >= fingerprintMinSharedKey {
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs
+}
+
+// findDuplicates agrupa as faixas cuja impressão digital coincide, usando apenas as
+// impressões já calculadas; pending conta as faixas ainda sem impressão atualizada e
+// failed as que o fpcalc não conseguiu processar. Só são comparadas faixas com durações
+// próximas e com trechos em comum.
+func findDuplicates(tracks []LibraryTrack, minSimilarity float64) (groups []DuplicateGroup, pending, failed int) {
+	fingerprintsMu.Lock()
+	loadFingerprints()
+	type candidate struct {
+		track LibraryTrack
+		fp    *fingerprintEntry
+	}
+	var candidates []candidate
+	for _, track := range tracks {
+		if !fingerprintFresh(track) {
+			pending++
+			continue
+		}
+		if fingerprints[track.Path].Error != "" {
+			failed++
+			continue
+		}
+		candidates = append(candidates, candidate{track, fingerprints[track.Path]})
+	}
+	fingerprintsMu.Unlock()
+
+	// Union-find sobre os pares semelhantes
+	parent := make([]int, len(candidates))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	similarity := make(map[[2]int]float64)
+	raws := make([][]uint32, len(candidates))
+	for i, c := range candidates {
+		raws[i] = c.fp.Raw
+	}
+	for _, pair := range duplicateCandidatePairs(raws) {
+		i, j := pair[0], pair[1]
+		diff := candidates[j].fp.DurationSec - candidates[i].fp.DurationSec
+		if diff > duplicateMaxDurationDiff || -diff > duplicateMaxDurationDiff {
+			continue
+		}
+		score := fingerprintSimilarity(candidates[i].fp.Raw, candidates[j].fp.Raw)
+		if score < minSimilarity {
+			continue
+		}
+		similarity[[2]int{i, j}] = score
+		similarity[[2]int{j, i}] = score
+		parent[find(i)] = find(j)
+	}
+
+	members := make(map[int][]int)
+	for i := range candidates {
+		root := find(i)
+		members[root] = append(members[root], i)
+	}
+
+	for _, indexes := range members {
+		if len(indexes) < 2 {
+			continue
+		}
+		sort.SliceStable(indexes, func(a, b int) bool {
+			return keepScore(candidates[indexes[a]].track) > keepScore(candidates[indexes[b]].track)
+		})
+
+		keep := indexes[0]
+		var group DuplicateGroup
+		for n, i := range indexes {
+			track := candidates[i].track
+			file := DuplicateFile{
+				ID:              track.ID,
+				Path:            track.Path,
+				Format:          track.Format,
+				BitRate:         track.BitRate,
+				DurationSec:     track.DurationSec,
+				Size:            track.Size,
+				TagCompleteness: tagCompleteness(track),
+				Similarity:      1,
+				Action:          "keep",
+			}
+			if n > 0 {
+				file.Action = "delete"
+				file.Similarity = similarity[[2]int{keep, i}]
+				// Membro ligado ao grupo por outro arquivo: compara com o mantido
+				if file.Similarity == 0 {
+					file.Similarity = fingerprintSimilarity(candidates[keep].fp.Raw, candidates[i].fp.Raw)
+				}
+			}
+			group.Files = append(group.Files, file)
+		}
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Files[0].Path < groups[j].Files[0].Path })
+	return groups, pending, failed
+}
+
+// listDuplicates retorna os grupos de arquivos com a mesma gravação na biblioteca,
+// sugerindo qual manter, a partir das impressões digitais em cache. Faixas pendentes
+// disparam a varredura em segundo plano (refresh=true também relê a biblioteca); as que
+// falharam no fpcalc aparecem em failed e só são refeitas quando o arquivo muda.
+// min_similarity sobrescreve DUPLICATE_MIN_SIMILARITY.
+func listDuplicates(c *gin.Context) {
+	minSimilarity := duplicateMinSimilarity
+	if raw := c.Query("min_similarity"); raw != "" {
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || value <= 0.5 || value > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_similarity must be between 0.5 and 1"})
+			return
+		}
+		minSimilarity = value
+	}
+
+	refresh := c.Query("refresh") == "true"
+	groups, pending, failed := findDuplicates(libraryTracks(false), minSimilarity)
+	if pending > 0 || refresh {
+		startFingerprintScan(refresh)
+	}
+
+	fingerprintScanMu.Lock()
+	scan := fingerprintScan
+	fingerprintScanMu.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"groups": groups, "total": len(groups), "pending": pending, "failed": failed, "scan": scan,
+	})
+}
+
+// scanDuplicates inicia a varredura de impressões digitais da biblioteca em segundo
+// plano; o andamento aparece em GET /library/duplicates.
+func scanDuplicates(c *gin.Context) {
+	if !startFingerprintScan(true) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Fingerprint scan already running"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"status": "scanning"})
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+)
+
+func randomRaw(r *rand.Rand, n int) []uint32 {
+	raw := make([]uint32, n)
+	for i := range raw {
+		raw[i] = r.Uint32()
+	}
+	return raw
+}
+
+// useTestFingerprints começa o teste com o cache de impressões digitais vazio e cancela a
+// gravação agendada ao final.
+func useTestFingerprints(t *testing.T) {
+	t.Helper()
+	fingerprintsMu.Lock()
+	previous, previousLoaded := fingerprints, fingerprintsLoaded
+	fingerprints, fingerprintsLoaded = make(map[string]*fingerprintEntry), true
+	fingerprintsMu.Unlock()
+	t.Cleanup(func() {
+		fingerprintsMu.Lock()
+		defer fingerprintsMu.Unlock()
+		if fingerprintsSaveTimer != nil {
+			fingerprintsSaveTimer.Stop()
+			fingerprintsSaveTimer = nil
+		}
+		fingerprints, fingerprintsLoaded = previous, previousLoaded
+	})
+}
+
+func TestFindDuplicatesUsesCachedFingerprints(t *testing.T) {
+	useTestFingerprints(t)
+	r := rand.New(rand.NewSource(1))
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	song := randomRaw(r, 300)
+
+	fingerprintsMu.Lock()
+	fingerprints = map[string]*fingerprintEntry{
+		"a/song.mp3":   {Size: 100, ModTime: modTime, DurationSec: 200, Raw: song},
+		"b/song.flac":  {Size: 500, ModTime: modTime, DurationSec: 201, Raw: song},
+		"c/other.mp3":  {Size: 100, ModTime: modTime, DurationSec: 200, Raw: randomRaw(r, 300)},
+		"d/stale.mp3":  {Size: 1, ModTime: modTime, DurationSec: 200, Raw: song},
+		"e/orphan.mp3": {Size: 100, ModTime: modTime, DurationSec: 200, Raw: song},
+		"g/broken.mp3": {Size: 100, ModTime: modTime, Error: "fpcalc failed"},
+	}
+	fingerprintsMu.Unlock()
+
+	tracks := []LibraryTrack{
+		{ID: "1", Path: "a/song.mp3", Size: 100, ModTime: modTime, Format: "mp3", BitRate: 128000},
+		{ID: "2", Path: "b/song.flac", Size: 500, ModTime: modTime, Format: "flac"},
+		{ID: "3", Path: "c/other.mp3", Size: 100, ModTime: modTime, Format: "mp3"},
+		// Arquivo alterado desde a impressão: fica pendente em vez de ser recalculado
+		{ID: "4", Path: "d/stale.mp3", Size: 2, ModTime: modTime, Format: "mp3"},
+		{ID: "5", Path: "f/new.mp3", Size: 100, ModTime: modTime, Format: "mp3"},
+		// Falha já registrada: não conta como pendente, senão toda consulta refaria a varredura
+		{ID: "6", Path: "g/broken.mp3", Size: 100, ModTime: modTime, Format: "mp3"},
+	}
+
+	groups, pending, failed := findDuplicates(tracks, 0.85)
+	if pending != 2 || failed != 1 {
+		t.Errorf("pending = %d, failed = %d; want 2 and 1", pending, failed)
+	}
+	if len(groups) != 1 || len(groups[0].Files) != 2 {
+		t.Fatalf("groups = %+v, want one group with two files", groups)
+	}
+	keep, drop := groups[0].Files[0], groups[0].Files[1]
+	if keep.Path != "b/song.flac" || keep.Action != "keep" {
+		t.Errorf("kept %s (%s), want the lossless file", keep.Path, keep.Action)
+	}
+	if drop.Path != "a/song.mp3" || drop.Action != "delete" || drop.Similarity != 1 {
+		t.Errorf("second file = %+v, want a/song.mp3 marked for deletion", drop)
+	}
+}
+
+func TestEnsureFingerprintsCachesFailures(t *testing.T) {
+	useTestLibrary(t)
+	useTestFingerprints(t)
+	// Não é um arquivo de áudio válido (e o fpcalc pode nem estar instalado): a falha fica
+	// registrada com tamanho e data do arquivo
+	writeTestFile(t, absLibraryPath("broken.mp3"), "not audio")
+	info, err := os.Stat(absLibraryPath("broken.mp3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	track := LibraryTrack{Path: "broken.mp3", Size: info.Size(), ModTime: info.ModTime()}
+
+	ensureFingerprints([]LibraryTrack{track}, false)
+
+	fingerprintsMu.Lock()
+	entry := fingerprints["broken.mp3"]
+	fresh := fingerprintFresh(track)
+	scheduled := fingerprintsSaveTimer != nil
+	fingerprintsMu.Unlock()
+	if entry == nil || entry.Error == "" || !fresh {
+		t.Fatalf("entry = %+v (fresh %v), want a cached failure", entry, fresh)
+	}
+	if !scheduled {
+		t.Error("cache save was not scheduled")
+	}
+	if _, pending, failed := findDuplicates([]LibraryTrack{track}, 0.85); pending != 0 || failed != 1 {
+		t.Errorf("pending = %d, failed = %d; want 0 and 1", pending, failed)
+	}
+
+	// Com o arquivo alterado a impressão volta a ficar pendente
+	track.Size++
+	if _, pending, _ := findDuplicates([]LibraryTrack{track}, 0.85); pending != 1 {
+		t.Errorf("pending after change = %d, want 1", pending)
+	}
+}
+
+func TestFingerprintSimilarity(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	song := randomRaw(r, 300)
+	// A mesma gravação com 5s a mais de introdução
+	shifted := append(randomRaw(r, 40), song...)
+
+	if got := fingerprintSimilarity(song, song); got != 1 {
+		t.Errorf("similarity of identical fingerprints = %v, want 1", got)
+	}
+	if got := fingerprintSimilarity(song, shifted); got != 1 {
+		t.Errorf("similarity with a longer intro = %v, want 1", got)
+	}
+	if got := fingerprintSimilarity(song, randomRaw(r, 300)); got > 0.6 {
+		t.Errorf("similarity of unrelated fingerprints = %v, want about 0.5", got)
+	}
+}
+
+func TestKeepScore(t *testing.T) {
+	tagged := map[string]string{"title": "Song", "artist": "Band", "album": "Album", "album_artist": "Band"}
+	lossless := LibraryTrack{Format: "flac"}
+	mp3 := LibraryTrack{Format: "mp3", BitRate: 320000}
+	taggedMP3 := LibraryTrack{Format: "mp3", BitRate: 128000, Tags: tagged}
+
+	if keepScore(lossless) != keepScore(mp3) {
+		t.Errorf("lossless and 320k scores differ: %v, %v", keepScore(lossless), keepScore(mp3))
+	}
+	if got := tagCompleteness(taggedMP3); got != 0.5 {
+		t.Errorf("tagCompleteness = %v, want 0.5", got)
+	}
+	if keepScore(taggedMP3) >= keepScore(mp3) {
+		t.Errorf("tags outweighed bitrate: %v >= %v", keepScore(taggedMP3), keepScore(mp3))
+	}
+}
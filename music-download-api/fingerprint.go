@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+)
+
+// acoustIDKey habilita a identificação de faixas pela impressão digital no AcoustID.
+var acoustIDKey = os.Getenv("ACOUSTID_API_KEY")
+
+// Fingerprint é a impressão digital Chromaprint de um arquivo, calculada pelo fpcalc.
+type Fingerprint struct {
+	DurationSec float64 `json:"duration"`
+	Value       string  `json:"fingerprint"`
+}
+
+// fingerprintAudio calcula a impressão digital do arquivo com o fpcalc (Chromaprint).
+func fingerprintAudio(path string) (*Fingerprint, error) {
+	output, err := exec.Command("fpcalc", "-json", path).Output()
+	if err != nil {
+		return nil, fmt.Errorf("fpcalc failed for %s: %w", path, err)
+	}
+	var fp Fingerprint
+	if err := json.Unmarshal(output, &fp); err != nil {
+		return nil, fmt.Errorf("failed to decode fpcalc output: %w", err)
+	}
+	if fp.Value == "" {
+		return nil, fmt.Errorf("empty fingerprint for %s", path)
+	}
+	return &fp, nil
+}
+
+// AcoustIDMatch é uma gravação do MusicBrainz associada à impressão digital.
+type AcoustIDMatch struct {
+	RecordingID string  `json:"recording_id"`
+	Score       float64 `json:"score"`
+}
+
+// lookupAcoustID procura gravações do MusicBrainz para a impressão digital, da mais para
+// a menos provável. Sem ACOUSTID_API_KEY não há consulta.
+func lookupAcoustID(fp *Fingerprint) ([]AcoustIDMatch, error) {
+	if acoustIDKey == "" {
+		return nil, nil
+	}
+
+	form := url.Values{
+		"client":      {acoustIDKey},
+		"meta":        {"recordingids"},
+		"duration":    {fmt.Sprintf("%d", int(fp.DurationSec))},
+		"fingerprint": {fp.Value},
+	}
+	acoustIDLimiter.Wait()
+	resp, err := http.PostForm("https://api.acoustid.org/v2/lookup", form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Status  string `json:"status"`
+		Results []struct {
+			Score      float64 `json:"score"`
+			Recordings []struct {
+				ID string `json:"id"`
+			} `json:"recordings"`
+		} `json:"results"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.Status != "ok" {
+		if result.Error != nil {
+			return nil, fmt.Errorf("acoustid lookup failed: %s", result.Error.Message)
+		}
+		return nil, fmt.Errorf("acoustid lookup failed")
+	}
+
+	var matches []AcoustIDMatch
+	for _, r := range result.Results {
+		for _, rec := range r.Recordings {
+			matches = append(matches, AcoustIDMatch{RecordingID: rec.ID, Score: r.Score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	return matches, nil
+}
+
+// rawFingerprint calcula a impressão digital não comprimida (uma palavra de 32 bits a
+// cada ~0,12s), usada para comparar arquivos entre si.
+func rawFingerprint(path string) ([]uint32, float64, error) {
+	output, err := exec.Command("fpcalc", "-raw", "-json", path).Output()
+	if err != nil {
+		return nil, 0, fmt.Errorf("fpcalc failed for %s: %w", path, err)
+	}
+	var raw struct {
+		Duration    float64  `json:"duration"`
+		Fingerprint []uint32 `json:"fingerprint"`
+	}
+	if err := json.Unmarshal(output, &raw); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode fpcalc output: %w", err)
+	}
+	if len(raw.Fingerprint) == 0 {
+		return nil, 0, fmt.Errorf("empty fingerprint for %s", path)
+	}
+	return raw.Fingerprint, raw.Duration, nil
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// youTubeIDPattern encontra a URL do vídeo que o yt-dlp grava nas tags (purl/comment).
+var youTubeIDPattern = regexp.MustCompile(`(?:youtube\.com/watch\?v=|youtu\.be/|music\.youtube\.com/watch\?v=)([A-Za-z0-9_-]{11})`)
+
+// ImportRequest é o corpo aceito por POST /library/import. Path restringe a importação
+// a uma pasta da biblioteca; Fingerprint habilita a identificação pelo AcoustID para
+// arquivos sem tags suficientes.
+type ImportRequest struct {
+	Path        string `json:"path,omitempty"`
+	Fingerprint *bool  `json:"fingerprint,omitempty"`
+}
+
+// ImportResult resume a importação de uma pasta existente.
+type ImportResult struct {
+	Scanned        int      `json:"scanned"`
+	AlreadyIndexed int      `json:"already_indexed"`
+	Linked         int      `json:"linked"`
+	Unmatched      []string `json:"unmatched,omitempty"`
+	Errors         []string `json:"errors,omitempty"`
+}
+
+// importBatchSize é quantos arquivos associados a importação acumula antes de gravar o
+// índice de downloads.
+const importBatchSize = 100
+
+// importLink é a faixa de origem encontrada para um arquivo importado.
+type importLink struct {
+	Platform string
+	SourceID string
+	Title    string
+	Method   string
+}
+
+// searchSpotifyByISRC procura a faixa do Spotify com o ISRC informado.
+func searchSpotifyByISRC(isrc string) (*TrackMetadata, error) {
+	var result struct {
+		Tracks struct {
+			Items []spotifyTrackObject `json:"items"`
+		} `json:"tracks"`
+	}
+	endpoint := fmt.Sprintf("https://api.spotify.com/v1/search?q=%s&type=track&limit=1", url.QueryEscape("isrc:"+isrc))
+	if err := spotifyGet(endpoint, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Tracks.Items) == 0 {
+		return nil, nil
+	}
+	return spotifyTrackToMetadata(result.Tracks.Items[0]), nil
+}
+
+// linkImportedFile tenta descobrir a faixa de origem de um arquivo, do sinal mais
+// confiável ao menos: URL do YouTube nas tags, ISRC, busca por artista/título e, por
+// fim, a impressão digital no AcoustID.
+func linkImportedFile(rel string, useFingerprint bool) (*importLink, error) {
+	probe, err := probeAudio(absLibraryPath(rel))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range []string{"purl", "comment", "description", "synopsis"} {
+		if m := youTubeIDPattern.FindStringSubmatch(probe.Tags[key]); m != nil {
+			return &importLink{Platform: "youtube", SourceID: m[1], Title: probe.Tags["title"], Method: "tags"}, nil
+		}
+	}
+
+	isrc := probe.Tags["isrc"]
+	if isrc == "" {
+		isrc = probe.Tags["tsrc"]
+	}
+	if isrc != "" {
+		meta, err := searchSpotifyByISRC(isrc)
+		if err != nil {
+			log.WithError(err).Warnf("Failed to search ISRC %s", isrc)
+		} else if meta != nil {
+			return &importLink{Platform: "spotify", SourceID: meta.SourceID, Title: meta.Title, Method: "isrc"}, nil
+		}
+	}
+
+	if title := probe.Tags["title"]; title != "" {
+		parsed := ParsedTitle{Artist: probe.Tags["artist"], Title: title}
+		if link := searchSpotifyLink(parsed, probe.DurationSec, "search"); link != nil {
+			return link, nil
+		}
+	}
+
+	if !useFingerprint || acoustIDKey == "" {
+		return nil, nil
+	}
+	fp, err := fingerprintAudio(absLibraryPath(rel))
+	if err != nil {
+		return nil, err
+	}
+	matches, err := lookupAcoustID(fp)
+	if err != nil || len(matches) == 0 {
+		return nil, err
+	}
+	recording, err := getMusicBrainzRecordingMetadata(matches[0].RecordingID)
+	if err != nil {
+		return nil, err
+	}
+	if recording.ISRC != "" {
+		if meta, err := searchSpotifyByISRC(recording.ISRC); err == nil && meta != nil {
+			return &importLink{Platform: "spotify", SourceID: meta.SourceID, Title: meta.Title, Method: "fingerprint"}, nil
+		}
+	}
+	// A deduplicação só consulta faixas do Spotify e do YouTube: uma gravação que só existe
+	// no MusicBrainz não evitaria nenhum download, então o arquivo fica sem associação
+	if recording.Title != "" && len(recording.Artists) > 0 {
+		parsed := ParsedTitle{Artist: recording.Artists[0], Title: recording.Title}
+		return searchSpotifyLink(parsed, probe.DurationSec, "fingerprint"), nil
+	}
+	return nil, nil
+}
+
+// searchSpotifyLink busca a faixa no Spotify pelo artista/título e devolve o melhor
+// candidato acima de matchMinScore.
+func searchSpotifyLink(parsed ParsedTitle, durationSec float64, method string) *importLink {
+	for _, candidate := range findMatchCandidates(parsed, durationSec) {
+		if candidate.Score < matchMinScore {
+			break
+		}
+		if candidate.Metadata.Source == "spotify" {
+			return &importLink{
+				Platform: "spotify", SourceID: candidate.Metadata.SourceID,
+				Title: candidate.Metadata.Title, Method: method,
+			}
+		}
+	}
+	return nil
+}
+
+// runImport percorre a pasta, ignora os arquivos já indexados e registra no índice de
+// downloads os que puderem ser associados a uma faixa de origem.
+func runImport(job *DownloadJob, dir string, useFingerprint bool, emit func(string)) error {
+	updateJob(job, func(j *DownloadJob) { j.Status = jobRunning })
+
+	indexed := indexedFiles()
+	var files []string
+	err := filepath.WalkDir(absLibraryPath(dir), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if skip := skipHiddenDir(absLibraryPath(dir), p, d); skip != nil {
+			return skip
+		}
+		if d.IsDir() || !isAudioFile(p) || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		if rel, err := filepath.Rel(libraryDir, p); err == nil {
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to walk %s: %w", dir, err)
+	}
+
+	result := &ImportResult{}
+	updateJob(job, func(j *DownloadJob) {
+		j.Progress = &JobProgress{Total: len(files)}
+		j.Result.Import = result
+	})
+	emit(fmt.Sprintf("Found %d audio files", len(files)))
+
+	// O índice é gravado em lotes, em vez de reescrito a cada arquivo; uma importação
+	// interrompida perde no máximo o último lote
+	var linked []IndexEntry
+	for i, rel := range files {
+		updateJob(job, func(j *DownloadJob) {
+			j.Progress.Done = i
+			result.Scanned++
+		})
+		if indexed[rel] {
+			updateJob(job, func(j *DownloadJob) { result.AlreadyIndexed++ })
+			continue
+		}
+
+		link, err := linkImportedFile(rel, useFingerprint)
+		if err != nil {
+			log.WithError(err).Warnf("Failed to import %s", rel)
+			updateJob(job, func(j *DownloadJob) { result.Errors = append(result.Errors, rel) })
+			continue
+		}
+		if link == nil {
+			updateJob(job, func(j *DownloadJob) { result.Unmatched = append(result.Unmatched, rel) })
+			continue
+		}
+
+		linked = append(linked, IndexEntry{
+			Platform: link.Platform, SourceID: link.SourceID, File: rel, Title: link.Title, DownloadedAt: job.CreatedAt,
+		})
+		addJobTrack(job, &JobTrack{File: rel, SourceID: link.SourceID, SourceTitle: link.Title})
+		updateJob(job, func(j *DownloadJob) { result.Linked++ })
+		emit(fmt.Sprintf("Linked %s to %s:%s (%s) [%d/%d]", rel, link.Platform, link.SourceID, link.Method, i+1, len(files)))
+		if len(linked) >= importBatchSize {
+			recordDownloads(linked)
+			linked = linked[:0]
+		}
+	}
+
+	recordDownloads(linked)
+	updateJob(job, func(j *DownloadJob) { j.Progress.Done = len(files) })
+	snapshot := snapshotJob(job)
+	emit(fmt.Sprintf(
+		"Import finished: %d linked, %d already indexed, %d unmatched, %d errors",
+		snapshot.Result.Import.Linked, snapshot.Result.Import.AlreadyIndexed,
+		len(snapshot.Result.Import.Unmatched), len(snapshot.Result.Import.Errors),
+	))
+	return nil
+}
+
+// importLibrary inicia em segundo plano a importação de uma pasta existente da
+// biblioteca. O andamento é acompanhado em GET /jobs/:id.
+func importLibrary(c *gin.Context) {
+	var request ImportRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			log.WithError(err).Error("Invalid request body")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	dir := "."
+	if request.Path != "" {
+		dir = path.Clean(strings.ReplaceAll(request.Path, "\\", "/"))
+		if path.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, "../") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "path must be relative to the library"})
+			return
+		}
+	}
+	useFingerprint := true
+	if request.Fingerprint != nil {
+		useFingerprint = *request.Fingerprint
+	}
+
+	job := createJob("library://" + dir)
+	updateJob(job, func(j *DownloadJob) { j.Platform = "library" })
+	emit := logEmitter(job.URL)
+	go func() {
+		finishJob(job, runImport(job, dir, useFingerprint, emit))
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{"job_id": job.ID})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestYouTubeIDPattern(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"Downloaded from https://youtu.be/dQw4w9WgXcQ?t=10", "dQw4w9WgXcQ"},
+		{"https://music.youtube.com/watch?v=a_b-C1d2E3f&list=RD", "a_b-C1d2E3f"},
+		{"https://www.youtube.com/channel/UC123", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if m := youTubeIDPattern.FindStringSubmatch(tt.tag); m != nil {
+			got = m[1]
+		}
+		if got != tt.want {
+			t.Errorf("youTubeIDPattern(%q) = %q, want %q", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestRunImportSkipsIndexedFiles(t *testing.T) {
+	useTestLibrary(t)
+	useTestDataDir(t)
+	writeTestFile(t, absLibraryPath("Band/Album/01 - Song.mp3"), "audio")
+	writeTestFile(t, absLibraryPath("Band/Album/.tagging-01 - Song.mp3"), "temp")
+	writeTestFile(t, absLibraryPath("Band/Album/cover.jpg"), "image")
+	recordDownloads([]IndexEntry{{Platform: "spotify", SourceID: "sp1", File: "Band/Album/01 - Song.mp3"}})
+
+	job := &DownloadJob{ID: "import1", Platform: "library", CreatedAt: time.Now()}
+	if err := runImport(job, ".", false, func(string) {}); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := snapshotJob(job)
+	result := snapshot.Result.Import
+	if result == nil || result.Scanned != 1 || result.AlreadyIndexed != 1 || result.Linked != 0 {
+		t.Errorf("import result = %+v, want one already indexed file", result)
+	}
+	if snapshot.Progress == nil || snapshot.Progress.Done != 1 || snapshot.Progress.Total != 1 {
+		t.Errorf("progress = %+v, want 1/1", snapshot.Progress)
+	}
+}
+
+func TestImportLibraryRejectsPathsOutsideLibrary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/library/import", importLibrary)
+	for _, body := range []string{`{"path":"../outside"}`, `{"path":"/etc"}`, `{"path":"..\\outside"}`, `{`} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/library/import", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("body %s: status = %d, want %d", body, w.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dataDir guarda o estado persistente do serviço (índice de downloads etc).
+var dataDir = getEnv("DATA_DIR", "/data")
+
+// loadJSONFile lê um arquivo de estado em DATA_DIR; arquivo inexistente não é erro.
+func loadJSONFile(name string, v interface{}) error {
+	data, err := os.ReadFile(filepath.Join(dataDir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// saveJSONFile grava um arquivo de estado em DATA_DIR de forma atômica.
+func saveJSONFile(name string, v interface{}) error {
+	return writeJSONFile(name, v, 0o644)
+}
+
+// saveSecretJSONFile grava um arquivo com credenciais (tokens, client secrets) legível
+// só pelo usuário do serviço.
+func saveSecretJSONFile(name string, v interface{}) error {
+	return writeJSONFile(name, v, 0o600)
+}
+
+// writeJSONFile grava o JSON em um temporário com a permissão pedida e o renomeia por
+// cima do arquivo final.
+func writeJSONFile(name string, v interface{}, perm fs.FileMode) error {
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dataDir, name)
+	tmp := path + ".tmp"
+	// Um temporário que sobrou manteria a permissão antiga: WriteFile só a aplica ao criar
+	if err := os.Remove(tmp); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// IndexEntry registra qual arquivo da biblioteca corresponde a uma faixa de origem.
+type IndexEntry struct {
+	Platform     string    `json:"platform"`
+	SourceID     string    `json:"source_id"`
+	File         string    `json:"file"`
+	Title        string    `json:"title,omitempty"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+}
+
+// downloadIndex é o índice de faixas já baixadas, chaveado por "plataforma:id".
+// É a base da deduplicação e da regeneração de playlists.
+var (
+	downloadIndex       = make(map[string]IndexEntry)
+	downloadIndexMu     sync.Mutex
+	downloadIndexLoaded bool
+)
+
+const downloadIndexFile = "download-index.json"
+
+func indexKey(platform, sourceID string) string {
+	return platform + ":" + sourceID
+}
+
+// ensureDownloadIndex carrega o índice do disco na primeira utilização.
+// Deve ser chamada com downloadIndexMu travado.
+func ensureDownloadIndex() {
+	if downloadIndexLoaded {
+		return
+	}
+	downloadIndexLoaded = true
+	if err := loadJSONFile(downloadIndexFile, &downloadIndex); err != nil {
+		log.WithError(err).Error("Failed to load download index")
+	}
+}
+
+// recordDownloads adiciona entradas ao índice e persiste o resultado.
+func recordDownloads(entries []IndexEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	downloadIndexMu.Lock()
+	defer downloadIndexMu.Unlock()
+	ensureDownloadIndex()
+
+	for _, entry := range entries {
+		downloadIndex[indexKey(entry.Platform, entry.SourceID)] = entry
+	}
+	if err := saveJSONFile(downloadIndexFile, downloadIndex); err != nil {
+		log.WithError(err).Error("Failed to save download index")
+	}
+}
+
+// lookupDownload retorna o arquivo já baixado para a faixa de origem, se ele ainda existir.
+func lookupDownload(platform, sourceID string) (IndexEntry, bool) {
+	downloadIndexMu.Lock()
+	defer downloadIndexMu.Unlock()
+	ensureDownloadIndex()
+
+	entry, ok := downloadIndex[indexKey(platform, sourceID)]
+	if !ok {
+		return IndexEntry{}, false
+	}
+	if _, err := os.Stat(absLibraryPath(entry.File)); err != nil {
+		return IndexEntry{}, false
+	}
+	return entry, true
+}
+
+// linkJobTracks descobre a faixa de origem de cada arquivo do job ainda sem SourceID
+// (caso dos arquivos do spotDL), comparando ISRC e tags com as faixas da coleção.
+func linkJobTracks(job *DownloadJob, entries []PlaylistEntry) {
+	// Um único arquivo de uma URL de faixa é a própria faixa
+	if job.Platform == "spotify" && len(job.Tracks) == 1 && job.Tracks[0].SourceID == "" {
+		if itemType, itemID, err := extractSpotifyID(job.URL); err == nil && itemType == "track" {
+			updateJob(job, func(j *DownloadJob) { j.Tracks[0].SourceID = itemID })
+			return
+		}
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	isrcs := make(map[string]string)
+	for _, entry := range entries {
+		if entry.ISRC != "" {
+			isrcs[strings.ToUpper(entry.ISRC)] = entry.SourceID
+		}
+	}
+
+	for _, track := range job.Tracks {
+		if track.SourceID != "" {
+			continue
+		}
+		probe, err := probeAudio(absLibraryPath(track.File))
+		if err != nil {
+			log.WithError(err).Warnf("Failed to read tags of %s", track.File)
+			continue
+		}
+
+		sourceID := isrcs[strings.ToUpper(probe.Tags["isrc"])]
+		if sourceID == "" {
+			parsed := ParsedTitle{Title: probe.Tags["title"], Artist: probe.Tags["artist"]}
+			bestScore := 0.0
+			for _, entry := range entries {
+				candidate := scoreCandidate(parsed, probe.DurationSec, TrackMetadata{
+					Title: entry.Title, Artists: entry.Artists, DurationMs: entry.DurationMs,
+				})
+				if candidate.Score > bestScore && candidate.Score >= matchMinScore {
+					sourceID, bestScore = entry.SourceID, candidate.Score
+				}
+			}
+		}
+		if sourceID != "" {
+			updateJob(job, func(j *DownloadJob) { track.SourceID = sourceID })
+		}
+	}
+}
+
+// indexJobTracks grava no índice os arquivos do job já associados a uma faixa de origem.
+func indexJobTracks(job *DownloadJob) {
+	snapshot := snapshotJob(job)
+	var entries []IndexEntry
+	now := time.Now()
+	for _, track := range snapshot.Tracks {
+		if track.SourceID == "" {
+			continue
+		}
+		entry := IndexEntry{
+			Platform:     snapshot.Platform,
+			SourceID:     track.SourceID,
+			File:         track.File,
+			Title:        track.SourceTitle,
+			DownloadedAt: now,
+		}
+		if track.Metadata != nil {
+			entry.Title = track.Metadata.Title
+		}
+		entries = append(entries, entry)
+	}
+	recordDownloads(entries)
+}
+
+// resolveEntryFile encontra o arquivo baixado para uma faixa da coleção: primeiro entre
+// os arquivos do job, depois no índice (downloads de sincronizações anteriores).
+func resolveEntryFile(job *DownloadJob, entry PlaylistEntry) (string, bool) {
+	for _, track := range job.Tracks {
+		if track.SourceID == entry.SourceID {
+			return track.File, true
+		}
+	}
+	if indexed, ok := lookupDownload(entry.Platform, entry.SourceID); ok {
+		return indexed.File, true
+	}
+	return "", false
+}
+
+// describeEntry formata uma faixa como "Artista - Título" para logs e playlists.
+func describeEntry(entry PlaylistEntry) string {
+	if len(entry.Artists) == 0 {
+		return entry.Title
+	}
+	return fmt.Sprintf("%s - %s", strings.Join(entry.Artists, ", "), entry.Title)
+}
+
+// fileIndexedByOthers indica se outra faixa de origem, além de platform:sourceID, aponta
+// para o arquivo no índice.
+func fileIndexedByOthers(file, platform, sourceID string) bool {
+	downloadIndexMu.Lock()
+	defer downloadIndexMu.Unlock()
+	ensureDownloadIndex()
+
+	own := indexKey(platform, sourceID)
+	for key, entry := range downloadIndex {
+		if entry.File == file && key != own {
+			return true
+		}
+	}
+	return false
+}
+
+// forgetDownloadFile remove do índice todas as faixas de origem que apontam para o arquivo.
+func forgetDownloadFile(file string) {
+	downloadIndexMu.Lock()
+	defer downloadIndexMu.Unlock()
+	ensureDownloadIndex()
+
+	changed := false
+	for key, entry := range downloadIndex {
+		if entry.File == file {
+			delete(downloadIndex, key)
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	if err := saveJSONFile(downloadIndexFile, downloadIndex); err != nil {
+		log.WithError(err).Error("Failed to save download index")
+	}
+}
+
+// moveDownloadFile atualiza o índice após um arquivo ser renomeado ou movido.
+func moveDownloadFile(from, to string) {
+	downloadIndexMu.Lock()
+	defer downloadIndexMu.Unlock()
+	ensureDownloadIndex()
+
+	changed := false
+	for key, entry := range downloadIndex {
+		if entry.File == from {
+			entry.File = to
+			downloadIndex[key] = entry
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	if err := saveJSONFile(downloadIndexFile, downloadIndex); err != nil {
+		log.WithError(err).Error("Failed to save download index")
+	}
+}
+
+// indexedFiles retorna o conjunto de arquivos já associados a alguma faixa de origem.
+func indexedFiles() map[string]bool {
+	downloadIndexMu.Lock()
+	defer downloadIndexMu.Unlock()
+	ensureDownloadIndex()
+
+	files := make(map[string]bool, len(downloadIndex))
+	for _, entry := range downloadIndex {
+		files[entry.File] = true
+	}
+	return files
+}
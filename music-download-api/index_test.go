@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useTestDataDir aponta DATA_DIR para uma pasta temporária e começa o teste com o índice
+// de downloads vazio.
+func useTestDataDir(t *testing.T) {
+	t.Helper()
+	previousDir := dataDir
+	dataDir = t.TempDir()
+	downloadIndexMu.Lock()
+	previousIndex, previousLoaded := downloadIndex, downloadIndexLoaded
+	downloadIndex, downloadIndexLoaded = make(map[string]IndexEntry), true
+	downloadIndexMu.Unlock()
+	t.Cleanup(func() {
+		dataDir = previousDir
+		downloadIndexMu.Lock()
+		downloadIndex, downloadIndexLoaded = previousIndex, previousLoaded
+		downloadIndexMu.Unlock()
+	})
+}
+
+func TestSaveJSONFilePermissions(t *testing.T) {
+	tests := []struct {
+		name string
+		save func(string, interface{}) error
+		want os.FileMode
+	}{
+		{"state file", saveJSONFile, 0o644},
+		{"secret file", saveSecretJSONFile, 0o600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestDataDir(t)
+			path := filepath.Join(dataDir, "state.json")
+			// Arquivo antigo e temporário abandonado com permissões mais abertas
+			writeTestFile(t, path, "{}")
+			writeTestFile(t, path+".tmp", "{}")
+			if err := os.Chmod(path+".tmp", 0o666); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.save("state.json", map[string]string{"token": "secret"}); err != nil {
+				t.Fatal(err)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.Mode().Perm(); got != tt.want {
+				t.Errorf("mode = %o, want %o", got, tt.want)
+			}
+
+			var loaded map[string]string
+			if err := loadJSONFile("state.json", &loaded); err != nil || loaded["token"] != "secret" {
+				t.Errorf("loadJSONFile = %v, %v", loaded, err)
+			}
+		})
+	}
+}
+
+func TestLookupDownload(t *testing.T) {
+	useTestLibrary(t)
+	useTestDataDir(t)
+	writeTestFile(t, absLibraryPath("Band/Album/01 - Song.mp3"), "audio")
+	recordDownloads([]IndexEntry{
+		{Platform: "spotify", SourceID: "sp1", File: "Band/Album/01 - Song.mp3"},
+		{Platform: "spotify", SourceID: "sp2", File: "Band/Album/02 - Deleted.mp3"},
+	})
+
+	if entry, ok := lookupDownload("spotify", "sp1"); !ok || entry.File != "Band/Album/01 - Song.mp3" {
+		t.Errorf("lookupDownload(sp1) = %+v, %v; want the indexed file", entry, ok)
+	}
+	// Um arquivo apagado da biblioteca não conta como baixado
+	if _, ok := lookupDownload("spotify", "sp2"); ok {
+		t.Error("lookupDownload(sp2) found a file that no longer exists")
+	}
+	if _, ok := lookupDownload("youtube", "sp1"); ok {
+		t.Error("lookupDownload matched an ID from another platform")
+	}
+
+	var saved map[string]IndexEntry
+	if err := loadJSONFile(downloadIndexFile, &saved); err != nil || len(saved) != 2 {
+		t.Errorf("saved index = %v (%v), want 2 entries", saved, err)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Estados possíveis de um job de download.
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobCompleted = "completed"
+	jobFailed    = "failed"
+)
+
+// JobResult acumula o que as etapas de pós-processamento fizeram com os arquivos do job.
+type JobResult struct {
+	Tagged    []string `json:"tagged,omitempty"`
+	TagErrors []string `json:"tag_errors,omitempty"`
+
+	Lyrics        []string `json:"lyrics,omitempty"`
+	LyricsMissing []string `json:"lyrics_missing,omitempty"`
+	LyricsErrors  []string `json:"lyrics_errors,omitempty"`
+
+	Loudness []LoudnessResult `json:"loudness,omitempty"`
+
+	Scan *ScanResult `json:"scan,omitempty"`
+
+	NavidromePlaylist *PlaylistSyncResult `json:"navidrome_playlist,omitempty"`
+
+	M3U *M3UResult `json:"m3u,omitempty"`
+
+	Import *ImportResult `json:"import,omitempty"`
+}
+
+// JobProgress indica quantos itens de um job longo (como a importação) já foram processados.
+type JobProgress struct {
+	Total int `json:"total"`
+	Done  int `json:"done"`
+}
+
+// JobCollection guarda o nome e a ordem original das faixas de um job de playlist/álbum.
+type JobCollection struct {
+	Name    string          `json:"name"`
+	Entries []PlaylistEntry `json:"entries"`
+}
+
+// JobTrack é um arquivo gravado por um job, com os dados da origem usados no casamento
+// de metadados e as tags efetivamente aplicadas. Skipped marca a faixa que já existia na
+// biblioteca (on_conflict=skip): File aponta para o arquivo existente, que o
+// pós-processamento não altera.
+type JobTrack struct {
+	File         string         `json:"file"`
+	Skipped      bool           `json:"skipped,omitempty"`
+	SourceID     string         `json:"source_id,omitempty"`
+	SourceTitle  string         `json:"source_title,omitempty"`
+	SourceArtist string         `json:"source_artist,omitempty"`
+	SourceTrack  string         `json:"source_track,omitempty"`
+	Channel      string         `json:"channel,omitempty"`
+	DurationSec  float64        `json:"duration_sec,omitempty"`
+	Parsed       *ParsedTitle   `json:"parsed,omitempty"`
+	Metadata     *TrackMetadata `json:"metadata,omitempty"`
+	MatchScore   float64        `json:"match_score,omitempty"`
+	ReviewID     string         `json:"review_id,omitempty"`
+}
+
+// DownloadJob representa o download de uma única URL e tudo que foi feito após ele.
+// Files guarda caminhos relativos à raiz da biblioteca (LIBRARY_DIR). NavidromePlaylist
+// pede que a playlist de origem seja espelhada no Navidrome após a varredura. SubJobs
+// lista os jobs criados para cada álbum quando a URL é um artista.
+type DownloadJob struct {
+	ID                string         `json:"id"`
+	URL               string         `json:"url"`
+	Platform          string         `json:"platform"`
+	Status            string         `json:"status"`
+	Files             []string       `json:"files,omitempty"`
+	Tracks            []*JobTrack    `json:"tracks,omitempty"`
+	Metadata          *TrackMetadata `json:"metadata,omitempty"`
+	Collection        *JobCollection `json:"collection,omitempty"`
+	NavidromePlaylist bool           `json:"navidrome_playlist,omitempty"`
+	SubJobs           []string       `json:"sub_jobs,omitempty"`
+	Progress          *JobProgress   `json:"progress,omitempty"`
+	Result            JobResult      `json:"result"`
+	Error             string         `json:"error,omitempty"`
+	CreatedAt         time.Time      `json:"created_at"`
+	FinishedAt        *time.Time     `json:"finished_at,omitempty"`
+}
+
+var (
+	jobs   = make(map[string]*DownloadJob)
+	jobsMu sync.Mutex
+)
+
+// newJobID gera um identificador aleatório curto para jobs e itens relacionados.
+func newJobID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(buf)
+}
+
+// createJob registra um novo job na fila para a URL informada.
+func createJob(urlStr string) *DownloadJob {
+	platform := "youtube"
+	if isSpotifyURL(urlStr) {
+		platform = "spotify"
+	}
+
+	job := &DownloadJob{
+		ID:        newJobID(),
+		URL:       urlStr,
+		Platform:  platform,
+		Status:    jobQueued,
+		CreatedAt: time.Now(),
+	}
+
+	jobsMu.Lock()
+	jobs[job.ID] = job
+	jobsMu.Unlock()
+	return job
+}
+
+// updateJob aplica fn ao job sob o mutex do registro.
+func updateJob(job *DownloadJob, fn func(j *DownloadJob)) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	fn(job)
+}
+
+// finishJob marca o job como concluído ou falho.
+func finishJob(job *DownloadJob, err error) {
+	updateJob(job, func(j *DownloadJob) {
+		now := time.Now()
+		j.FinishedAt = &now
+		if err != nil {
+			j.Status = jobFailed
+			j.Error = err.Error()
+			return
+		}
+		j.Status = jobCompleted
+	})
+	invalidateLibraryIndex()
+}
+
+// snapshotJob retorna uma cópia do job segura para serializar fora do mutex.
+func snapshotJob(job *DownloadJob) DownloadJob {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	return copyJob(job)
+}
+
+// copyJob copia o job e suas faixas; deve ser chamada com jobsMu travado.
+func copyJob(job *DownloadJob) DownloadJob {
+	copied := *job
+	copied.Files = append([]string(nil), job.Files...)
+	copied.SubJobs = append([]string(nil), job.SubJobs...)
+	if job.Progress != nil {
+		progress := *job.Progress
+		copied.Progress = &progress
+	}
+	// Varredura, playlist e M3U são alterados no lugar pelas etapas finais do job
+	if job.Result.Scan != nil {
+		scan := *job.Result.Scan
+		copied.Result.Scan = &scan
+	}
+	if job.Result.NavidromePlaylist != nil {
+		playlist := *job.Result.NavidromePlaylist
+		playlist.Missing = append([]string(nil), playlist.Missing...)
+		copied.Result.NavidromePlaylist = &playlist
+	}
+	if job.Result.M3U != nil {
+		m3u := *job.Result.M3U
+		m3u.Missing = append([]string(nil), m3u.Missing...)
+		copied.Result.M3U = &m3u
+	}
+	if job.Result.Import != nil {
+		result := *job.Result.Import
+		result.Unmatched = append([]string(nil), result.Unmatched...)
+		result.Errors = append([]string(nil), result.Errors...)
+		copied.Result.Import = &result
+	}
+	copied.Tracks = make([]*JobTrack, len(job.Tracks))
+	for i, track := range job.Tracks {
+		t := *track
+		copied.Tracks[i] = &t
+	}
+	return copied
+}
+
+// downloadedTracks devolve as faixas do job que ele de fato gravou, sem as puladas por
+// on_conflict=skip. job deve ser uma cópia obtida com snapshotJob.
+func downloadedTracks(job DownloadJob) []*JobTrack {
+	tracks := make([]*JobTrack, 0, len(job.Tracks))
+	for _, track := range job.Tracks {
+		if !track.Skipped {
+			tracks = append(tracks, track)
+		}
+	}
+	return tracks
+}
+
+// addJobTrack registra um arquivo gravado pelo job.
+func addJobTrack(job *DownloadJob, track *JobTrack) {
+	updateJob(job, func(j *DownloadJob) {
+		j.Files = append(j.Files, track.File)
+		j.Tracks = append(j.Tracks, track)
+	})
+}
+
+// listJobs retorna os jobs do mais recente para o mais antigo.
+func listJobs(c *gin.Context) {
+	jobsMu.Lock()
+	list := make([]DownloadJob, 0, len(jobs))
+	for _, job := range jobs {
+		list = append(list, copyJob(job))
+	}
+	jobsMu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+
+	c.JSON(http.StatusOK, gin.H{"jobs": list})
+}
+
+// getJob retorna um job específico pelo ID.
+func getJob(c *gin.Context) {
+	jobsMu.Lock()
+	job, ok := jobs[c.Param("id")]
+	jobsMu.Unlock()
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshotJob(job))
+}
+
+// logEmitter repassa a saída de um job executado em segundo plano para o log do serviço.
+func logEmitter(urlStr string) func(string) {
+	return func(message string) {
+		log.Infof("[%s] %s", urlStr, message)
+	}
+}
+
+// runJob cria e executa de forma síncrona um job de download fora de uma requisição
+// HTTP (assinaturas, lançamentos de artistas etc).
+func runJob(urlStr string, opts downloadOptions) *DownloadJob {
+	job := createJob(urlStr)
+	finishJob(job, runDownload(job, opts, logEmitter(urlStr)))
+	return job
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCopyJobDetachesResults(t *testing.T) {
+	job := &DownloadJob{
+		ID: "copy-job",
+		Result: JobResult{
+			Scan:              &ScanResult{RequestedAt: time.Now()},
+			NavidromePlaylist: &PlaylistSyncResult{Name: "Mix", Missing: []string{"a"}},
+			M3U:               &M3UResult{File: "Playlists/Mix.m3u8", Missing: []string{"b"}},
+		},
+		Tracks: []*JobTrack{{File: "a.mp3"}},
+	}
+	copied := copyJob(job)
+
+	now := time.Now()
+	job.Result.Scan.StartedAt = &now
+	job.Result.Scan.Error = "failed"
+	job.Result.NavidromePlaylist.Missing[0] = "changed"
+	job.Result.M3U.Missing[0] = "changed"
+	job.Tracks[0].File = "changed.mp3"
+
+	if copied.Result.Scan.StartedAt != nil || copied.Result.Scan.Error != "" {
+		t.Errorf("copied scan = %+v, want it unchanged", copied.Result.Scan)
+	}
+	if copied.Result.NavidromePlaylist.Missing[0] != "a" || copied.Result.M3U.Missing[0] != "b" {
+		t.Errorf("copied results share slices with the job: %+v, %+v", copied.Result.NavidromePlaylist, copied.Result.M3U)
+	}
+	if copied.Tracks[0].File != "a.mp3" {
+		t.Errorf("copied track = %+v, want it unchanged", copied.Tracks[0])
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// libraryDir é onde a pasta de downloads compartilhada está montada neste container.
+var libraryDir = getEnv("LIBRARY_DIR", "/downloads")
+
+// audioExtensions lista os formatos que o serviço reconhece como áudio.
+var audioExtensions = map[string]bool{
+	".mp3": true, ".m4a": true, ".mp4": true, ".aac": true, ".flac": true,
+	".ogg": true, ".opus": true, ".wav": true, ".webm": true,
+}
+
+func isAudioFile(path string) bool {
+	return audioExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// containerToLibraryPath converte um caminho visto pelo yt-dlp ou spotDL em um
+// caminho relativo à raiz da biblioteca.
+func containerToLibraryPath(containerPath string) string {
+	for _, root := range []string{ytDlpRoot, spotDLRoot} {
+		if strings.HasPrefix(containerPath, root+"/") {
+			return strings.TrimPrefix(containerPath, root+"/")
+		}
+	}
+	return strings.TrimPrefix(containerPath, "/")
+}
+
+// absLibraryPath resolve um caminho relativo da biblioteca para o caminho local.
+func absLibraryPath(rel string) string {
+	return filepath.Join(libraryDir, filepath.FromSlash(rel))
+}
+
+// stagingRoot é a pasta oculta da biblioteca onde os jobs do spotDL (e os do yt-dlp com
+// on_conflict=rename) gravam antes de os arquivos irem para o lugar definitivo. Pastas ocultas são ignoradas pelas varreduras.
+const stagingRoot = ".staging"
+
+// stagingDir é a pasta temporária de um job, relativa à raiz da biblioteca.
+func stagingDir(jobID string) string {
+	return stagingRoot + "/" + jobID
+}
+
+// skipHiddenDir interrompe a varredura em pastas ocultas abaixo de root, como a área
+// temporária dos jobs.
+func skipHiddenDir(root, path string, d fs.DirEntry) error {
+	if d != nil && d.IsDir() && path != root && strings.HasPrefix(d.Name(), ".") {
+		return fs.SkipDir
+	}
+	return nil
+}
+
+// stagedFile associa um arquivo gravado na pasta temporária ao caminho final na
+// biblioteca, ambos relativos à raiz da biblioteca. Skipped indica que o destino já
+// existia e foi mantido (on_conflict=skip): o arquivo em To não foi gravado pelo job.
+type stagedFile struct {
+	From    string
+	To      string
+	Skipped bool
+}
+
+// availableLibraryPath devolve rel ou, se já existir, o primeiro "nome (n).ext" livre.
+func availableLibraryPath(rel string) string {
+	ext := filepath.Ext(rel)
+	base := strings.TrimSuffix(rel, ext)
+	candidate := rel
+	for n := 2; ; n++ {
+		if _, err := os.Stat(absLibraryPath(candidate)); errors.Is(err, fs.ErrNotExist) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, n, ext)
+	}
+}
+
+// moveStagedFiles move os arquivos gravados na pasta temporária do job para o caminho
+// equivalente na biblioteca, aplicando a política de colisão, e devolve os arquivos de
+// áudio movidos. Assim o job só enxerga o que ele mesmo gravou, mesmo com outros jobs
+// alterando a biblioteca ao mesmo tempo.
+func moveStagedFiles(jobID, conflict string) ([]stagedFile, error) {
+	staging := stagingDir(jobID)
+	root := absLibraryPath(staging)
+
+	var moved []stagedFile
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == root {
+			return nil
+		}
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		// Com skip, um arquivo que já existe na biblioteca é mantido e o job aponta para ele
+		from := staging + "/" + rel
+		if conflict == conflictRename {
+			rel = availableLibraryPath(rel)
+		}
+		target := absLibraryPath(rel)
+		_, statErr := os.Stat(target)
+		skipped := statErr == nil && conflict == conflictSkip
+		if !skipped {
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			if err := os.Rename(path, target); err != nil {
+				return err
+			}
+		}
+		if isAudioFile(path) {
+			moved = append(moved, stagedFile{From: from, To: rel, Skipped: skipped})
+		}
+		return nil
+	})
+	return moved, err
+}
+
+// AudioProbe é o resultado do ffprobe para um arquivo de áudio. As chaves de Tags
+// são normalizadas para minúsculas.
+type AudioProbe struct {
+	Format      string            `json:"format"`
+	DurationSec float64           `json:"duration_sec"`
+	BitRate     int               `json:"bit_rate"`
+	SampleRate  int               `json:"sample_rate,omitempty"`
+	Codec       string            `json:"codec,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+// probeAudio lê formato, duração, bitrate e tags de um arquivo com ffprobe.
+func probeAudio(path string) (*AudioProbe, error) {
+	cmd := exec.Command(
+		"ffprobe", "-v", "quiet", "-print_format", "json",
+		"-show_format", "-show_streams", path,
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed for %s: %w", path, err)
+	}
+
+	var raw struct {
+		Format struct {
+			FormatName string            `json:"format_name"`
+			Duration   string            `json:"duration"`
+			BitRate    string            `json:"bit_rate"`
+			Tags       map[string]string `json:"tags"`
+		} `json:"format"`
+		Streams []struct {
+			CodecType  string            `json:"codec_type"`
+			CodecName  string            `json:"codec_name"`
+			SampleRate string            `json:"sample_rate"`
+			Tags       map[string]string `json:"tags"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(output, &raw); err != nil {
+		return nil, fmt.Errorf("failed to decode ffprobe output: %w", err)
+	}
+
+	probe := &AudioProbe{
+		Format: strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."),
+		Tags:   make(map[string]string),
+	}
+	probe.DurationSec, _ = strconv.ParseFloat(raw.Format.Duration, 64)
+	probe.BitRate, _ = strconv.Atoi(raw.Format.BitRate)
+
+	// Em Ogg/Opus as tags ficam no stream de áudio, não no container
+	for _, stream := range raw.Streams {
+		if stream.CodecType != "audio" {
+			continue
+		}
+		probe.Codec = stream.CodecName
+		probe.SampleRate, _ = strconv.Atoi(stream.SampleRate)
+		for key, value := range stream.Tags {
+			probe.Tags[strings.ToLower(key)] = value
+		}
+		break
+	}
+	for key, value := range raw.Format.Tags {
+		probe.Tags[strings.ToLower(key)] = value
+	}
+
+	return probe, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// useTestLibrary aponta LIBRARY_DIR para uma pasta temporária durante o teste.
+func useTestLibrary(t *testing.T) {
+	t.Helper()
+	previous := libraryDir
+	libraryDir = t.TempDir()
+	t.Cleanup(func() { libraryDir = previous })
+}
+
+func TestMoveStagedFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		conflict string
+		want     string
+		wantTo   string
+		skipped  bool
+	}{
+		{"skip keeps the existing file", conflictSkip, "old", "Artist/Album/01 - Song.mp3", true},
+		{"overwrite replaces the existing file", conflictOverwrite, "new", "Artist/Album/01 - Song.mp3", false},
+		{"rename keeps both files", conflictRename, "old", "Artist/Album/01 - Song (2).mp3", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestLibrary(t)
+			staging := absLibraryPath(stagingDir("job1"))
+			writeTestFile(t, absLibraryPath("Artist/Album/01 - Song.mp3"), "old")
+			writeTestFile(t, filepath.Join(staging, "Artist/Album/01 - Song.mp3"), "new")
+			writeTestFile(t, filepath.Join(staging, "Artist/Album/02 - Other.mp3"), "other")
+			writeTestFile(t, filepath.Join(staging, "Artist/Album/02 - Other.lrc"), "lyrics")
+			// Arquivo de outro job gravado ao mesmo tempo: não pode ser atribuído a job1
+			writeTestFile(t, absLibraryPath("Someone/Else.mp3"), "foreign")
+
+			moved, err := moveStagedFiles("job1", tt.conflict)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []stagedFile{
+				{From: ".staging/job1/Artist/Album/01 - Song.mp3", To: tt.wantTo, Skipped: tt.skipped},
+				{From: ".staging/job1/Artist/Album/02 - Other.mp3", To: "Artist/Album/02 - Other.mp3"},
+			}
+			if len(moved) != len(want) {
+				t.Fatalf("moved = %v, want %v", moved, want)
+			}
+			for i := range want {
+				if moved[i] != want[i] {
+					t.Errorf("moved[%d] = %v, want %v", i, moved[i], want[i])
+				}
+			}
+
+			data, err := os.ReadFile(absLibraryPath("Artist/Album/01 - Song.mp3"))
+			if err != nil || string(data) != tt.want {
+				t.Errorf("existing file = %q (%v), want %q", data, err, tt.want)
+			}
+			if data, err := os.ReadFile(absLibraryPath(tt.wantTo)); err != nil || (tt.wantTo != "Artist/Album/01 - Song.mp3" && string(data) != "new") {
+				t.Errorf("new file at %s = %q (%v)", tt.wantTo, data, err)
+			}
+			if _, err := os.Stat(absLibraryPath("Artist/Album/02 - Other.lrc")); err != nil {
+				t.Errorf("sidecar file was not moved: %v", err)
+			}
+		})
+	}
+}
+
+func TestMoveStagedFilesWithoutStaging(t *testing.T) {
+	useTestLibrary(t)
+	moved, err := moveStagedFiles("missing", conflictSkip)
+	if err != nil || len(moved) != 0 {
+		t.Errorf("moveStagedFiles = %v, %v; want no files and no error", moved, err)
+	}
+}
+
+func TestDownloadedTracks(t *testing.T) {
+	job := DownloadJob{Tracks: []*JobTrack{
+		{File: "a.mp3"},
+		{File: "b.mp3", Skipped: true},
+		{File: "c.mp3"},
+	}}
+	var files []string
+	for _, track := range downloadedTracks(job) {
+		files = append(files, track.File)
+	}
+	if want := []string{"a.mp3", "c.mp3"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("downloadedTracks = %v, want %v", files, want)
+	}
+}
+
+func TestContainerToLibraryPath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"/downloads/Artist/Album/01 - Song.mp3", "Artist/Album/01 - Song.mp3"},
+		{"/music/Artist/Album/01 - Song.mp3", "Artist/Album/01 - Song.mp3"},
+		{"/downloadsX/Song.mp3", "downloadsX/Song.mp3"},
+		{"Artist/Song.mp3", "Artist/Song.mp3"},
+	}
+	for _, tt := range tests {
+		if got := containerToLibraryPath(tt.in); got != tt.want {
+			t.Errorf("containerToLibraryPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
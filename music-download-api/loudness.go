@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Modos de tratamento de volume após o download.
+const (
+	loudnessOff        = "off"
+	loudnessReplayGain = "replaygain"
+	loudnessNormalize  = "r128"
+
+	// replayGainReference é o nível de referência do ReplayGain 2.0, em LUFS.
+	replayGainReference = -18.0
+)
+
+var (
+	loudnessMode   = getEnv("LOUDNESS_MODE", loudnessOff)
+	loudnessTarget = getEnvFloat("LOUDNESS_TARGET", -16)
+)
+
+// LoudnessResult é a medição (e o ajuste aplicado) de um arquivo.
+type LoudnessResult struct {
+	File           string   `json:"file"`
+	IntegratedLUFS float64  `json:"integrated_lufs"`
+	TruePeakDBTP   float64  `json:"true_peak_dbtp"`
+	LoudnessRange  float64  `json:"loudness_range"`
+	TrackGainDB    float64  `json:"track_gain_db"`
+	AlbumGainDB    *float64 `json:"album_gain_db,omitempty"`
+	Normalized     bool     `json:"normalized,omitempty"`
+	Error          string   `json:"error,omitempty"`
+}
+
+// loudnormStats é o JSON impresso pelo filtro loudnorm do ffmpeg (valores vêm como texto).
+type loudnormStats struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+// measureLoudness roda a primeira passada do loudnorm (EBU R128) e devolve as medições.
+func measureLoudness(path string) (*loudnormStats, float64, error) {
+	cmd := exec.Command(
+		"ffmpeg", "-hide_banner", "-nostats", "-i", path,
+		"-af", fmt.Sprintf("loudnorm=I=%.1f:TP=-1.5:LRA=11:print_format=json", loudnessTarget),
+		"-f", "null", "-",
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, 0, fmt.Errorf("ffmpeg loudness analysis failed: %w", err)
+	}
+
+	text := string(output)
+	start := strings.LastIndex(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return nil, 0, fmt.Errorf("loudnorm output not found")
+	}
+
+	var stats loudnormStats
+	if err := json.Unmarshal([]byte(text[start:end+1]), &stats); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode loudnorm output: %w", err)
+	}
+
+	return &stats, parseFFmpegDuration(text), nil
+}
+
+// parseFFmpegDuration extrai "Duration: HH:MM:SS.xx" da saída do ffmpeg, em segundos.
+func parseFFmpegDuration(output string) float64 {
+	idx := strings.Index(output, "Duration: ")
+	if idx < 0 {
+		return 0
+	}
+	value := strings.SplitN(output[idx+len("Duration: "):], ",", 2)[0]
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) != 3 {
+		return 0
+	}
+	h, _ := strconv.ParseFloat(parts[0], 64)
+	m, _ := strconv.ParseFloat(parts[1], 64)
+	sec, _ := strconv.ParseFloat(parts[2], 64)
+	return h*3600 + m*60 + sec
+}
+
+// albumLoudness combina as medições das faixas em uma loudness de álbum, ponderando
+// a energia de cada faixa pela sua duração.
+func albumLoudness(lufs, durations []float64) float64 {
+	var energy, total float64
+	for i, l := range lufs {
+		energy += durations[i] * math.Pow(10, l/10)
+		total += durations[i]
+	}
+	if total == 0 {
+		return replayGainReference
+	}
+	return 10 * math.Log10(energy/total)
+}
+
+// writeReplayGainTags grava as tags REPLAYGAIN_* sem recodificar o áudio.
+func writeReplayGainTags(path string, result LoudnessResult, albumPeak float64) error {
+	args := []string{
+		"-y", "-loglevel", "error", "-i", path,
+		"-map", "0", "-map_metadata", "0", "-c", "copy",
+		"-metadata", fmt.Sprintf("REPLAYGAIN_TRACK_GAIN=%.2f dB", result.TrackGainDB),
+		"-metadata", fmt.Sprintf("REPLAYGAIN_TRACK_PEAK=%.6f", math.Pow(10, result.TruePeakDBTP/20)),
+	}
+	if result.AlbumGainDB != nil {
+		args = append(args,
+			"-metadata", fmt.Sprintf("REPLAYGAIN_ALBUM_GAIN=%.2f dB", *result.AlbumGainDB),
+			"-metadata", fmt.Sprintf("REPLAYGAIN_ALBUM_PEAK=%.6f", math.Pow(10, albumPeak/20)),
+		)
+	}
+	return rewriteWithFFmpeg(path, append(args, metadataMuxerArgs(path)...))
+}
+
+// normalizeCodecArgs devolve o codificador usado para regravar cada formato após a
+// normalização; formatos sem entrada aqui não são normalizados.
+func normalizeCodecArgs(ext string) ([]string, bool) {
+	switch strings.ToLower(ext) {
+	case ".mp3":
+		return []string{"-c:a", "libmp3lame", "-q:a", "0"}, true
+	case ".m4a", ".mp4", ".aac":
+		return []string{"-c:a", "aac", "-b:a", "256k"}, true
+	case ".flac":
+		return []string{"-c:a", "flac"}, true
+	case ".opus", ".ogg", ".webm":
+		return []string{"-c:a", "libopus", "-b:a", "160k"}, true
+	}
+	return nil, false
+}
+
+// normalizeLoudness aplica a segunda passada do loudnorm usando as medições da primeira,
+// recodificando o áudio no mesmo formato e taxa de amostragem do arquivo.
+func normalizeLoudness(path string, stats *loudnormStats) error {
+	codecArgs, ok := normalizeCodecArgs(filepath.Ext(path))
+	if !ok {
+		return fmt.Errorf("normalization not supported for %s files", filepath.Ext(path))
+	}
+
+	// O loudnorm trabalha em 192 kHz; voltamos para a taxa original, para não reduzir a
+	// qualidade de arquivos hi-res
+	probe, err := probeAudio(path)
+	if err != nil {
+		return err
+	}
+	if probe.SampleRate > 0 {
+		codecArgs = append(codecArgs, "-ar", strconv.Itoa(probe.SampleRate))
+	}
+
+	filter := fmt.Sprintf(
+		"loudnorm=I=%.1f:TP=-1.5:LRA=11:measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true",
+		loudnessTarget, stats.InputI, stats.InputTP, stats.InputLRA, stats.InputThresh, stats.TargetOffset,
+	)
+	args := []string{
+		"-y", "-loglevel", "error", "-i", path,
+		"-map", "0:a", "-map", "0:v?", "-map_metadata", "0",
+		"-af", filter, "-c:v", "copy",
+	}
+	args = append(args, codecArgs...)
+	return rewriteWithFFmpeg(path, append(args, metadataMuxerArgs(path)...))
+}
+
+// isAlbumJob indica se as faixas do job formam um álbum, para o cálculo do ganho de álbum.
+func isAlbumJob(job *DownloadJob) bool {
+	if strings.Contains(job.URL, "/album/") {
+		return true
+	}
+	if len(job.Tracks) < 2 {
+		return false
+	}
+	album := ""
+	for _, track := range job.Tracks {
+		if track.Metadata == nil || track.Metadata.Album == "" {
+			return false
+		}
+		if album != "" && track.Metadata.Album != album {
+			return false
+		}
+		album = track.Metadata.Album
+	}
+	return true
+}
+
+// processJobLoudness mede todas as faixas do job e grava ReplayGain (com ganho de álbum
+// em jobs de álbum) ou normaliza o áudio em EBU R128, conforme LOUDNESS_MODE.
+func processJobLoudness(job *DownloadJob, emit func(string)) {
+	if loudnessMode != loudnessReplayGain && loudnessMode != loudnessNormalize {
+		return
+	}
+
+	snapshot := snapshotJob(job)
+	tracks := downloadedTracks(snapshot)
+	results := make([]LoudnessResult, 0, len(tracks))
+	allStats := make([]*loudnormStats, 0, len(tracks))
+	var lufs, durations []float64
+	albumPeak := math.Inf(-1)
+
+	for _, track := range tracks {
+		if _, ok := normalizeCodecArgs(filepath.Ext(track.File)); loudnessMode == loudnessNormalize && !ok {
+			log.Infof("Skipping loudness normalization of %s: no encoder mapped for its format", track.File)
+			continue
+		}
+		result := LoudnessResult{File: track.File}
+		stats, duration, err := measureLoudness(absLibraryPath(track.File))
+		if err != nil {
+			log.WithError(err).Errorf("Failed to measure loudness of %s", track.File)
+			result.Error = err.Error()
+			results = append(results, result)
+			allStats = append(allStats, nil)
+			continue
+		}
+
+		result.IntegratedLUFS, _ = strconv.ParseFloat(stats.InputI, 64)
+		result.TruePeakDBTP, _ = strconv.ParseFloat(stats.InputTP, 64)
+		result.LoudnessRange, _ = strconv.ParseFloat(stats.InputLRA, 64)
+		result.TrackGainDB = replayGainReference - result.IntegratedLUFS
+
+		lufs = append(lufs, result.IntegratedLUFS)
+		durations = append(durations, duration)
+		albumPeak = math.Max(albumPeak, result.TruePeakDBTP)
+		results = append(results, result)
+		allStats = append(allStats, stats)
+	}
+
+	if loudnessMode == loudnessReplayGain && isAlbumJob(&snapshot) && len(lufs) > 0 {
+		albumGain := replayGainReference - albumLoudness(lufs, durations)
+		for i := range results {
+			if results[i].Error == "" {
+				results[i].AlbumGainDB = &albumGain
+			}
+		}
+	}
+
+	for i := range results {
+		if results[i].Error != "" {
+			continue
+		}
+		path := absLibraryPath(results[i].File)
+
+		var err error
+		if loudnessMode == loudnessNormalize {
+			err = normalizeLoudness(path, allStats[i])
+			results[i].Normalized = err == nil
+		} else {
+			err = writeReplayGainTags(path, results[i], albumPeak)
+		}
+		if err != nil {
+			log.WithError(err).Errorf("Failed to apply loudness to %s", results[i].File)
+			results[i].Error = err.Error()
+			continue
+		}
+		emit(fmt.Sprintf("Loudness %s: %.1f LUFS, track gain %.2f dB", results[i].File, results[i].IntegratedLUFS, results[i].TrackGainDB))
+	}
+
+	updateJob(job, func(j *DownloadJob) { j.Result.Loudness = results })
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestNormalizeCodecArgs(t *testing.T) {
+	tests := []struct {
+		ext   string
+		codec string
+		ok    bool
+	}{
+		{".mp3", "libmp3lame", true},
+		{".M4A", "aac", true},
+		{".flac", "flac", true},
+		{".opus", "libopus", true},
+		{".wav", "", false},
+		{".wma", "", false},
+	}
+	for _, tt := range tests {
+		args, ok := normalizeCodecArgs(tt.ext)
+		if ok != tt.ok {
+			t.Errorf("normalizeCodecArgs(%q) ok = %v, want %v", tt.ext, ok, tt.ok)
+			continue
+		}
+		if ok && (len(args) < 2 || args[1] != tt.codec) {
+			t.Errorf("normalizeCodecArgs(%q) = %v, want codec %s", tt.ext, args, tt.codec)
+		}
+		// A taxa de amostragem vem do arquivo original, não de um valor fixo por formato
+		if slices.Contains(args, "-ar") {
+			t.Errorf("normalizeCodecArgs(%q) = %v, want no fixed sample rate", tt.ext, args)
+		}
+	}
+}
+
+func TestAlbumLoudness(t *testing.T) {
+	if got := albumLoudness([]float64{-10, -10}, []float64{100, 300}); math.Abs(got+10) > 1e-9 {
+		t.Errorf("albumLoudness of equal tracks = %v, want -10", got)
+	}
+	// A faixa mais longa pesa mais no resultado
+	got := albumLoudness([]float64{-20, -10}, []float64{100, 300})
+	if got <= -15 || got >= -10 {
+		t.Errorf("albumLoudness = %v, want between -15 and -10", got)
+	}
+	if got := albumLoudness(nil, nil); got != replayGainReference {
+		t.Errorf("albumLoudness without tracks = %v, want %v", got, replayGainReference)
+	}
+}
+
+func TestParseFFmpegDuration(t *testing.T) {
+	output := "Input #0, mp3, from 'a.mp3':\n  Duration: 00:03:25.50, start: 0.025057, bitrate: 320 kb/s"
+	if got := parseFFmpegDuration(output); got != 205.5 {
+		t.Errorf("parseFFmpegDuration = %v, want 205.5", got)
+	}
+	if got := parseFFmpegDuration("no duration"); got != 0 {
+		t.Errorf("parseFFmpegDuration without duration = %v, want 0", got)
+	}
+}
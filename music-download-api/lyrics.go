@@ -0,0 +1,387 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Modos de gravação das letras após o download.
+const (
+	lyricsSidecar = "sidecar"
+	lyricsEmbed   = "embed"
+	lyricsBoth    = "both"
+)
+
+// Limites para aceitar um resultado da busca livre do LRCLIB: sem eles a busca devolve
+// a letra de outra música com título parecido. lyricsMinDurationSimilarity 0.8 tolera
+// até 6s de diferença (ver durationSimilarity).
+const (
+	lyricsMinDurationSimilarity = 0.8
+	lyricsMinArtistSimilarity   = 0.6
+)
+
+var (
+	lyricsEnabled  = getEnv("LYRICS_ENABLED", "false") == "true"
+	lyricsMode     = getEnv("LYRICS_MODE", lyricsBoth)
+	lyricsLocalDir = os.Getenv("LYRICS_LOCAL_DIR")
+	lyricsProvider = newLyricsProvider(getEnv("LYRICS_PROVIDER", "lrclib"))
+)
+
+// LyricsQuery identifica a faixa cujas letras devem ser buscadas.
+type LyricsQuery struct {
+	Title       string  `json:"title"`
+	Artist      string  `json:"artist"`
+	Album       string  `json:"album,omitempty"`
+	DurationSec float64 `json:"duration_sec,omitempty"`
+}
+
+// Lyrics é a letra encontrada: Synced no formato LRC e Plain sem marcações de tempo.
+type Lyrics struct {
+	Provider     string `json:"provider"`
+	Synced       string `json:"synced,omitempty"`
+	Plain        string `json:"plain,omitempty"`
+	Instrumental bool   `json:"instrumental,omitempty"`
+}
+
+// errLyricsNotFound é retornado pelos provedores quando a faixa não tem letra cadastrada.
+var errLyricsNotFound = fmt.Errorf("lyrics not found")
+
+// LyricsProvider é uma fonte de letras. Novos provedores só precisam implementar esta
+// interface e ser registrados em newLyricsProvider.
+type LyricsProvider interface {
+	Name() string
+	FetchLyrics(query LyricsQuery) (*Lyrics, error)
+}
+
+// validateLyricsMode confere LYRICS_MODE na inicialização; um valor inválido faria os
+// jobs buscarem letras sem gravá-las em lugar nenhum.
+func validateLyricsMode(mode string) error {
+	switch mode {
+	case lyricsSidecar, lyricsEmbed, lyricsBoth:
+		return nil
+	}
+	return fmt.Errorf("invalid LYRICS_MODE %q, use %s, %s or %s", mode, lyricsSidecar, lyricsEmbed, lyricsBoth)
+}
+
+// newLyricsProvider escolhe o provedor configurado em LYRICS_PROVIDER.
+func newLyricsProvider(name string) LyricsProvider {
+	switch name {
+	case "local":
+		return &localLyricsProvider{dir: lyricsLocalDir}
+	default:
+		return &lrclibProvider{baseURL: getEnv("LRCLIB_URL", "https://lrclib.net")}
+	}
+}
+
+// lrclibProvider busca letras no LRCLIB (https://lrclib.net).
+type lrclibProvider struct {
+	baseURL string
+}
+
+type lrclibRecord struct {
+	TrackName    string  `json:"trackName"`
+	ArtistName   string  `json:"artistName"`
+	Duration     float64 `json:"duration"`
+	Instrumental bool    `json:"instrumental"`
+	PlainLyrics  string  `json:"plainLyrics"`
+	SyncedLyrics string  `json:"syncedLyrics"`
+}
+
+func (p *lrclibProvider) Name() string { return "lrclib" }
+
+// FetchLyrics tenta primeiro o casamento exato (/api/get) e, sem sucesso, a busca livre
+// (/api/search), escolhendo o resultado com duração mais próxima entre os compatíveis.
+func (p *lrclibProvider) FetchLyrics(query LyricsQuery) (*Lyrics, error) {
+	params := url.Values{}
+	params.Set("track_name", query.Title)
+	params.Set("artist_name", query.Artist)
+	if query.Album != "" {
+		params.Set("album_name", query.Album)
+	}
+	if query.DurationSec > 0 {
+		params.Set("duration", strconv.Itoa(int(query.DurationSec)))
+	}
+
+	var record lrclibRecord
+	found, err := p.get("/api/get?"+params.Encode(), &record)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return p.toLyrics(record), nil
+	}
+
+	search := url.Values{}
+	search.Set("track_name", query.Title)
+	search.Set("artist_name", query.Artist)
+	var records []lrclibRecord
+	if _, err := p.get("/api/search?"+search.Encode(), &records); err != nil {
+		return nil, err
+	}
+	best, ok := pickLRCLIBRecord(query, records)
+	if !ok {
+		return nil, errLyricsNotFound
+	}
+	return p.toLyrics(best), nil
+}
+
+// pickLRCLIBRecord escolhe o resultado da busca com duração mais próxima, descartando os
+// de outro artista ou com duração muito diferente da faixa.
+func pickLRCLIBRecord(query LyricsQuery, records []lrclibRecord) (lrclibRecord, bool) {
+	var best lrclibRecord
+	bestScore := -1.0
+	for _, r := range records {
+		if query.Artist != "" && stringSimilarity(query.Artist, r.ArtistName) < lyricsMinArtistSimilarity {
+			continue
+		}
+		score := 1.0
+		if query.DurationSec > 0 {
+			score = durationSimilarity(query.DurationSec, r.Duration)
+			if score < lyricsMinDurationSimilarity {
+				continue
+			}
+		}
+		if score > bestScore {
+			best, bestScore = r, score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+// get faz a requisição ao LRCLIB; retorna found=false quando a API responde 404.
+func (p *lrclibProvider) get(path string, out interface{}) (bool, error) {
+	req, err := http.NewRequest("GET", p.baseURL+path, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("User-Agent", "stack-music (https://github.com/PedroCamargo-dev/stack-music)")
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("lrclib returned status %d", resp.StatusCode)
+	}
+	return true, json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (p *lrclibProvider) toLyrics(record lrclibRecord) *Lyrics {
+	return &Lyrics{
+		Provider:     p.Name(),
+		Synced:       record.SyncedLyrics,
+		Plain:        record.PlainLyrics,
+		Instrumental: record.Instrumental,
+	}
+}
+
+// localLyricsProvider lê letras de arquivos "Artista - Título.lrc" ou ".txt" em um
+// diretório local. Serve como substituto do LRCLIB em testes e ambientes offline.
+type localLyricsProvider struct {
+	dir string
+}
+
+func (p *localLyricsProvider) Name() string { return "local" }
+
+func (p *localLyricsProvider) FetchLyrics(query LyricsQuery) (*Lyrics, error) {
+	if p.dir == "" {
+		return nil, fmt.Errorf("LYRICS_LOCAL_DIR not set")
+	}
+
+	base := sanitizeFilename(fmt.Sprintf("%s - %s", query.Artist, query.Title))
+	lyrics := &Lyrics{Provider: p.Name()}
+
+	if data, err := os.ReadFile(filepath.Join(p.dir, base+".lrc")); err == nil {
+		lyrics.Synced = string(data)
+		lyrics.Plain = stripLRCTimestamps(lyrics.Synced)
+	}
+	if data, err := os.ReadFile(filepath.Join(p.dir, base+".txt")); err == nil {
+		lyrics.Plain = string(data)
+	}
+
+	if lyrics.Synced == "" && lyrics.Plain == "" {
+		return nil, errLyricsNotFound
+	}
+	return lyrics, nil
+}
+
+// stripLRCTimestamps remove as marcações [mm:ss.xx] e as linhas de cabeçalho de um LRC.
+func stripLRCTimestamps(lrc string) string {
+	var lines []string
+	for _, line := range strings.Split(lrc, "\n") {
+		line = strings.TrimRight(line, "\r")
+		for strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end < 0 {
+				break
+			}
+			line = line[end+1:]
+		}
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// lyricsQueryForTrack monta a consulta a partir dos metadados da faixa ou, na falta
+// deles, do título do vídeo.
+func lyricsQueryForTrack(job *DownloadJob, track *JobTrack) (LyricsQuery, bool) {
+	meta := track.Metadata
+	if meta == nil {
+		meta = job.Metadata
+	}
+	if meta != nil && meta.Title != "" && len(meta.Artists) > 0 {
+		return LyricsQuery{
+			Title:       meta.Title,
+			Artist:      meta.Artists[0],
+			Album:       meta.Album,
+			DurationSec: float64(meta.DurationMs) / 1000,
+		}, true
+	}
+	if track.Parsed != nil && track.Parsed.Title != "" {
+		return LyricsQuery{Title: track.Parsed.Title, Artist: track.Parsed.Artist, DurationSec: track.DurationSec}, true
+	}
+	return LyricsQuery{}, false
+}
+
+// writeLyricsSidecar grava o arquivo .lrc ao lado do áudio.
+func writeLyricsSidecar(path string, lyrics *Lyrics) error {
+	content := lyrics.Synced
+	if content == "" {
+		content = lyrics.Plain
+	}
+	lrcPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".lrc"
+	return os.WriteFile(lrcPath, []byte(content+"\n"), 0o644)
+}
+
+// embedLyrics grava a letra sem sincronização na tag "lyrics" do arquivo.
+func embedLyrics(path string, lyrics *Lyrics) error {
+	plain := lyrics.Plain
+	if plain == "" {
+		plain = stripLRCTimestamps(lyrics.Synced)
+	}
+	args := []string{
+		"-y", "-loglevel", "error", "-i", path,
+		"-map", "0", "-map_metadata", "0", "-c", "copy",
+		"-metadata", "lyrics=" + plain,
+	}
+	return rewriteWithFFmpeg(path, append(args, metadataMuxerArgs(path)...))
+}
+
+// fetchJobLyrics busca e grava as letras de cada faixa do job, conforme LYRICS_MODE.
+func fetchJobLyrics(job *DownloadJob, emit func(string)) {
+	if !lyricsEnabled {
+		return
+	}
+
+	snapshot := snapshotJob(job)
+	for _, track := range downloadedTracks(snapshot) {
+		query, ok := lyricsQueryForTrack(&snapshot, track)
+		if !ok {
+			continue
+		}
+
+		lyrics, err := lyricsProvider.FetchLyrics(query)
+		if err != nil || lyrics.Instrumental {
+			if err != nil && err != errLyricsNotFound {
+				log.WithError(err).Warnf("Failed to fetch lyrics for %s", track.File)
+			}
+			updateJob(job, func(j *DownloadJob) {
+				j.Result.LyricsMissing = append(j.Result.LyricsMissing, track.File)
+			})
+			continue
+		}
+
+		path := absLibraryPath(track.File)
+		saved := false
+		if lyricsMode == lyricsSidecar || lyricsMode == lyricsBoth {
+			if err := writeLyricsSidecar(path, lyrics); err != nil {
+				log.WithError(err).Errorf("Failed to write lyrics sidecar for %s", track.File)
+			} else {
+				saved = true
+			}
+		}
+		if lyricsMode == lyricsEmbed || lyricsMode == lyricsBoth {
+			if err := embedLyrics(path, lyrics); err != nil {
+				log.WithError(err).Errorf("Failed to embed lyrics in %s", track.File)
+			} else {
+				saved = true
+			}
+		}
+		if !saved {
+			emit(fmt.Sprintf("Failed to save lyrics for %s", track.File))
+			updateJob(job, func(j *DownloadJob) {
+				j.Result.LyricsErrors = append(j.Result.LyricsErrors, track.File)
+			})
+			continue
+		}
+
+		emit(fmt.Sprintf("Lyrics saved for %s (%s)", track.File, lyrics.Provider))
+		updateJob(job, func(j *DownloadJob) {
+			j.Result.Lyrics = append(j.Result.Lyrics, track.File)
+		})
+	}
+}
+
+// getLyrics busca letras sob demanda. "track" aceita uma URL/URI de faixa do Spotify ou
+// um texto "Artista - Título"; artist, title, album e duration podem ser passados à parte.
+func getLyrics(c *gin.Context) {
+	query := LyricsQuery{
+		Title:  c.Query("title"),
+		Artist: c.Query("artist"),
+		Album:  c.Query("album"),
+	}
+	if duration, err := strconv.ParseFloat(c.Query("duration"), 64); err == nil {
+		query.DurationSec = duration
+	}
+
+	if track := c.Query("track"); track != "" {
+		if strings.HasPrefix(track, "spotify:track:") || strings.Contains(track, "spotify.com") {
+			meta, err := fetchTrackMetadata(track)
+			if err != nil {
+				log.WithError(err).Errorf("Failed to get Spotify metadata for %s", track)
+				c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to get track metadata"})
+				return
+			}
+			query = LyricsQuery{Title: meta.Title, Album: meta.Album, DurationSec: float64(meta.DurationMs) / 1000}
+			if len(meta.Artists) > 0 {
+				query.Artist = meta.Artists[0]
+			}
+		} else {
+			parsed := parseVideoTitle(track, query.Artist)
+			query.Title, query.Artist = parsed.Title, parsed.Artist
+		}
+	}
+
+	if query.Title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing track or title"})
+		return
+	}
+
+	lyrics, err := lyricsProvider.FetchLyrics(query)
+	if err == errLyricsNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lyrics not found", "query": query})
+		return
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to fetch lyrics")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch lyrics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"query": query, "lyrics": lyrics})
+}
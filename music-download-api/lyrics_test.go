@@ -0,0 +1,165 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNewLyricsProvider(t *testing.T) {
+	previous := lyricsLocalDir
+	lyricsLocalDir = "/lyrics"
+	t.Cleanup(func() { lyricsLocalDir = previous })
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"local", "local"},
+		{"lrclib", "lrclib"},
+		{"", "lrclib"},
+		{"unknown", "lrclib"},
+	}
+	for _, tt := range tests {
+		provider := newLyricsProvider(tt.name)
+		if got := provider.Name(); got != tt.want {
+			t.Errorf("newLyricsProvider(%q).Name() = %q, want %q", tt.name, got, tt.want)
+		}
+		if local, ok := provider.(*localLyricsProvider); ok && local.dir != "/lyrics" {
+			t.Errorf("local provider dir = %q, want LYRICS_LOCAL_DIR", local.dir)
+		}
+	}
+}
+
+func TestStripLRCTimestamps(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"single timestamp", "[00:12.34]Hello", "Hello"},
+		{"repeated line", "[00:01.00][00:30.00] Chorus", "Chorus"},
+		{"header lines", "[ar:Artist]\n[ti:Song]\n[00:01.00]Line one\r\n[00:02.00]Line two", "Line one\nLine two"},
+		{"plain text kept", "No timestamps here", "No timestamps here"},
+		{"unclosed bracket", "[00:01 broken", "[00:01 broken"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripLRCTimestamps(tt.in); got != tt.want {
+				t.Errorf("stripLRCTimestamps(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalLyricsProvider(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "Artist - Synced.lrc"), "[00:01.00]First\n[00:02.00]Second")
+	writeTestFile(t, filepath.Join(dir, "Artist - Plain.txt"), "Just text")
+	writeTestFile(t, filepath.Join(dir, "AC_DC - Both.lrc"), "[00:01.00]Synced")
+	writeTestFile(t, filepath.Join(dir, "AC_DC - Both.txt"), "Plain version")
+
+	tests := []struct {
+		name    string
+		query   LyricsQuery
+		synced  string
+		plain   string
+		wantErr error
+	}{
+		{
+			name:   "lrc derives plain text",
+			query:  LyricsQuery{Artist: "Artist", Title: "Synced"},
+			synced: "[00:01.00]First\n[00:02.00]Second",
+			plain:  "First\nSecond",
+		},
+		{
+			name:  "txt only",
+			query: LyricsQuery{Artist: "Artist", Title: "Plain"},
+			plain: "Just text",
+		},
+		{
+			name:   "txt overrides derived plain text and names are sanitized",
+			query:  LyricsQuery{Artist: "AC/DC", Title: "Both"},
+			synced: "[00:01.00]Synced",
+			plain:  "Plain version",
+		},
+		{
+			name:    "missing",
+			query:   LyricsQuery{Artist: "Artist", Title: "Missing"},
+			wantErr: errLyricsNotFound,
+		},
+	}
+
+	provider := &localLyricsProvider{dir: dir}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lyrics, err := provider.FetchLyrics(tt.query)
+			if tt.wantErr != nil {
+				if err != tt.wantErr {
+					t.Fatalf("FetchLyrics error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lyrics.Provider != "local" || lyrics.Synced != tt.synced || lyrics.Plain != tt.plain {
+				t.Errorf("FetchLyrics = %+v, want synced %q and plain %q", lyrics, tt.synced, tt.plain)
+			}
+		})
+	}
+}
+
+func TestLocalLyricsProviderWithoutDir(t *testing.T) {
+	if _, err := (&localLyricsProvider{}).FetchLyrics(LyricsQuery{Title: "Song"}); err == nil {
+		t.Error("FetchLyrics without a directory succeeded, want error")
+	}
+}
+
+func TestValidateLyricsMode(t *testing.T) {
+	for _, mode := range []string{lyricsSidecar, lyricsEmbed, lyricsBoth} {
+		if err := validateLyricsMode(mode); err != nil {
+			t.Errorf("validateLyricsMode(%q) = %v, want nil", mode, err)
+		}
+	}
+	for _, mode := range []string{"", "lrc", "Both"} {
+		if err := validateLyricsMode(mode); err == nil {
+			t.Errorf("validateLyricsMode(%q) succeeded, want error", mode)
+		}
+	}
+}
+
+func TestPickLRCLIBRecord(t *testing.T) {
+	records := []lrclibRecord{
+		{TrackName: "Song", ArtistName: "Another Group", Duration: 200},
+		{TrackName: "Song", ArtistName: "Band", Duration: 260},
+		{TrackName: "Song", ArtistName: "Band", Duration: 203},
+		{TrackName: "Song (Live)", ArtistName: "The Band", Duration: 199},
+	}
+	tests := []struct {
+		name     string
+		query    LyricsQuery
+		records  []lrclibRecord
+		want     float64
+		wantNone bool
+	}{
+		{"closest duration of the same artist", LyricsQuery{Title: "Song", Artist: "Band", DurationSec: 200}, records, 199, false},
+		{"unknown duration takes the first of the artist", LyricsQuery{Title: "Song", Artist: "Band"}, records, 260, false},
+		{"duration too far", LyricsQuery{Title: "Song", Artist: "Band", DurationSec: 320}, records, 0, true},
+		{"other artist only", LyricsQuery{Title: "Song", Artist: "Singer", DurationSec: 200}, records[:1], 0, true},
+		{"no results", LyricsQuery{Title: "Song", Artist: "Band"}, nil, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := pickLRCLIBRecord(tt.query, tt.records)
+			if tt.wantNone {
+				if ok {
+					t.Errorf("pickLRCLIBRecord = %+v, want no record", got)
+				}
+				return
+			}
+			if !ok || got.Duration != tt.want {
+				t.Errorf("pickLRCLIBRecord = %+v, %v; want duration %v", got, ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	m3uEnabled     = getEnv("M3U_ENABLED", "true") == "true"
+	m3uPlaylistDir = getEnv("M3U_PLAYLIST_DIR", "Playlists")
+)
+
+// M3UResult registra o arquivo de playlist gerado para o job.
+type M3UResult struct {
+	File    string   `json:"file"`
+	Entries int      `json:"entries"`
+	Missing []string `json:"missing,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// m3uSourcePrefix marca no .m3u8 a URL da coleção de origem, para que uma playlist de
+// mesmo nome vinda de outra fonte não sobrescreva o arquivo.
+const m3uSourcePrefix = "#SOURCE:"
+
+// m3uSource lê a URL de origem gravada no cabeçalho de um .m3u8; "" se não houver.
+func m3uSource(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if source, ok := strings.CutPrefix(line, m3uSourcePrefix); ok {
+			return source
+		}
+		if !strings.HasPrefix(line, "#") || strings.HasPrefix(line, "#EXTINF") {
+			break
+		}
+	}
+	return ""
+}
+
+// m3uPathForCollection escolhe o arquivo da playlist: o que já pertence à mesma origem
+// ou o primeiro "Nome.m3u8", "Nome (2).m3u8"... ainda livre.
+func m3uPathForCollection(name, sourceURL string) string {
+	base := sanitizeFilename(name)
+	for n := 1; ; n++ {
+		file := base + ".m3u8"
+		if n > 1 {
+			file = fmt.Sprintf("%s (%d).m3u8", base, n)
+		}
+		rel := filepath.ToSlash(filepath.Join(m3uPlaylistDir, file))
+		if _, err := os.Stat(absLibraryPath(rel)); os.IsNotExist(err) || m3uSource(absLibraryPath(rel)) == sourceURL {
+			return rel
+		}
+	}
+}
+
+// writeJobM3U gera (ou regenera) o .m3u8 da coleção do job, na ordem de origem, com
+// caminhos relativos ao próprio arquivo de playlist.
+func writeJobM3U(job *DownloadJob) {
+	if !m3uEnabled || job.Collection == nil {
+		return
+	}
+
+	collection := job.Collection
+	rel := m3uPathForCollection(collection.Name, job.URL)
+	result := &M3UResult{File: rel}
+	defer func() {
+		updateJob(job, func(j *DownloadJob) { j.Result.M3U = result })
+	}()
+
+	playlistPath := absLibraryPath(rel)
+	playlistDir := filepath.Dir(playlistPath)
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	fmt.Fprintf(&b, "#PLAYLIST:%s\n", collection.Name)
+	fmt.Fprintf(&b, "%s%s\n", m3uSourcePrefix, job.URL)
+
+	for _, entry := range collection.Entries {
+		entry = entryForJobTrack(job, entry)
+		file, ok := resolveEntryFile(job, entry)
+		if !ok {
+			result.Missing = append(result.Missing, describeEntry(entry))
+			continue
+		}
+
+		target, err := filepath.Rel(playlistDir, absLibraryPath(file))
+		if err != nil {
+			result.Missing = append(result.Missing, describeEntry(entry))
+			continue
+		}
+
+		duration := entry.DurationMs / 1000
+		if duration == 0 {
+			if probe, err := probeAudio(absLibraryPath(file)); err == nil {
+				duration = int(probe.DurationSec)
+			}
+		}
+		if duration == 0 {
+			duration = -1
+		}
+
+		fmt.Fprintf(&b, "#EXTINF:%d,%s\n", duration, describeEntry(entry))
+		b.WriteString(filepath.ToSlash(target) + "\n")
+		result.Entries++
+	}
+
+	if err := os.MkdirAll(playlistDir, 0o755); err != nil {
+		result.Error = err.Error()
+		return
+	}
+	if err := os.WriteFile(playlistPath, []byte(b.String()), 0o644); err != nil {
+		log.WithError(err).Errorf("Failed to write playlist %s", rel)
+		result.Error = err.Error()
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestM3UPathForCollection(t *testing.T) {
+	useTestLibrary(t)
+	previous := m3uPlaylistDir
+	m3uPlaylistDir = "Playlists"
+	t.Cleanup(func() { m3uPlaylistDir = previous })
+	writeTestFile(t, absLibraryPath("Playlists/Mix.m3u8"), "#EXTM3U\n#PLAYLIST:Mix\n#SOURCE:https://open.spotify.com/playlist/a\n")
+	writeTestFile(t, absLibraryPath("Playlists/Mix (2).m3u8"), "#EXTM3U\n#PLAYLIST:Mix\n#SOURCE:https://open.spotify.com/playlist/b\n")
+	writeTestFile(t, absLibraryPath("Playlists/Old.m3u8"), "#EXTM3U\n#EXTINF:1,Song\nSong.mp3\n")
+
+	tests := []struct {
+		name      string
+		playlist  string
+		sourceURL string
+		want      string
+	}{
+		{"new name", "Road Trip", "https://open.spotify.com/playlist/c", "Playlists/Road Trip.m3u8"},
+		{"same source keeps its file", "Mix", "https://open.spotify.com/playlist/a", "Playlists/Mix.m3u8"},
+		{"same source with suffix", "Mix", "https://open.spotify.com/playlist/b", "Playlists/Mix (2).m3u8"},
+		{"other source gets a free suffix", "Mix", "https://open.spotify.com/playlist/c", "Playlists/Mix (3).m3u8"},
+		{"file without source is not reused", "Old", "https://open.spotify.com/playlist/c", "Playlists/Old (2).m3u8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m3uPathForCollection(tt.playlist, tt.sourceURL); got != tt.want {
+				t.Errorf("m3uPathForCollection(%q, %q) = %q, want %q", tt.playlist, tt.sourceURL, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteJobM3U(t *testing.T) {
+	useTestLibrary(t)
+	useTestDataDir(t)
+	previous := m3uPlaylistDir
+	m3uPlaylistDir = "Playlists"
+	t.Cleanup(func() { m3uPlaylistDir = previous })
+	writeTestFile(t, absLibraryPath("Band/Album/01 - First.mp3"), "audio")
+	writeTestFile(t, absLibraryPath("Other/Record/02 - Second.mp3"), "audio")
+	// A segunda faixa veio de um download anterior e só está no índice
+	recordDownloads([]IndexEntry{{Platform: "spotify", SourceID: "t2", File: "Other/Record/02 - Second.mp3"}})
+
+	job := &DownloadJob{
+		URL:    "https://open.spotify.com/playlist/mix",
+		Tracks: []*JobTrack{{File: "Band/Album/01 - First.mp3", SourceID: "t1"}},
+		Collection: &JobCollection{Name: "Road/Trip", Entries: []PlaylistEntry{
+			{Platform: "spotify", SourceID: "t1", Title: "First", Artists: []string{"Band"}, DurationMs: 200000},
+			{Platform: "spotify", SourceID: "t2", Title: "Second", Artists: []string{"Other"}, DurationMs: 180500},
+			{Platform: "spotify", SourceID: "t3", Title: "Missing", Artists: []string{"Nobody"}, DurationMs: 100000},
+		}},
+	}
+	writeJobM3U(job)
+
+	result := job.Result.M3U
+	if result == nil || result.File != "Playlists/Road_Trip.m3u8" || result.Entries != 2 || result.Error != "" {
+		t.Fatalf("M3U result = %+v", result)
+	}
+	if len(result.Missing) != 1 || result.Missing[0] != "Nobody - Missing" {
+		t.Errorf("missing = %v, want the track that was not downloaded", result.Missing)
+	}
+
+	data, err := os.ReadFile(absLibraryPath(result.File))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	for _, want := range []string{
+		"#EXTM3U\n#PLAYLIST:Road/Trip\n",
+		"#EXTINF:200,Band - First\n../Band/Album/01 - First.mp3\n",
+		"#EXTINF:180,Other - Second\n../Other/Record/02 - Second.mp3\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("playlist %q does not contain %q", content, want)
+		}
+	}
+	if strings.Contains(content, "Missing") {
+		t.Errorf("playlist lists a missing track: %q", content)
+	}
+}
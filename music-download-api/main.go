@@ -32,6 +32,33 @@ type SpotifyToken struct {
 
 type ProcessUrlsRequest struct {
 	URLs []string `json:"urls"`
+	// SpotifyUser é o ID de uma conta vinculada em /auth/spotify/login, necessário para
+	// playlists privadas e para as músicas curtidas.
+	SpotifyUser string `json:"spotify_user,omitempty"`
+	// YouTubeAccount é o ID do canal vinculado em /auth/youtube/device, necessário para
+	// playlists privadas e para os vídeos curtidos (LL).
+	YouTubeAccount string `json:"youtube_account,omitempty"`
+}
+
+// DownloadRequest é o corpo aceito por /download. PathTemplate e OnConflict
+// sobrescrevem, apenas para esta requisição, a configuração global.
+type DownloadRequest struct {
+	URLs         []string `json:"urls"`
+	PathTemplate string   `json:"path_template,omitempty"`
+	OnConflict   string   `json:"on_conflict,omitempty"`
+	// TagSources associa uma URL de download à origem dos metadados usados nas tags
+	// (URL/URI de faixa do Spotify ou "musicbrainz:<mbid>").
+	TagSources map[string]string `json:"tag_sources,omitempty"`
+	// NavidromePlaylist sobrescreve NAVIDROME_PLAYLIST_SYNC para as playlists desta requisição.
+	NavidromePlaylist *bool `json:"navidrome_playlist,omitempty"`
+	// ArtistReleases associa uma URL de artista do Spotify aos IDs dos lançamentos escolhidos
+	// (campo releases de /process-urls). Sem seleção, todos os álbuns e singles são baixados.
+	ArtistReleases map[string][]string `json:"artist_releases,omitempty"`
+	// SpotifyUser é a conta vinculada usada para ler playlists privadas e músicas curtidas.
+	SpotifyUser string `json:"spotify_user,omitempty"`
+	// YouTubeAccount é a conta vinculada usada para ler playlists privadas e vídeos
+	// curtidos; seus cookies, se enviados, são repassados ao yt-dlp.
+	YouTubeAccount string `json:"youtube_account,omitempty"`
 }
 
 // TrackInfo continua exatamente como antes:
@@ -43,6 +70,8 @@ type TrackInfo struct {
 	Duration   string `json:"duration,omitempty"`
 	Thumbnail  string `json:"thumbnail,omitempty"`
 	TrackCount *int   `json:"track_count,omitempty"`
+	// Releases lista a discografia deduplicada quando o item é um artista do Spotify
+	Releases []ArtistRelease `json:"releases,omitempty"`
 }
 
 // Agora a resposta inclui 4 slices, uma para cada tipo:
@@ -67,6 +96,23 @@ var (
 	mu    sync.Mutex // Mutex para proteger stats
 )
 
+// getEnv retorna a variável de ambiente key ou fallback, se ela estiver vazia.
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvFloat lê uma variável de ambiente numérica, usando fallback se vazia ou inválida.
+func getEnvFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
 func init() {
 	log.Out = os.Stdout
 	log.SetFormatter(&logrus.JSONFormatter{})
@@ -128,6 +174,11 @@ func getAccessToken(service string) (string, error) {
 	}
 }
 
+// isSpotifyURL indica se a URL deve ser baixada pelo spotDL.
+func isSpotifyURL(urlStr string) bool {
+	return strings.Contains(urlStr, "spotify")
+}
+
 // contains verifica se uma slice de strings contém um determinado elemento.
 func contains(slice []string, str string) bool {
 	for _, v := range slice {
@@ -146,6 +197,7 @@ func extractSpotifyID(urlStr string) (string, string, error) {
 	// https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M
 	// https://open.spotify.com/artist/4Z8W4fKeB5YxbusRsdQVPb
 	// https://open.spotify.com/album/3eGXGSSlcUc8EU1ABMxtYZ
+	// https://open.spotify.com/collection/tracks (músicas curtidas)
 
 	parts := strings.Split(urlStr, "/")
 	if len(parts) < 2 {
@@ -154,7 +206,7 @@ func extractSpotifyID(urlStr string) (string, string, error) {
 
 	var itemType, itemID string
 	for i, part := range parts {
-		if part == "track" || part == "playlist" || part == "artist" || part == "album" {
+		if part == "track" || part == "playlist" || part == "artist" || part == "album" || part == "collection" {
 			itemType = part
 			if i+1 < len(parts) {
 				itemID = strings.Split(parts[i+1], "?")[0] // Remove query parameters
@@ -170,9 +222,16 @@ func extractSpotifyID(urlStr string) (string, string, error) {
 	return itemType, itemID, nil
 }
 
-// getSpotifyItemInfo obtém informações de um item do Spotify
-func getSpotifyItemInfo(itemType, itemID string) (*TrackInfo, error) {
-	token, err := getAccessToken("spotify")
+// getSpotifyItemInfo obtém informações de um item do Spotify. Com spotifyUser, usa o
+// token da conta vinculada, o que permite ler playlists privadas e as músicas curtidas.
+func getSpotifyItemInfo(itemType, itemID, spotifyUser string) (*TrackInfo, error) {
+	var token string
+	var err error
+	if spotifyUser != "" {
+		token, err = spotifyUserToken(spotifyUser)
+	} else {
+		token, err = getAccessToken("spotify")
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -187,6 +246,11 @@ func getSpotifyItemInfo(itemType, itemID string) (*TrackInfo, error) {
 		endpoint = fmt.Sprintf("https://api.spotify.com/v1/artists/%s", itemID)
 	case "album":
 		endpoint = fmt.Sprintf("https://api.spotify.com/v1/albums/%s", itemID)
+	case "collection":
+		if spotifyUser == "" {
+			return nil, fmt.Errorf("liked songs require a linked Spotify user")
+		}
+		endpoint = "https://api.spotify.com/v1/me/tracks?limit=1"
 	default:
 		return nil, fmt.Errorf("unsupported Spotify item type: %s", itemType)
 	}
@@ -204,6 +268,10 @@ func getSpotifyItemInfo(itemType, itemID string) (*TrackInfo, error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("spotify returned status %d for %s", resp.StatusCode, endpoint)
+	}
+
 	var result map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, err
@@ -265,6 +333,12 @@ func getSpotifyItemInfo(itemType, itemID string) (*TrackInfo, error) {
 				}
 			}
 		}
+		releases, err := getArtistDiscography(itemID, discographyAlbumGroups)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to list releases of artist %s", itemID)
+		} else {
+			trackInfo.Releases = releases
+		}
 
 	case "album":
 		if name, ok := result["name"].(string); ok {
@@ -283,6 +357,15 @@ func getSpotifyItemInfo(itemType, itemID string) (*TrackInfo, error) {
 				trackInfo.TrackCount = &count
 			}
 		}
+
+	case "collection":
+		// As músicas curtidas são tratadas como uma playlist da conta
+		trackInfo.Type = "playlist"
+		trackInfo.Title = "Liked Songs"
+		if total, ok := result["total"].(float64); ok {
+			count := int(total)
+			trackInfo.TrackCount = &count
+		}
 	}
 
 	return trackInfo, nil
@@ -343,26 +426,17 @@ func getYouTubeVideoInfo(videoID string) (*TrackInfo, error) {
 	return trackInfo, nil
 }
 
-// getYouTubePlaylistInfo obtém informações de uma playlist do YouTube
-func getYouTubePlaylistInfo(playlistID string) (*TrackInfo, error) {
-	apiKey, err := getAccessToken("youtube")
-	if err != nil {
-		return nil, err
+// getYouTubePlaylistInfo obtém informações de uma playlist do YouTube. Com
+// youtubeAccount, usa o token da conta vinculada, o que permite ler playlists privadas e
+// os vídeos curtidos (LL).
+func getYouTubePlaylistInfo(playlistID, youtubeAccount string) (*TrackInfo, error) {
+	if playlistID == youtubeLikedPlaylist {
+		return getYouTubeLikedInfo(youtubeAccount)
 	}
 
-	endpoint := fmt.Sprintf(
-		"https://www.googleapis.com/youtube/v3/playlists?part=snippet,contentDetails&id=%s&key=%s",
-		playlistID, apiKey,
-	)
-
-	resp, err := http.Get(endpoint)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
 	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	params := url.Values{"part": {"snippet,contentDetails"}, "id": {playlistID}}
+	if err := youtubeAccountGet(youtubeAccount, "playlists", params, &result); err != nil {
 		return nil, err
 	}
 
@@ -403,6 +477,33 @@ func getYouTubePlaylistInfo(playlistID string) (*TrackInfo, error) {
 	return trackInfo, nil
 }
 
+// getYouTubeLikedInfo descreve a playlist de vídeos curtidos da conta vinculada, que não
+// aparece em playlists.list.
+func getYouTubeLikedInfo(youtubeAccount string) (*TrackInfo, error) {
+	if youtubeAccount == "" {
+		return nil, fmt.Errorf("liked videos require a linked YouTube account")
+	}
+
+	var result struct {
+		PageInfo struct {
+			TotalResults int `json:"totalResults"`
+		} `json:"pageInfo"`
+	}
+	params := url.Values{"part": {"id"}, "playlistId": {youtubeLikedPlaylist}, "maxResults": {"0"}}
+	if err := youtubeAccountGet(youtubeAccount, "playlistItems", params, &result); err != nil {
+		return nil, err
+	}
+
+	count := result.PageInfo.TotalResults
+	return &TrackInfo{
+		URL:        fmt.Sprintf("https://www.youtube.com/playlist?list=%s", youtubeLikedPlaylist),
+		Title:      "Liked videos",
+		Platform:   "youtube",
+		Type:       "playlist",
+		TrackCount: &count,
+	}, nil
+}
+
 // parseYouTubeDuration converte duração ISO 8601 para formato MM:SS
 func parseYouTubeDuration(duration string) string {
 	// Exemplo: PT4M13S -> 4:13
@@ -487,6 +588,14 @@ func processUrls(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No URLs provided"})
 		return
 	}
+	if request.SpotifyUser != "" && !spotifyUserLinked(request.SpotifyUser) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Spotify user is not linked"})
+		return
+	}
+	if request.YouTubeAccount != "" && !youtubeAccountLinked(request.YouTubeAccount) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "YouTube account is not linked"})
+		return
+	}
 
 	// 2) Cria 4 fatias distintas e o mutex
 	var (
@@ -519,7 +628,7 @@ func processUrls(c *gin.Context) {
 					log.WithError(extractErr).Errorf("Failed to extract Spotify ID from URL: %s", urlItem)
 					return
 				}
-				trackInfo, err = getSpotifyItemInfo(itemType, itemID)
+				trackInfo, err = getSpotifyItemInfo(itemType, itemID, request.SpotifyUser)
 				if err != nil {
 					log.WithError(err).Errorf("Failed to get Spotify info for URL: %s", urlItem)
 					return
@@ -533,7 +642,7 @@ func processUrls(c *gin.Context) {
 					return
 				}
 				if itemType == "playlist" {
-					trackInfo, err = getYouTubePlaylistInfo(itemID)
+					trackInfo, err = getYouTubePlaylistInfo(itemID, request.YouTubeAccount)
 				} else {
 					trackInfo, err = getYouTubeVideoInfo(itemID)
 				}
@@ -850,9 +959,7 @@ func search(c *gin.Context) {
 // downloadMusic recebe um JSON com URLs de vídeos/links do Spotify e inicia múltiplos downloads
 // em paralelo (um worker por URL). A saída de cada comando é streamada de volta ao cliente.
 func downloadMusic(c *gin.Context) {
-	var request struct {
-		URLs []string `json:"urls"`
-	}
+	var request DownloadRequest
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		log.WithError(err).Error("Invalid request body")
@@ -865,6 +972,26 @@ func downloadMusic(c *gin.Context) {
 		return
 	}
 
+	pathTemplate, onConflict, err := resolvePathOptions(request.PathTemplate, request.OnConflict)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.SpotifyUser != "" && !spotifyUserLinked(request.SpotifyUser) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Spotify user is not linked"})
+		return
+	}
+	if request.YouTubeAccount != "" && !youtubeAccountLinked(request.YouTubeAccount) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "YouTube account is not linked"})
+		return
+	}
+	opts := downloadOptions{
+		PathTemplate:   pathTemplate,
+		OnConflict:     onConflict,
+		SpotifyUser:    request.SpotifyUser,
+		YouTubeAccount: request.YouTubeAccount,
+	}
+
 	// Headers para streaming de resposta
 	c.Writer.Header().Set("Content-Type", "text/plain")
 	c.Writer.Header().Set("Transfer-Encoding", "chunked")
@@ -882,71 +1009,52 @@ func downloadMusic(c *gin.Context) {
 		go func(urlStr string) {
 			defer wg.Done()
 
-			var cmd *exec.Cmd
-			if strings.Contains(urlStr, "spotify") {
-				cmd = exec.Command("docker", "exec", "-i", "spotDL", "spotdl", urlStr)
-			} else {
-				cmd = exec.Command(
-					"docker", "exec", "-i", "yt-dlp",
-					"yt-dlp", "-f", "bestaudio", "--extract-audio",
-					"--audio-format", "mp3", "--progress",
-					"-o", "/downloads/%(title)s.%(ext)s", urlStr,
-				)
-			}
-
-			stdout, err := cmd.StdoutPipe()
-			if err != nil {
-				log.WithError(err).Error("Failed to create stdout pipe")
+			emit := func(message string) {
 				mu.Lock()
-				c.Writer.Write([]byte(fmt.Sprintf("Failed to start download for URL %s\n", urlStr)))
+				defer mu.Unlock()
+				if _, err := c.Writer.Write([]byte(fmt.Sprintf("[%s] %s\n", urlStr, message))); err != nil {
+					log.WithError(err).Error("Failed to write chunk to client")
+					return
+				}
 				c.Writer.Flush()
-				mu.Unlock()
-				return
 			}
 
-			stderr, err := cmd.StderrPipe()
-			if err != nil {
-				log.WithError(err).Error("Failed to create stderr pipe")
-				mu.Lock()
-				c.Writer.Write([]byte(fmt.Sprintf("Failed to start download for URL %s\n", urlStr)))
-				c.Writer.Flush()
-				mu.Unlock()
-				return
-			}
+			job := createJob(urlStr)
+			emit(fmt.Sprintf("Job %s queued", job.ID))
 
-			if err := cmd.Start(); err != nil {
-				log.WithError(err).Error("Failed to start download command")
-				mu.Lock()
-				c.Writer.Write([]byte(fmt.Sprintf("Failed to start download for URL %s\n", urlStr)))
-				c.Writer.Flush()
-				mu.Unlock()
-				return
+			syncPlaylist := navidromePlaylistSync
+			if request.NavidromePlaylist != nil {
+				syncPlaylist = *request.NavidromePlaylist
+			}
+			if syncPlaylist && strings.Contains(urlStr, "playlist") {
+				updateJob(job, func(j *DownloadJob) { j.NavidromePlaylist = true })
 			}
 
-			scanner := bufio.NewScanner(io.MultiReader(stdout, stderr))
-			for scanner.Scan() {
-				chunk := fmt.Sprintf("[%s] %s\n", urlStr, scanner.Text())
-				mu.Lock()
-				if _, err := c.Writer.Write([]byte(chunk)); err != nil {
-					log.WithError(err).Error("Failed to write chunk to client")
-					mu.Unlock()
-					return
+			// Metadados informados explicitamente para esta URL
+			if source, ok := request.TagSources[urlStr]; ok {
+				meta, err := fetchTrackMetadata(source)
+				if err != nil {
+					log.WithError(err).Errorf("Failed to fetch metadata from %s", source)
+					emit(fmt.Sprintf("Failed to fetch metadata from %s: %s", source, err.Error()))
+				} else {
+					updateJob(job, func(j *DownloadJob) { j.Metadata = meta })
 				}
-				c.Writer.Flush()
-				mu.Unlock()
 			}
 
-			if err := cmd.Wait(); err != nil {
-				log.WithError(err).Error("Download command failed")
-				mu.Lock()
-				c.Writer.Write([]byte(fmt.Sprintf("Download failed for URL %s: %s\n", urlStr, err.Error())))
-				c.Writer.Flush()
-				mu.Unlock()
-				return
+			var err error
+			if isSpotifyArtistURL(urlStr) {
+				err = runArtistDownload(job, request.ArtistReleases[urlStr], opts, emit)
+			} else {
+				err = runDownload(job, opts, emit)
 			}
+			finishJob(job, err)
 
 			mu.Lock()
-			c.Writer.Write([]byte(fmt.Sprintf("Download completed successfully for URL %s\n", urlStr)))
+			if err != nil {
+				c.Writer.Write([]byte(fmt.Sprintf("Download failed for URL %s: %s\n", urlStr, err.Error())))
+			} else {
+				c.Writer.Write([]byte(fmt.Sprintf("Download completed successfully for URL %s\n", urlStr)))
+			}
 			c.Writer.Flush()
 			mu.Unlock()
 		}(downloadURL)
@@ -967,18 +1075,289 @@ func downloadMusic(c *gin.Context) {
 	mu.Unlock()
 }
 
+// downloadOptions agrupa as opções de saída resolvidas para uma requisição de download.
+// SpotifyUser e YouTubeAccount são as contas vinculadas usadas para listar playlists
+// privadas e curtidas; os cookies da conta do YouTube também vão para o yt-dlp.
+type downloadOptions struct {
+	PathTemplate   *PathTemplate
+	OnConflict     string
+	SpotifyUser    string
+	YouTubeAccount string
+}
+
+// downloadedFileMarker prefixa as linhas em que o yt-dlp informa, em JSON, o arquivo
+// final gravado e os dados do vídeo usados no casamento de metadados.
+const downloadedFileMarker = "__FILE__:"
+
+// ytDlpFileInfo é o JSON impresso pelo yt-dlp após mover cada arquivo.
+type ytDlpFileInfo struct {
+	ID       string  `json:"id"`
+	Title    string  `json:"title"`
+	Channel  string  `json:"channel"`
+	Uploader string  `json:"uploader"`
+	Artist   string  `json:"artist"`
+	Track    string  `json:"track"`
+	Duration float64 `json:"duration"`
+	Filepath string  `json:"filepath"`
+}
+
+// runDownload executa o download de um job no container adequado (spotDL ou yt-dlp),
+// repassando cada linha de saída para emit, e em seguida roda o pós-processamento.
+func runDownload(job *DownloadJob, opts downloadOptions, emit func(string)) error {
+	updateJob(job, func(j *DownloadJob) { j.Status = jobRunning })
+
+	// O spotDL não informa os caminhos gravados: ele grava na pasta temporária do job,
+	// cujos arquivos são movidos para a biblioteca ao final. Os downloaders não sabem
+	// renomear em caso de colisão nem informam quando pulam um arquivo existente, então
+	// com rename e skip o yt-dlp também usa a pasta temporária
+	staged := job.Platform == "spotify" || opts.OnConflict != conflictOverwrite
+	if staged {
+		defer os.RemoveAll(absLibraryPath(stagingDir(job.ID)))
+	}
+
+	// spotDL e yt-dlp só enxergam o que é público: com uma conta vinculada, as faixas da
+	// coleção são listadas com o token do usuário e passadas ao downloader uma a uma
+	account := opts.SpotifyUser
+	if job.Platform == "youtube" {
+		account = opts.YouTubeAccount
+	}
+	var entries []PlaylistEntry
+	listed := false
+	sources := []string{job.URL}
+	if account != "" && isCollectionURL(job.URL) {
+		name, collectionEntries, err := expandPlaylist(job.URL, opts.SpotifyUser, opts.YouTubeAccount)
+		if err != nil {
+			return fmt.Errorf("failed to list tracks of %s: %w", job.URL, err)
+		}
+		if len(collectionEntries) == 0 {
+			return fmt.Errorf("no tracks to download in %s", job.URL)
+		}
+		entries, listed = collectionEntries, true
+		updateJob(job, func(j *DownloadJob) { j.Collection = &JobCollection{Name: name, Entries: entries} })
+		sources = sources[:0]
+		for _, entry := range entries {
+			sources = append(sources, entry.URL)
+		}
+		emit(fmt.Sprintf("Listed %d tracks of %q", len(entries), name))
+	}
+
+	ytDlpOutput := opts.PathTemplate.YtDlp()
+	if staged {
+		ytDlpOutput = opts.PathTemplate.YtDlpIn(stagingDir(job.ID))
+	}
+
+	var cmd *exec.Cmd
+	if job.Platform == "spotify" {
+		args := append([]string{"exec", "-i", "spotDL", "spotdl"}, sources...)
+		args = append(args, "--output", opts.PathTemplate.SpotDLIn(stagingDir(job.ID)))
+		args = append(args, spotDLConflictArgs(opts.OnConflict)...)
+		cmd = exec.Command("docker", args...)
+	} else {
+		args := []string{
+			"exec", "-i", "yt-dlp",
+			"yt-dlp", "-f", "bestaudio", "--extract-audio",
+			"--audio-format", "mp3", "--progress", "--no-quiet",
+			"-o", ytDlpOutput,
+			"--print", "after_move:" + downloadedFileMarker + "%(.{id,title,channel,uploader,artist,track,duration,filepath})j",
+		}
+		args = append(args, ytDlpConflictArgs(opts.OnConflict)...)
+		args = append(args, ytDlpCookiesArgs(opts.YouTubeAccount)...)
+		cmd = exec.Command("docker", append(args, sources...)...)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		log.WithError(err).Error("Failed to create stdout pipe")
+		return fmt.Errorf("failed to start download: %w", err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		log.WithError(err).Error("Failed to create stderr pipe")
+		return fmt.Errorf("failed to start download: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		log.WithError(err).Error("Failed to start download command")
+		return fmt.Errorf("failed to start download: %w", err)
+	}
+
+	scanner := bufio.NewScanner(io.MultiReader(stdout, stderr))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, downloadedFileMarker) {
+			var info ytDlpFileInfo
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, downloadedFileMarker)), &info); err != nil {
+				log.WithError(err).Errorf("Failed to decode yt-dlp file info: %s", line)
+				continue
+			}
+			channel := info.Channel
+			if channel == "" {
+				channel = info.Uploader
+			}
+			track := &JobTrack{
+				File:         containerToLibraryPath(info.Filepath),
+				SourceID:     info.ID,
+				SourceTitle:  info.Title,
+				SourceArtist: info.Artist,
+				SourceTrack:  info.Track,
+				Channel:      channel,
+				DurationSec:  info.Duration,
+			}
+			addJobTrack(job, track)
+			emit(fmt.Sprintf("Saved %s", track.File))
+			continue
+		}
+		emit(line)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		log.WithError(err).Error("Download command failed")
+		return err
+	}
+
+	if staged {
+		moved, err := moveStagedFiles(job.ID, opts.OnConflict)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to move files of job %s", job.ID)
+			return fmt.Errorf("failed to move downloaded files: %w", err)
+		}
+		// O yt-dlp já registrou as faixas com o caminho temporário; as do spotDL são novas
+		for _, file := range moved {
+			found := false
+			updateJob(job, func(j *DownloadJob) {
+				for _, track := range j.Tracks {
+					if track.File == file.From {
+						track.File = file.To
+						track.Skipped = file.Skipped
+						found = true
+					}
+				}
+				for i, f := range j.Files {
+					if f == file.From {
+						j.Files[i] = file.To
+					}
+				}
+			})
+			if !found {
+				addJobTrack(job, &JobTrack{File: file.To, Skipped: file.Skipped})
+			}
+			if file.Skipped {
+				emit(fmt.Sprintf("Skipped %s, already in the library", file.To))
+			}
+		}
+	}
+
+	// Playlists e álbuns: guarda a ordem original e associa os arquivos às faixas de origem
+	if !listed && isCollectionURL(job.URL) {
+		name, collectionEntries, err := expandPlaylist(job.URL, opts.SpotifyUser, opts.YouTubeAccount)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to list tracks of %s", job.URL)
+		} else {
+			entries = collectionEntries
+			updateJob(job, func(j *DownloadJob) { j.Collection = &JobCollection{Name: name, Entries: entries} })
+		}
+	}
+	linkJobTracks(job, entries)
+
+	matchJobTracks(job, emit)
+	tagJobFiles(job, emit)
+	processJobLoudness(job, emit)
+	fetchJobLyrics(job, emit)
+	indexJobTracks(job)
+	fingerprintJobFiles(job)
+	writeJobM3U(job)
+
+	if len(job.Tracks) > 0 {
+		scheduleLibraryScan(job)
+	}
+	return nil
+}
+
 func main() {
 	r := gin.Default()
 
 	// Rota principal de busca (YouTube + Spotify, incluindo parâmetros de query)
 	r.GET("/search", search)
 
+	// Busca com resposta normalizada e versionada (ver search.go)
+	r.GET("/v2/search", searchV2)
+
+	// Sugestões para o campo de busca (histórico, biblioteca e autocompletar externo)
+	r.GET("/suggest", suggest)
+
+	// Rotas de descoberta (Spotify), no mesmo formato de /v2/search
+	r.GET("/discover/related-artists", discoverRelatedArtists)
+	r.GET("/discover/recommendations", discoverRecommendations)
+	r.GET("/discover/new-releases", discoverNewReleases)
+
+	// Contas do Spotify vinculadas (OAuth com PKCE) para playlists privadas e músicas curtidas
+	r.GET("/auth/spotify/login", spotifyAuthLogin)
+	r.GET("/auth/spotify/callback", spotifyAuthCallback)
+	r.GET("/auth/spotify/users", listSpotifyUsers)
+	r.GET("/auth/spotify/users/:id/playlists", listSpotifyUserPlaylists)
+	r.DELETE("/auth/spotify/users/:id", deleteSpotifyUser)
+
+	// Contas do YouTube vinculadas (fluxo de dispositivo do Google) e cookies do yt-dlp
+	r.POST("/auth/youtube/device", startYouTubeLogin)
+	r.GET("/auth/youtube/device/:id", getYouTubeLogin)
+	r.GET("/auth/youtube/accounts", listYouTubeAccounts)
+	r.DELETE("/auth/youtube/accounts/:id", deleteYouTubeAccount)
+	r.PUT("/auth/youtube/accounts/:id/cookies", putYouTubeCookies)
+	r.DELETE("/auth/youtube/accounts/:id/cookies", deleteYouTubeCookies)
+
 	// Rota de processamento de URLs
 	r.POST("/process-urls", processUrls)
 
 	// Rota de download
 	r.POST("/download", downloadMusic)
 
+	// Rotas de acompanhamento dos jobs de download
+	r.GET("/jobs", listJobs)
+	r.GET("/jobs/:id", getJob)
+
+	// Fila de revisão dos casamentos de metadados de baixa confiança
+	r.GET("/review", listReviews)
+	r.POST("/review/:id/accept", acceptReview)
+	r.POST("/review/:id/skip", skipReview)
+
+	// Rota de busca de letras
+	r.GET("/lyrics", getLyrics)
+
+	// Assinaturas de playlists com sincronização agendada
+	r.GET("/subscriptions", listSubscriptions)
+	r.POST("/subscriptions", createSubscription)
+	r.GET("/subscriptions/:id", getSubscription)
+	r.DELETE("/subscriptions/:id", deleteSubscription)
+	r.POST("/subscriptions/:id/sync", syncSubscriptionNow)
+
+	// Artistas seguidos: novos lançamentos são baixados automaticamente
+	r.GET("/follows", listFollows)
+	r.POST("/follows", createFollow)
+	r.GET("/follows/:id", getFollow)
+	r.DELETE("/follows/:id", deleteFollow)
+	r.POST("/follows/:id/check", checkFollowNow)
+
+	// Biblioteca local: navegação pelos arquivos já baixados
+	r.GET("/library/artists", listLibraryArtists)
+	r.GET("/library/albums", listLibraryAlbums)
+	r.GET("/library/tracks", listLibraryTracks)
+	r.GET("/library/tracks/:id", getLibraryTrack)
+	r.GET("/library/tracks/:id/stream", streamLibraryTrack)
+	r.DELETE("/library/tracks/:id", deleteLibraryTrack)
+	r.POST("/library/tracks/:id/move", moveLibraryTrack)
+	r.POST("/library/tracks/:id/retag", retagLibraryTrack)
+	r.DELETE("/library/albums/:id", deleteLibraryAlbum)
+	r.POST("/library/import", importLibrary)
+	r.GET("/library/duplicates", listDuplicates)
+	r.POST("/library/duplicates/scan", scanDuplicates)
+
+	if err := validateLyricsMode(lyricsMode); err != nil {
+		log.WithError(err).Fatal("Invalid lyrics configuration")
+	}
+
+	startScheduler()
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "3333"
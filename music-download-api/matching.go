@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	autoMatchEnabled = getEnv("AUTO_MATCH_ENABLED", "true") == "true"
+	matchMinScore    = getEnvFloat("MATCH_MIN_SCORE", 0.85)
+	matchProviders   = strings.Split(getEnv("MATCH_PROVIDERS", "spotify,musicbrainz"), ",")
+)
+
+// titleNoisePattern captura trechos entre parênteses/colchetes que não fazem parte do nome
+// da música, como "(Official Video)" ou "[Clipe Oficial]".
+var titleNoisePattern = regexp.MustCompile(
+	`(?i)\s*[\(\[][^\)\]]*\b(official|oficial|video|vídeo|clipe|audio|áudio|lyrics?|letra|visuali[sz]er|hd|hq|4k|mv)\b[^\)\]]*[\)\]]`,
+)
+
+// featPattern remove participações ("feat. X", "ft. X") do nome da música.
+var featPattern = regexp.MustCompile(`(?i)\s*[\(\[]?\b(feat|ft|featuring)\b\.?\s+[^\)\]]*[\)\]]?`)
+
+// remasterPattern remove sufixos de remasterização usados pelo Spotify
+// ("Song - Remastered 2011", "Song (2011 Remaster)").
+var remasterPattern = regexp.MustCompile(`(?i)\s*(-\s*[^-]*remaster[^-]*$|[\(\[][^\)\]]*remaster[^\)\]]*[\)\]])`)
+
+// ParsedTitle é o resultado da análise do título de um vídeo.
+type ParsedTitle struct {
+	Artist string `json:"artist"`
+	Title  string `json:"title"`
+}
+
+// parseVideoTitle separa "Artista - Música (Official Video)" em artista e música.
+// Sem separador, o artista vem do canal (sem sufixos como " - Topic" ou "VEVO").
+func parseVideoTitle(title, channel string) ParsedTitle {
+	cleaned := titleNoisePattern.ReplaceAllString(title, "")
+	if idx := strings.Index(cleaned, " | "); idx > 0 {
+		cleaned = cleaned[:idx]
+	}
+
+	for _, sep := range []string{" - ", " – ", " — ", " ~ "} {
+		if artist, song, ok := strings.Cut(cleaned, sep); ok {
+			return ParsedTitle{
+				Artist: strings.TrimSpace(featPattern.ReplaceAllString(artist, "")),
+				Title:  strings.Trim(strings.TrimSpace(featPattern.ReplaceAllString(song, "")), `"'`),
+			}
+		}
+	}
+
+	artist := strings.TrimSuffix(channel, " - Topic")
+	artist = strings.TrimSuffix(artist, "VEVO")
+	artist = strings.TrimSuffix(artist, " Official")
+	return ParsedTitle{
+		Artist: strings.TrimSpace(artist),
+		Title:  strings.Trim(strings.TrimSpace(featPattern.ReplaceAllString(cleaned, "")), `"'`),
+	}
+}
+
+// normalizeText deixa o texto comparável: minúsculas, sem acentos e só letras/dígitos.
+func normalizeText(s string) string {
+	decomposed := norm.NFD.String(strings.ToLower(s))
+	var b strings.Builder
+	lastSpace := true
+	for _, r := range decomposed {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+			lastSpace = false
+		case !lastSpace:
+			b.WriteByte(' ')
+			lastSpace = true
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// levenshtein calcula a distância de edição entre duas strings (em runas).
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// stringSimilarity retorna uma nota de 0 a 1 entre dois textos, combinando distância de
+// edição e sobreposição de palavras (para tolerar palavras extras no título).
+func stringSimilarity(a, b string) float64 {
+	na, nb := normalizeText(a), normalizeText(b)
+	if na == "" || nb == "" {
+		return 0
+	}
+	if na == nb {
+		return 1
+	}
+
+	ra, rb := []rune(na), []rune(nb)
+	editScore := 1 - float64(levenshtein(ra, rb))/float64(max(len(ra), len(rb)))
+
+	wordsA := strings.Fields(na)
+	wordsB := make(map[string]bool)
+	for _, w := range strings.Fields(nb) {
+		wordsB[w] = true
+	}
+	common := 0
+	for _, w := range wordsA {
+		if wordsB[w] {
+			common++
+		}
+	}
+	tokenScore := (float64(common)/float64(len(wordsA)) + float64(common)/float64(len(wordsB))) / 2
+
+	return math.Max(editScore, tokenScore)
+}
+
+// durationSimilarity dá 1 para durações iguais e cai linearmente até 0 em 30s de diferença.
+func durationSimilarity(aSec, bSec float64) float64 {
+	if aSec <= 0 || bSec <= 0 {
+		return 0.5
+	}
+	return math.Max(0, 1-math.Abs(aSec-bSec)/30)
+}
+
+// MatchCandidate é um possível casamento de metadados com sua pontuação.
+type MatchCandidate struct {
+	Metadata      TrackMetadata `json:"metadata"`
+	Score         float64       `json:"score"`
+	TitleScore    float64       `json:"title_score"`
+	ArtistScore   float64       `json:"artist_score"`
+	DurationScore float64       `json:"duration_score"`
+}
+
+// scoreCandidate pondera título, artista e duração em uma nota final de 0 a 1.
+func scoreCandidate(parsed ParsedTitle, durationSec float64, meta TrackMetadata) MatchCandidate {
+	artistScore := 0.0
+	for _, artist := range meta.Artists {
+		artistScore = math.Max(artistScore, stringSimilarity(parsed.Artist, artist))
+	}
+	// Sem artista identificado no título, a nota do artista fica neutra
+	if parsed.Artist == "" {
+		artistScore = 0.5
+	}
+
+	candidate := MatchCandidate{
+		Metadata:      meta,
+		TitleScore:    stringSimilarity(parsed.Title, remasterPattern.ReplaceAllString(meta.Title, "")),
+		ArtistScore:   artistScore,
+		DurationScore: durationSimilarity(durationSec, float64(meta.DurationMs)/1000),
+	}
+	candidate.Score = 0.5*candidate.TitleScore + 0.3*candidate.ArtistScore + 0.2*candidate.DurationScore
+	return candidate
+}
+
+// searchSpotifyCandidates busca faixas no Spotify pelo artista e título extraídos.
+func searchSpotifyCandidates(parsed ParsedTitle) ([]TrackMetadata, error) {
+	query := fmt.Sprintf("track:%s", parsed.Title)
+	if parsed.Artist != "" {
+		query += fmt.Sprintf(" artist:%s", parsed.Artist)
+	}
+
+	var result struct {
+		Tracks struct {
+			Items []spotifyTrackObject `json:"items"`
+		} `json:"tracks"`
+	}
+	endpoint := fmt.Sprintf("https://api.spotify.com/v1/search?q=%s&type=track&limit=5", url.QueryEscape(query))
+	if err := spotifyGet(endpoint, &result); err != nil {
+		return nil, err
+	}
+
+	candidates := make([]TrackMetadata, 0, len(result.Tracks.Items))
+	for _, item := range result.Tracks.Items {
+		candidates = append(candidates, *spotifyTrackToMetadata(item))
+	}
+	return candidates, nil
+}
+
+// searchMusicBrainzCandidates busca gravações no MusicBrainz pelo artista e título extraídos.
+func searchMusicBrainzCandidates(parsed ParsedTitle) ([]TrackMetadata, error) {
+	query := fmt.Sprintf(`recording:"%s"`, parsed.Title)
+	if parsed.Artist != "" {
+		query += fmt.Sprintf(` AND artist:"%s"`, parsed.Artist)
+	}
+
+	var result struct {
+		Recordings []musicBrainzRecording `json:"recordings"`
+	}
+	endpoint := fmt.Sprintf("https://musicbrainz.org/ws/2/recording?query=%s&limit=5&fmt=json", url.QueryEscape(query))
+	if err := musicBrainzGet(endpoint, &result); err != nil {
+		return nil, err
+	}
+
+	candidates := make([]TrackMetadata, 0, len(result.Recordings))
+	for _, rec := range result.Recordings {
+		candidates = append(candidates, *musicBrainzRecordingToMetadata(rec))
+	}
+	return candidates, nil
+}
+
+// findMatchCandidates consulta os provedores configurados e ordena os candidatos pela nota.
+func findMatchCandidates(parsed ParsedTitle, durationSec float64) []MatchCandidate {
+	var scored []MatchCandidate
+	for _, provider := range matchProviders {
+		var metas []TrackMetadata
+		var err error
+
+		switch strings.TrimSpace(provider) {
+		case "spotify":
+			metas, err = searchSpotifyCandidates(parsed)
+		case "musicbrainz":
+			metas, err = searchMusicBrainzCandidates(parsed)
+		default:
+			continue
+		}
+		if err != nil {
+			log.WithError(err).Warnf("Failed to search %s for %q", provider, parsed.Title)
+			continue
+		}
+
+		for _, meta := range metas {
+			scored = append(scored, scoreCandidate(parsed, durationSec, meta))
+		}
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].Score > scored[j].Score
+	})
+	return scored
+}
+
+// completeMetadata troca um candidato de busca pelos metadados completos do provedor
+// (o resultado de busca do Spotify, por exemplo, não traz gênero).
+func completeMetadata(meta TrackMetadata) *TrackMetadata {
+	var full *TrackMetadata
+	var err error
+	switch meta.Source {
+	case "spotify":
+		full, err = getSpotifyTrackMetadata(meta.SourceID)
+	case "musicbrainz":
+		full, err = getMusicBrainzRecordingMetadata(meta.SourceID)
+	default:
+		return &meta
+	}
+	if err != nil {
+		log.WithError(err).Warnf("Failed to complete %s metadata for %s", meta.Source, meta.SourceID)
+		return &meta
+	}
+	return full
+}
+
+// matchJobTracks tenta casar cada faixa do YouTube sem metadados com Spotify/MusicBrainz.
+// Casamentos com nota abaixo de MATCH_MIN_SCORE vão para a fila de revisão.
+func matchJobTracks(job *DownloadJob, emit func(string)) {
+	if !autoMatchEnabled || job.Platform != "youtube" || job.Metadata != nil {
+		return
+	}
+
+	// As faixas são lidas de uma cópia e alteradas pelo índice, sob o mutex do registro
+	snapshot := snapshotJob(job)
+	for i, track := range snapshot.Tracks {
+		if track.Skipped || track.Metadata != nil {
+			continue
+		}
+
+		parsed := parseVideoTitle(track.SourceTitle, track.Channel)
+		if track.SourceArtist != "" && track.SourceTrack != "" {
+			// O YouTube Music já informa artista e faixa; são mais confiáveis que o título
+			parsed = ParsedTitle{Artist: track.SourceArtist, Title: track.SourceTrack}
+		}
+
+		candidates := findMatchCandidates(parsed, track.DurationSec)
+		var jobTrack *JobTrack
+		updateJob(job, func(j *DownloadJob) {
+			jobTrack = j.Tracks[i]
+			jobTrack.Parsed = &parsed
+		})
+
+		if len(candidates) == 0 {
+			emit(fmt.Sprintf("No metadata match for %s", track.File))
+			continue
+		}
+
+		best := candidates[0]
+		if best.Score >= matchMinScore {
+			meta := completeMetadata(best.Metadata)
+			updateJob(job, func(j *DownloadJob) {
+				jobTrack.Metadata = meta
+				jobTrack.MatchScore = best.Score
+			})
+			emit(fmt.Sprintf("Matched %s to %s - %s (%s, score %s)",
+				track.File, strings.Join(meta.Artists, ", "), meta.Title, meta.Source,
+				strconv.FormatFloat(best.Score, 'f', 2, 64)))
+			continue
+		}
+
+		item := enqueueReview(job, jobTrack, parsed, candidates)
+		emit(fmt.Sprintf("Low-confidence match for %s (score %s), queued for review as %s",
+			track.File, strconv.FormatFloat(best.Score, 'f', 2, 64), item.ID))
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseVideoTitle(t *testing.T) {
+	tests := []struct {
+		name    string
+		title   string
+		channel string
+		want    ParsedTitle
+	}{
+		{
+			name:  "artist and title with noise",
+			title: "Daft Punk - Get Lucky (Official Video)",
+			want:  ParsedTitle{Artist: "Daft Punk", Title: "Get Lucky"},
+		},
+		{
+			name:  "en dash and bracketed noise",
+			title: "Anitta – Envolver [Clipe Oficial]",
+			want:  ParsedTitle{Artist: "Anitta", Title: "Envolver"},
+		},
+		{
+			name:  "feat removed from both sides",
+			title: "Calvin Harris feat. Rihanna - This Is What You Came For (ft. Someone)",
+			want:  ParsedTitle{Artist: "Calvin Harris", Title: "This Is What You Came For"},
+		},
+		{
+			name:  "pipe suffix and quotes",
+			title: `Artist - "Song" | Live at Home`,
+			want:  ParsedTitle{Artist: "Artist", Title: "Song"},
+		},
+		{
+			name:    "artist from topic channel",
+			title:   "Song Name",
+			channel: "Some Band - Topic",
+			want:    ParsedTitle{Artist: "Some Band", Title: "Song Name"},
+		},
+		{
+			name:    "artist from vevo channel",
+			title:   "Hello (Official Music Video)",
+			channel: "AdeleVEVO",
+			want:    ParsedTitle{Artist: "Adele", Title: "Hello"},
+		},
+		{
+			name:    "hyphen inside word is not a separator",
+			title:   "Jay-Z",
+			channel: "Uploader",
+			want:    ParsedTitle{Artist: "Uploader", Title: "Jay-Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseVideoTitle(tt.title, tt.channel); got != tt.want {
+				t.Errorf("parseVideoTitle(%q, %q) = %+v, want %+v", tt.title, tt.channel, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Canção Única", "cancao unica"},
+		{"  Hello,   World! ", "hello world"},
+		{"AC/DC", "ac dc"},
+		{"...", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeText(tt.in); got != tt.want {
+			t.Errorf("normalizeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestStringSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{"equal after normalization", "Canção", "cancao", 1},
+		{"empty side", "", "song", 0},
+		{"only punctuation", "!!!", "song", 0},
+		{"one edit", "song", "sing", 0.75},
+		{"extra words", "get lucky", "get lucky radio edit", 0.75},
+		{"unrelated", "abc", "xyz", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stringSimilarity(tt.a, tt.b)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("stringSimilarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if back := stringSimilarity(tt.b, tt.a); math.Abs(back-got) > 1e-9 {
+				t.Errorf("stringSimilarity is not symmetric: %v vs %v", got, back)
+			}
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+var musicBrainzUserAgent = os.Getenv("MUSICBRAINZ_USER_AGENT")
+
+// TrackMetadata é o conjunto de tags que o pipeline escreve nos arquivos baixados.
+type TrackMetadata struct {
+	Source      string   `json:"source"`
+	SourceID    string   `json:"source_id"`
+	Title       string   `json:"title"`
+	Artists     []string `json:"artists"`
+	Album       string   `json:"album,omitempty"`
+	AlbumArtist string   `json:"album_artist,omitempty"`
+	TrackNumber int      `json:"track_number,omitempty"`
+	TrackTotal  int      `json:"track_total,omitempty"`
+	DiscNumber  int      `json:"disc_number,omitempty"`
+	DiscTotal   int      `json:"disc_total,omitempty"`
+	Year        string   `json:"year,omitempty"`
+	Genre       string   `json:"genre,omitempty"`
+	ISRC        string   `json:"isrc,omitempty"`
+	DurationMs  int      `json:"duration_ms,omitempty"`
+	CoverURL    string   `json:"cover_url,omitempty"`
+}
+
+// spotifyImage e spotifyArtistRef são os trechos do payload do Spotify usados aqui.
+type spotifyImage struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+type spotifyArtistRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type spotifyAlbumRef struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	AlbumType   string             `json:"album_type"`
+	ReleaseDate string             `json:"release_date"`
+	TotalTracks int                `json:"total_tracks"`
+	Images      []spotifyImage     `json:"images"`
+	Artists     []spotifyArtistRef `json:"artists"`
+}
+
+type spotifyTrackObject struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	DurationMs  int                `json:"duration_ms"`
+	TrackNumber int                `json:"track_number"`
+	DiscNumber  int                `json:"disc_number"`
+	Explicit    bool               `json:"explicit"`
+	Popularity  int                `json:"popularity"`
+	PreviewURL  string             `json:"preview_url"`
+	Artists     []spotifyArtistRef `json:"artists"`
+	Album       spotifyAlbumRef    `json:"album"`
+	ExternalIDs struct {
+		ISRC string `json:"isrc"`
+	} `json:"external_ids"`
+}
+
+// spotifyGet faz um GET autenticado na API do Spotify e decodifica a resposta em out.
+func spotifyGet(endpoint string, out interface{}) error {
+	token, err := getAccessToken("spotify")
+	if err != nil {
+		return err
+	}
+	return spotifyGetWithToken(token, endpoint, out)
+}
+
+// spotifyGetWithToken faz o GET com um token já obtido (do app ou de um usuário).
+func spotifyGetWithToken(token, endpoint string, out interface{}) error {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("spotify returned status %d for %s", resp.StatusCode, endpoint)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// youtubeGet faz um GET na YouTube Data API com a chave configurada e decodifica a
+// resposta em out.
+func youtubeGet(resource string, params url.Values, out interface{}) error {
+	apiKey, err := getAccessToken("youtube")
+	if err != nil {
+		return err
+	}
+	params.Set("key", apiKey)
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Get("https://www.googleapis.com/youtube/v3/" + resource + "?" + params.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("youtube returned status %d for %s", resp.StatusCode, resource)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// getSpotifyTrackMetadata busca as tags de uma faixa do Spotify, incluindo o gênero
+// do artista principal (faixas do Spotify não têm gênero próprio).
+func getSpotifyTrackMetadata(trackID string) (*TrackMetadata, error) {
+	var track spotifyTrackObject
+	if err := spotifyGet(fmt.Sprintf("https://api.spotify.com/v1/tracks/%s", trackID), &track); err != nil {
+		return nil, err
+	}
+
+	meta := spotifyTrackToMetadata(track)
+
+	if len(track.Artists) > 0 && track.Artists[0].ID != "" {
+		var artist struct {
+			Genres []string `json:"genres"`
+		}
+		if err := spotifyGet(fmt.Sprintf("https://api.spotify.com/v1/artists/%s", track.Artists[0].ID), &artist); err != nil {
+			log.WithError(err).Warnf("Failed to get genres for Spotify artist %s", track.Artists[0].ID)
+		} else if len(artist.Genres) > 0 {
+			meta.Genre = artist.Genres[0]
+		}
+	}
+
+	return meta, nil
+}
+
+// spotifyTrackToMetadata converte um objeto de faixa do Spotify para TrackMetadata.
+func spotifyTrackToMetadata(track spotifyTrackObject) *TrackMetadata {
+	meta := &TrackMetadata{
+		Source:      "spotify",
+		SourceID:    track.ID,
+		Title:       track.Name,
+		Album:       track.Album.Name,
+		TrackNumber: track.TrackNumber,
+		TrackTotal:  track.Album.TotalTracks,
+		DiscNumber:  track.DiscNumber,
+		ISRC:        track.ExternalIDs.ISRC,
+		DurationMs:  track.DurationMs,
+	}
+	for _, artist := range track.Artists {
+		meta.Artists = append(meta.Artists, artist.Name)
+	}
+	if len(track.Album.Artists) > 0 {
+		meta.AlbumArtist = track.Album.Artists[0].Name
+	}
+	if len(track.Album.ReleaseDate) >= 4 {
+		meta.Year = track.Album.ReleaseDate[:4]
+	}
+	if len(track.Album.Images) > 0 {
+		meta.CoverURL = track.Album.Images[0].URL
+	}
+	return meta
+}
+
+// musicBrainzGet faz um GET na API do MusicBrainz, que exige um User-Agent identificável.
+func musicBrainzGet(endpoint string, out interface{}) error {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	userAgent := musicBrainzUserAgent
+	if userAgent == "" {
+		userAgent = "stack-music/1.0 ( https://github.com/PedroCamargo-dev/stack-music )"
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+
+	musicBrainzLimiter.Wait()
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("musicbrainz returned status %d for %s", resp.StatusCode, endpoint)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type musicBrainzArtistCredit struct {
+	Name       string `json:"name"`
+	JoinPhrase string `json:"joinphrase"`
+}
+
+type musicBrainzRecording struct {
+	ID           string                    `json:"id"`
+	Title        string                    `json:"title"`
+	Length       int                       `json:"length"`
+	ISRCs        []string                  `json:"isrcs"`
+	ArtistCredit []musicBrainzArtistCredit `json:"artist-credit"`
+	Genres       []struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	} `json:"genres"`
+	Releases []struct {
+		ID           string                    `json:"id"`
+		Title        string                    `json:"title"`
+		Date         string                    `json:"date"`
+		ArtistCredit []musicBrainzArtistCredit `json:"artist-credit"`
+		Media        []struct {
+			Position   int `json:"position"`
+			TrackCount int `json:"track-count"`
+			Tracks     []struct {
+				Position int `json:"position"`
+			} `json:"tracks"`
+		} `json:"media"`
+	} `json:"releases"`
+}
+
+// getMusicBrainzRecordingMetadata busca as tags de uma gravação do MusicBrainz.
+// A capa vem do Cover Art Archive a partir do primeiro lançamento da gravação.
+func getMusicBrainzRecordingMetadata(recordingID string) (*TrackMetadata, error) {
+	endpoint := fmt.Sprintf(
+		"https://musicbrainz.org/ws/2/recording/%s?inc=artist-credits+releases+media+isrcs+genres&fmt=json",
+		url.PathEscape(recordingID),
+	)
+
+	var rec musicBrainzRecording
+	if err := musicBrainzGet(endpoint, &rec); err != nil {
+		return nil, err
+	}
+
+	return musicBrainzRecordingToMetadata(rec), nil
+}
+
+// musicBrainzRecordingToMetadata converte uma gravação do MusicBrainz para TrackMetadata.
+func musicBrainzRecordingToMetadata(rec musicBrainzRecording) *TrackMetadata {
+	meta := &TrackMetadata{
+		Source:     "musicbrainz",
+		SourceID:   rec.ID,
+		Title:      rec.Title,
+		DurationMs: rec.Length,
+	}
+	for _, credit := range rec.ArtistCredit {
+		meta.Artists = append(meta.Artists, credit.Name)
+	}
+	if len(rec.ISRCs) > 0 {
+		meta.ISRC = rec.ISRCs[0]
+	}
+	bestGenre := 0
+	for _, genre := range rec.Genres {
+		if genre.Count > bestGenre {
+			meta.Genre = genre.Name
+			bestGenre = genre.Count
+		}
+	}
+
+	if len(rec.Releases) > 0 {
+		release := rec.Releases[0]
+		meta.Album = release.Title
+		if len(release.Date) >= 4 {
+			meta.Year = release.Date[:4]
+		}
+		if len(release.ArtistCredit) > 0 {
+			meta.AlbumArtist = release.ArtistCredit[0].Name
+		}
+		if len(release.Media) > 0 {
+			medium := release.Media[0]
+			meta.DiscNumber = medium.Position
+			meta.DiscTotal = len(release.Media)
+			meta.TrackTotal = medium.TrackCount
+			if len(medium.Tracks) > 0 {
+				meta.TrackNumber = medium.Tracks[0].Position
+			}
+		}
+		meta.CoverURL = fmt.Sprintf("https://coverartarchive.org/release/%s/front-500", release.ID)
+	}
+
+	return meta
+}
+
+// parseTagSource interpreta a origem de metadados informada para uma URL:
+// URL/URI de faixa do Spotify, "musicbrainz:<mbid>" ou URL de gravação do MusicBrainz.
+func parseTagSource(source string) (string, string, error) {
+	source = strings.TrimSpace(source)
+
+	switch {
+	case strings.HasPrefix(source, "spotify:track:"):
+		return "spotify", strings.TrimPrefix(source, "spotify:track:"), nil
+	case strings.Contains(source, "spotify.com"):
+		itemType, itemID, err := extractSpotifyID(source)
+		if err != nil {
+			return "", "", err
+		}
+		if itemType != "track" {
+			return "", "", fmt.Errorf("tag source must be a Spotify track, got %s", itemType)
+		}
+		return "spotify", itemID, nil
+	case strings.HasPrefix(source, "musicbrainz:"):
+		return "musicbrainz", strings.TrimPrefix(source, "musicbrainz:"), nil
+	case strings.Contains(source, "musicbrainz.org/recording/"):
+		parts := strings.Split(source, "musicbrainz.org/recording/")
+		id := strings.Split(strings.Split(parts[1], "?")[0], "/")[0]
+		if id == "" {
+			return "", "", fmt.Errorf("could not extract MusicBrainz recording ID")
+		}
+		return "musicbrainz", id, nil
+	}
+
+	return "", "", fmt.Errorf("unsupported tag source: %s", source)
+}
+
+// fetchTrackMetadata busca os metadados conforme a origem informada.
+func fetchTrackMetadata(source string) (*TrackMetadata, error) {
+	provider, id, err := parseTagSource(source)
+	if err != nil {
+		return nil, err
+	}
+	if provider == "spotify" {
+		return getSpotifyTrackMetadata(id)
+	}
+	return getMusicBrainzRecordingMetadata(id)
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// navidromeMatchMinScore é a nota mínima para aceitar uma música do Navidrome como a
+// faixa da playlist de origem.
+const navidromeMatchMinScore = 0.75
+
+// navidromeSongChunk limita quantos songId vão em cada requisição, já que a API Subsonic
+// recebe os parâmetros na URL e playlists grandes estouram o limite (414).
+const navidromeSongChunk = 100
+
+var (
+	navidromeURL          = getEnv("NAVIDROME_URL", "http://navidrome:4533")
+	navidromeUser         = os.Getenv("NAVIDROME_USER")
+	navidromePassword     = os.Getenv("NAVIDROME_PASSWORD")
+	navidromeScanDebounce = time.Duration(getEnvFloat("NAVIDROME_SCAN_DEBOUNCE_SECONDS", 30)) * time.Second
+	navidromeScanMaxWait  = time.Duration(getEnvFloat("NAVIDROME_SCAN_MAX_WAIT_SECONDS", 300)) * time.Second
+	navidromeScanTimeout  = time.Duration(getEnvFloat("NAVIDROME_SCAN_TIMEOUT_SECONDS", 600)) * time.Second
+	navidromePlaylistSync = getEnv("NAVIDROME_PLAYLIST_SYNC", "false") == "true"
+
+	scanMu        sync.Mutex
+	scanTimer     *time.Timer
+	scanPending   []*DownloadJob
+	scanRequested bool
+	// scanWaitingSince marca o primeiro pedido ainda não atendido, para limitar o debounce
+	scanWaitingSince time.Time
+	// scanRunning impede que duas varreduras rodem ao mesmo tempo
+	scanRunning bool
+)
+
+// ScanResult registra a varredura do Navidrome disparada após o job.
+type ScanResult struct {
+	RequestedAt time.Time  `json:"requested_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	VisibleAt   *time.Time `json:"visible_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// subsonicResponse é o envelope comum das respostas da API Subsonic.
+type subsonicResponse struct {
+	Response struct {
+		Status string `json:"status"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"subsonic-response"`
+}
+
+// navidromeConfigured indica se há credenciais para falar com o Navidrome.
+func navidromeConfigured() bool {
+	return navidromeUser != "" && navidromePassword != ""
+}
+
+// subsonicGet chama um método da API Subsonic do Navidrome com autenticação por token
+// (md5 da senha + salt) e decodifica a resposta em out, que deve embutir o envelope.
+func subsonicGet(method string, params url.Values, out interface{}) error {
+	salt := newJobID()
+	sum := md5.Sum([]byte(navidromePassword + salt))
+
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("u", navidromeUser)
+	params.Set("t", hex.EncodeToString(sum[:]))
+	params.Set("s", salt)
+	params.Set("v", "1.16.1")
+	params.Set("c", "stack-music")
+	params.Set("f", "json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("%s/rest/%s?%s", navidromeURL, method, params.Encode()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("navidrome returned status %d for %s", resp.StatusCode, method)
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return err
+	}
+	var envelope subsonicResponse
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return err
+	}
+	if envelope.Response.Status != "ok" {
+		if envelope.Response.Error != nil {
+			return fmt.Errorf("navidrome %s failed: %s", method, envelope.Response.Error.Message)
+		}
+		return fmt.Errorf("navidrome %s failed", method)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// scheduleLibraryScan agenda uma varredura do Navidrome para o job. Jobs que terminam
+// dentro da janela de debounce são atendidos pela mesma varredura, mas nenhum pedido
+// espera mais que NAVIDROME_SCAN_MAX_WAIT_SECONDS.
+func scheduleLibraryScan(job *DownloadJob) {
+	if !navidromeConfigured() {
+		return
+	}
+
+	updateJob(job, func(j *DownloadJob) {
+		j.Result.Scan = &ScanResult{RequestedAt: time.Now()}
+	})
+
+	scanMu.Lock()
+	defer scanMu.Unlock()
+
+	scanPending = append(scanPending, job)
+	restartScanTimer()
+}
+
+// requestLibraryScan agenda uma varredura sem job associado, usada quando arquivos são
+// alterados ou removidos diretamente pela API.
+func requestLibraryScan() {
+	if !navidromeConfigured() {
+		return
+	}
+
+	scanMu.Lock()
+	defer scanMu.Unlock()
+
+	scanRequested = true
+	restartScanTimer()
+}
+
+// restartScanTimer reinicia a janela de debounce sem ultrapassar a espera máxima desde o
+// primeiro pedido pendente; deve ser chamada com scanMu travado.
+func restartScanTimer() {
+	if scanTimer != nil {
+		scanTimer.Stop()
+	}
+	if scanWaitingSince.IsZero() {
+		scanWaitingSince = time.Now()
+	}
+	delay := scanDelay(time.Since(scanWaitingSince))
+	scanTimer = time.AfterFunc(delay, runLibraryScan)
+}
+
+// scanDelay calcula quanto esperar pela próxima varredura depois de waited de espera.
+func scanDelay(waited time.Duration) time.Duration {
+	delay := navidromeScanDebounce
+	if remaining := navidromeScanMaxWait - waited; remaining < delay {
+		delay = remaining
+	}
+	return max(delay, 0)
+}
+
+// runLibraryScan dispara o startScan, acompanha o getScanStatus até o fim e registra
+// nos jobs atendidos quando as faixas ficaram visíveis.
+func runLibraryScan() {
+	scanMu.Lock()
+	scanTimer = nil
+	// Pedidos feitos durante uma varredura ficam pendentes até ela terminar
+	if scanRunning {
+		scanMu.Unlock()
+		return
+	}
+	batch := scanPending
+	requested := scanRequested
+	scanPending = nil
+	scanRequested = false
+	scanWaitingSince = time.Time{}
+	if len(batch) == 0 && !requested {
+		scanMu.Unlock()
+		return
+	}
+	scanRunning = true
+	scanMu.Unlock()
+
+	startedAt := time.Now()
+	err := startAndWaitLibraryScan()
+	finishedAt := time.Now()
+	finishLibraryScan()
+	for _, job := range batch {
+		updateJob(job, func(j *DownloadJob) {
+			j.Result.Scan.StartedAt = &startedAt
+			if err != nil {
+				j.Result.Scan.Error = err.Error()
+				return
+			}
+			j.Result.Scan.VisibleAt = &finishedAt
+		})
+	}
+	if err != nil {
+		log.WithError(err).Error("Navidrome library scan failed")
+		return
+	}
+
+	log.Infof("Navidrome library scan finished for %d job(s)", len(batch))
+
+	for _, job := range batch {
+		if job.NavidromePlaylist {
+			syncNavidromePlaylist(job)
+		}
+	}
+}
+
+// finishLibraryScan libera a próxima varredura e agenda a dos pedidos que chegaram
+// enquanto esta rodava.
+func finishLibraryScan() {
+	scanMu.Lock()
+	defer scanMu.Unlock()
+
+	scanRunning = false
+	if len(scanPending) > 0 || scanRequested {
+		restartScanTimer()
+	}
+}
+
+// startAndWaitLibraryScan inicia uma varredura incremental e espera ela terminar.
+func startAndWaitLibraryScan() error {
+	if err := subsonicGet("startScan", nil, nil); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(navidromeScanTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(2 * time.Second)
+
+		var status struct {
+			Response struct {
+				ScanStatus struct {
+					Scanning bool `json:"scanning"`
+					Count    int  `json:"count"`
+				} `json:"scanStatus"`
+			} `json:"subsonic-response"`
+		}
+		if err := subsonicGet("getScanStatus", nil, &status); err != nil {
+			return err
+		}
+		if !status.Response.ScanStatus.Scanning {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("navidrome scan did not finish within %s", navidromeScanTimeout)
+}
+
+// PlaylistSyncResult registra a playlist criada/atualizada no Navidrome para o job.
+type PlaylistSyncResult struct {
+	ID      string   `json:"id,omitempty"`
+	Name    string   `json:"name"`
+	Created bool     `json:"created"`
+	Matched int      `json:"matched"`
+	Missing []string `json:"missing,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// subsonicSong é o subconjunto dos campos de música da API Subsonic usados aqui.
+type subsonicSong struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Artist   string `json:"artist"`
+	Album    string `json:"album"`
+	Duration int    `json:"duration"`
+	Path     string `json:"path"`
+}
+
+// findNavidromeSong procura a faixa no Navidrome via search3 e escolhe a música com
+// melhor nota de título, artista e duração.
+func findNavidromeSong(entry PlaylistEntry) (*subsonicSong, error) {
+	params := url.Values{}
+	params.Set("query", entry.Title)
+	params.Set("songCount", "20")
+	params.Set("artistCount", "0")
+	params.Set("albumCount", "0")
+
+	var result struct {
+		Response struct {
+			SearchResult3 struct {
+				Song []subsonicSong `json:"song"`
+			} `json:"searchResult3"`
+		} `json:"subsonic-response"`
+	}
+	if err := subsonicGet("search3", params, &result); err != nil {
+		return nil, err
+	}
+
+	parsed := ParsedTitle{Title: entry.Title}
+	if len(entry.Artists) > 0 {
+		parsed.Artist = entry.Artists[0]
+	}
+
+	var best *subsonicSong
+	bestScore := 0.0
+	for i, song := range result.Response.SearchResult3.Song {
+		candidate := scoreCandidate(parsed, float64(entry.DurationMs)/1000, TrackMetadata{
+			Title:      song.Title,
+			Artists:    []string{song.Artist},
+			DurationMs: song.Duration * 1000,
+		})
+		if candidate.Score > bestScore {
+			best = &result.Response.SearchResult3.Song[i]
+			bestScore = candidate.Score
+		}
+	}
+	if best == nil || bestScore < navidromeMatchMinScore {
+		return nil, nil
+	}
+	return best, nil
+}
+
+// findNavidromePlaylist procura uma playlist existente, primeiro pelo comentário com a
+// URL de origem e depois pelo nome.
+func findNavidromePlaylist(name, sourceURL string) (string, error) {
+	var result struct {
+		Response struct {
+			Playlists struct {
+				Playlist []struct {
+					ID      string `json:"id"`
+					Name    string `json:"name"`
+					Comment string `json:"comment"`
+				} `json:"playlist"`
+			} `json:"playlists"`
+		} `json:"subsonic-response"`
+	}
+	if err := subsonicGet("getPlaylists", nil, &result); err != nil {
+		return "", err
+	}
+
+	byName := ""
+	for _, playlist := range result.Response.Playlists.Playlist {
+		if strings.Contains(playlist.Comment, sourceURL) {
+			return playlist.ID, nil
+		}
+		if playlist.Name == name && byName == "" {
+			byName = playlist.ID
+		}
+	}
+	return byName, nil
+}
+
+// entryForJobTrack usa, quando houver, os metadados aplicados ao arquivo baixado daquela
+// entrada (vídeos do YouTube ficam com o título do Spotify/MusicBrainz após o casamento).
+func entryForJobTrack(job *DownloadJob, entry PlaylistEntry) PlaylistEntry {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	for _, track := range job.Tracks {
+		if track.SourceID == entry.SourceID && track.Metadata != nil {
+			entry.Title = track.Metadata.Title
+			entry.Artists = track.Metadata.Artists
+			entry.DurationMs = track.Metadata.DurationMs
+		}
+	}
+	return entry
+}
+
+// syncNavidromePlaylist cria ou atualiza no Navidrome uma playlist com o mesmo nome e
+// ordem da playlist de origem do job, guardando a URL de origem no comentário.
+func syncNavidromePlaylist(job *DownloadJob) {
+	result := &PlaylistSyncResult{}
+	defer func() {
+		updateJob(job, func(j *DownloadJob) { j.Result.NavidromePlaylist = result })
+	}()
+
+	if job.Collection == nil {
+		result.Error = "playlist tracks unavailable"
+		return
+	}
+	name, entries := job.Collection.Name, job.Collection.Entries
+	result.Name = name
+
+	var songIDs []string
+	for _, entry := range entries {
+		entry = entryForJobTrack(job, entry)
+		song, err := findNavidromeSong(entry)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to search Navidrome for %s", entry.Title)
+		}
+		if song == nil {
+			result.Missing = append(result.Missing, describeEntry(entry))
+			continue
+		}
+		songIDs = append(songIDs, song.ID)
+		result.Matched++
+	}
+
+	playlistID, err := findNavidromePlaylist(name, job.URL)
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+
+	// createPlaylist com playlistId substitui as músicas de uma playlist existente; só o
+	// primeiro lote vai nele e o restante é acrescentado com updatePlaylist
+	first := songIDs[:min(len(songIDs), navidromeSongChunk)]
+	params := url.Values{"songId": first}
+	if playlistID != "" {
+		params.Set("playlistId", playlistID)
+	} else {
+		params.Set("name", name)
+		result.Created = true
+	}
+
+	var created struct {
+		Response struct {
+			Playlist struct {
+				ID string `json:"id"`
+			} `json:"playlist"`
+		} `json:"subsonic-response"`
+	}
+	if err := subsonicGet("createPlaylist", params, &created); err != nil {
+		log.WithError(err).Errorf("Failed to create Navidrome playlist %s", name)
+		result.Error = err.Error()
+		return
+	}
+	if created.Response.Playlist.ID != "" {
+		playlistID = created.Response.Playlist.ID
+	}
+	result.ID = playlistID
+
+	for start := len(first); start < len(songIDs); start += navidromeSongChunk {
+		add := url.Values{"songIdToAdd": songIDs[start:min(len(songIDs), start+navidromeSongChunk)]}
+		add.Set("playlistId", playlistID)
+		if err := subsonicGet("updatePlaylist", add, nil); err != nil {
+			log.WithError(err).Errorf("Failed to add songs to Navidrome playlist %s", name)
+			result.Error = err.Error()
+			return
+		}
+	}
+
+	comment := url.Values{}
+	comment.Set("playlistId", playlistID)
+	comment.Set("comment", job.URL)
+	if err := subsonicGet("updatePlaylist", comment, nil); err != nil {
+		log.WithError(err).Errorf("Failed to set comment on Navidrome playlist %s", name)
+		result.Error = err.Error()
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestScanDelay(t *testing.T) {
+	navidromeScanDebounce = 30 * time.Second
+	navidromeScanMaxWait = 300 * time.Second
+	tests := []struct {
+		waited time.Duration
+		want   time.Duration
+	}{
+		{0, 30 * time.Second},
+		{200 * time.Second, 30 * time.Second},
+		{290 * time.Second, 10 * time.Second},
+		{400 * time.Second, 0},
+	}
+	for _, tt := range tests {
+		if got := scanDelay(tt.waited); got != tt.want {
+			t.Errorf("scanDelay(%s) = %s, want %s", tt.waited, got, tt.want)
+		}
+	}
+}
+
+// useTestNavidrome aponta NAVIDROME_URL para um servidor de teste com handler.
+func useTestNavidrome(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	previousURL, previousUser, previousPassword := navidromeURL, navidromeUser, navidromePassword
+	navidromeURL, navidromeUser, navidromePassword = server.URL, "admin", "secret"
+	t.Cleanup(func() {
+		server.Close()
+		navidromeURL, navidromeUser, navidromePassword = previousURL, previousUser, previousPassword
+	})
+}
+
+func TestSubsonicGet(t *testing.T) {
+	useTestNavidrome(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		sum := md5.Sum([]byte("secret" + query.Get("s")))
+		if query.Get("u") != "admin" || query.Get("t") != hex.EncodeToString(sum[:]) || query.Get("f") != "json" {
+			t.Errorf("unexpected authentication %v", query)
+		}
+		switch r.URL.Path {
+		case "/rest/ping":
+			w.Write([]byte(`{"subsonic-response":{"status":"ok","version":"1.16.1"}}`))
+		case "/rest/startScan":
+			w.Write([]byte(`{"subsonic-response":{"status":"failed","error":{"code":50,"message":"not authorized"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	var out struct {
+		Response struct {
+			Version string `json:"version"`
+		} `json:"subsonic-response"`
+	}
+	if err := subsonicGet("ping", nil, &out); err != nil || out.Response.Version != "1.16.1" {
+		t.Errorf("subsonicGet(ping) = %+v, %v", out, err)
+	}
+	if err := subsonicGet("startScan", nil, nil); err == nil || err.Error() != "navidrome startScan failed: not authorized" {
+		t.Errorf("subsonicGet(startScan) error = %v, want the Subsonic error message", err)
+	}
+	if err := subsonicGet("missing", nil, nil); err == nil {
+		t.Error("subsonicGet(missing) succeeded, want an HTTP error")
+	}
+}
+
+func TestFindNavidromeSong(t *testing.T) {
+	useTestNavidrome(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"subsonic-response":{"status":"ok","searchResult3":{"song":[
+			{"id":"s1","title":"Song (Live)","artist":"Band","duration":320},
+			{"id":"s2","title":"Song","artist":"Band","duration":200},
+			{"id":"s3","title":"Song","artist":"Cover Band","duration":200}
+		]}}}`))
+	})
+
+	song, err := findNavidromeSong(PlaylistEntry{Title: "Song", Artists: []string{"Band"}, DurationMs: 201000})
+	if err != nil || song == nil || song.ID != "s2" {
+		t.Errorf("findNavidromeSong = %+v, %v; want s2", song, err)
+	}
+	song, err = findNavidromeSong(PlaylistEntry{Title: "Another Tune", Artists: []string{"Nobody"}})
+	if err != nil || song != nil {
+		t.Errorf("findNavidromeSong without a good match = %+v, %v; want nil", song, err)
+	}
+}
+
+func TestFindNavidromePlaylist(t *testing.T) {
+	useTestNavidrome(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"subsonic-response":{"status":"ok","playlists":{"playlist":[
+			{"id":"p1","name":"Mix","comment":""},
+			{"id":"p2","name":"Renamed","comment":"Source: https://open.spotify.com/playlist/abc"},
+			{"id":"p3","name":"Mix","comment":""}
+		]}}}`))
+	})
+
+	tests := []struct {
+		name, sourceURL, want string
+	}{
+		{"Anything", "https://open.spotify.com/playlist/abc", "p2"},
+		{"Mix", "https://open.spotify.com/playlist/other", "p1"},
+		{"Unknown", "https://open.spotify.com/playlist/other", ""},
+	}
+	for _, tt := range tests {
+		if got, err := findNavidromePlaylist(tt.name, tt.sourceURL); err != nil || got != tt.want {
+			t.Errorf("findNavidromePlaylist(%q, %q) = %q, %v; want %q", tt.name, tt.sourceURL, got, err, tt.want)
+		}
+	}
+}
+
+func TestEntryForJobTrack(t *testing.T) {
+	job := &DownloadJob{Tracks: []*JobTrack{
+		{SourceID: "v1", Metadata: &TrackMetadata{Title: "Real Title", Artists: []string{"Real Artist"}, DurationMs: 180000}},
+		{SourceID: "v2"},
+	}}
+	got := entryForJobTrack(job, PlaylistEntry{SourceID: "v1", Title: "Video (Official)", Artists: []string{"Channel"}})
+	if got.Title != "Real Title" || len(got.Artists) != 1 || got.Artists[0] != "Real Artist" || got.DurationMs != 180000 {
+		t.Errorf("entryForJobTrack with metadata = %+v", got)
+	}
+	entry := PlaylistEntry{SourceID: "v2", Title: "Video"}
+	if got := entryForJobTrack(job, entry); got.Title != "Video" {
+		t.Errorf("entryForJobTrack without metadata = %+v, want the playlist entry", got)
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MoveRequest é o corpo aceito por POST /library/tracks/:id/move. Path é o destino
+// relativo à biblioteca; sem ele, o destino é calculado pelo PathTemplate (ou pelo
+// PATH_TEMPLATE configurado) a partir das tags do arquivo.
+type MoveRequest struct {
+	Path         string `json:"path,omitempty"`
+	PathTemplate string `json:"path_template,omitempty"`
+}
+
+// RetagRequest é o corpo aceito por POST /library/tracks/:id/retag. Source é um ID,
+// URI ou URL de faixa do Spotify (ou "musicbrainz:<mbid>"); Move renomeia o arquivo
+// conforme as novas tags.
+type RetagRequest struct {
+	Source       string `json:"source"`
+	Move         bool   `json:"move,omitempty"`
+	PathTemplate string `json:"path_template,omitempty"`
+}
+
+// sidecarFiles lista os arquivos que acompanham a faixa (letras .lrc).
+func sidecarFiles(rel string) []string {
+	lrc := strings.TrimSuffix(rel, path.Ext(rel)) + ".lrc"
+	if _, err := os.Stat(absLibraryPath(lrc)); err == nil {
+		return []string{lrc}
+	}
+	return nil
+}
+
+// removeEmptyDirs apaga os diretórios vazios a partir de dir até a raiz da biblioteca.
+func removeEmptyDirs(dir string) {
+	for dir != "." && dir != "/" && dir != "" {
+		if err := os.Remove(absLibraryPath(dir)); err != nil {
+			return
+		}
+		dir = path.Dir(dir)
+	}
+}
+
+// deleteLibraryFile apaga a faixa e suas letras e a retira do índice de downloads.
+func deleteLibraryFile(rel string) error {
+	if err := os.Remove(absLibraryPath(rel)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, sidecar := range sidecarFiles(rel) {
+		os.Remove(absLibraryPath(sidecar))
+	}
+	forgetDownloadFile(rel)
+	removeEmptyDirs(path.Dir(rel))
+	return nil
+}
+
+// moveLibraryFile move a faixa (e suas letras) para outro caminho da biblioteca,
+// sem sobrescrever arquivos existentes, e atualiza o índice de downloads.
+func moveLibraryFile(from, to string) error {
+	if from == to {
+		return nil
+	}
+	if _, err := os.Stat(absLibraryPath(to)); err == nil {
+		return fmt.Errorf("destination already exists: %s", to)
+	}
+	if err := os.MkdirAll(filepath.Dir(absLibraryPath(to)), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(absLibraryPath(from), absLibraryPath(to)); err != nil {
+		return err
+	}
+	for _, sidecar := range sidecarFiles(from) {
+		target := strings.TrimSuffix(to, path.Ext(to)) + path.Ext(sidecar)
+		os.Rename(absLibraryPath(sidecar), absLibraryPath(target))
+	}
+	moveDownloadFile(from, to)
+	removeEmptyDirs(path.Dir(from))
+	return nil
+}
+
+// cleanLibraryPath valida um caminho relativo informado pelo usuário.
+func cleanLibraryPath(p string) (string, error) {
+	cleaned := path.Clean(strings.ReplaceAll(p, "\\", "/"))
+	if cleaned == "." || path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("path must be relative to the library")
+	}
+	if !isAudioFile(cleaned) {
+		return "", fmt.Errorf("path must end with an audio extension")
+	}
+	return cleaned, nil
+}
+
+// templateDestination calcula o caminho da faixa segundo o template a partir das tags.
+func templateDestination(track LibraryTrack, templateParam string) (string, error) {
+	tpl, _, err := resolvePathOptions(templateParam, "")
+	if err != nil {
+		return "", err
+	}
+
+	isrc := track.Tags["isrc"]
+	if isrc == "" {
+		isrc = track.Tags["tsrc"]
+	}
+	values := map[string]string{
+		"title":        track.Title,
+		"artist":       track.Artist,
+		"artists":      track.Artist,
+		"album":        track.Album,
+		"album_artist": track.AlbumArtist,
+		"year":         track.Year,
+		"genre":        track.Genre,
+		"isrc":         isrc,
+		"ext":          strings.TrimPrefix(path.Ext(track.Path), "."),
+	}
+	if track.TrackNumber > 0 {
+		values["track"] = strconv.Itoa(track.TrackNumber)
+	}
+	if track.DiscNumber > 0 {
+		values["disc"] = strconv.Itoa(track.DiscNumber)
+	}
+	return tpl.Render(values), nil
+}
+
+// libraryChanged atualiza o índice da biblioteca e pede uma varredura ao Navidrome.
+func libraryChanged() {
+	invalidateLibraryIndex()
+	requestLibraryScan()
+}
+
+// deleteLibraryTrack apaga uma faixa da biblioteca.
+func deleteLibraryTrack(c *gin.Context) {
+	track, ok := findLibraryTrack(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Track not found"})
+		return
+	}
+
+	if err := deleteLibraryFile(track.Path); err != nil {
+		log.WithError(err).Errorf("Failed to delete %s", track.Path)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete track"})
+		return
+	}
+	libraryChanged()
+	c.Status(http.StatusNoContent)
+}
+
+// deleteLibraryAlbum apaga todas as faixas de um álbum da biblioteca.
+func deleteLibraryAlbum(c *gin.Context) {
+	var albumTracks []LibraryTrack
+	for _, track := range libraryTracks(false) {
+		if libraryID(libraryAlbumKey(track)) == c.Param("id") {
+			albumTracks = append(albumTracks, track)
+		}
+	}
+	if len(albumTracks) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Album not found"})
+		return
+	}
+
+	var deleted []string
+	var failed []string
+	for _, track := range albumTracks {
+		if err := deleteLibraryFile(track.Path); err != nil {
+			log.WithError(err).Errorf("Failed to delete %s", track.Path)
+			failed = append(failed, track.Path)
+			continue
+		}
+		deleted = append(deleted, track.Path)
+	}
+	libraryChanged()
+
+	if len(failed) > 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete some tracks", "deleted": deleted, "failed": failed})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
+
+// moveLibraryTrack renomeia ou move uma faixa para um caminho explícito ou calculado
+// pelo template.
+func moveLibraryTrack(c *gin.Context) {
+	var request MoveRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.WithError(err).Error("Invalid request body")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	track, ok := findLibraryTrack(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Track not found"})
+		return
+	}
+
+	var dest string
+	var err error
+	if request.Path != "" {
+		dest, err = cleanLibraryPath(request.Path)
+	} else {
+		dest, err = templateDestination(track, request.PathTemplate)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := moveLibraryFile(track.Path, dest); err != nil {
+		log.WithError(err).Errorf("Failed to move %s", track.Path)
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	libraryChanged()
+	c.JSON(http.StatusOK, gin.H{"from": track.Path, "path": dest, "id": libraryID(dest)})
+}
+
+// retagLibraryTrack reescreve as tags de uma faixa a partir de uma faixa do Spotify (ou
+// gravação do MusicBrainz) escolhida pelo usuário e, opcionalmente, move o arquivo.
+func retagLibraryTrack(c *gin.Context) {
+	var request RetagRequest
+	if err := c.ShouldBindJSON(&request); err != nil || request.Source == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A source track is required"})
+		return
+	}
+
+	track, ok := findLibraryTrack(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Track not found"})
+		return
+	}
+
+	source := request.Source
+	// Um ID puro é tratado como faixa do Spotify
+	if !strings.Contains(source, ":") && !strings.Contains(source, "/") {
+		source = "spotify:track:" + source
+	}
+	meta, err := fetchTrackMetadata(source)
+	if err != nil {
+		log.WithError(err).Errorf("Failed to fetch metadata from %s", source)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to get track metadata"})
+		return
+	}
+
+	if err := writeTags(absLibraryPath(track.Path), meta); err != nil {
+		log.WithError(err).Errorf("Failed to tag %s", track.Path)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write tags"})
+		return
+	}
+	if meta.Source == "spotify" {
+		recordDownloads([]IndexEntry{{
+			Platform: "spotify", SourceID: meta.SourceID, File: track.Path, Title: meta.Title, DownloadedAt: track.ModTime,
+		}})
+	}
+
+	rel := track.Path
+	if request.Move {
+		refreshLibraryIndex(true)
+		updated, ok := findLibraryTrack(track.ID)
+		if !ok {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read retagged track"})
+			return
+		}
+		dest, err := templateDestination(updated, request.PathTemplate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := moveLibraryFile(rel, dest); err != nil {
+			log.WithError(err).Errorf("Failed to move %s", rel)
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "metadata": meta})
+			return
+		}
+		rel = dest
+	}
+	libraryChanged()
+
+	c.JSON(http.StatusOK, gin.H{"path": rel, "id": libraryID(rel), "metadata": meta})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeleteLibraryFile(t *testing.T) {
+	useTestLibrary(t)
+	useTestDataDir(t)
+	writeTestFile(t, absLibraryPath("Band/Album/01 - Song.mp3"), "audio")
+	writeTestFile(t, absLibraryPath("Band/Album/01 - Song.lrc"), "lyrics")
+	writeTestFile(t, absLibraryPath("Band/Other/01 - Kept.mp3"), "audio")
+	recordDownloads([]IndexEntry{
+		{Platform: "spotify", SourceID: "sp1", File: "Band/Album/01 - Song.mp3"},
+		{Platform: "youtube", SourceID: "yt1", File: "Band/Album/01 - Song.mp3"},
+		{Platform: "spotify", SourceID: "sp2", File: "Band/Other/01 - Kept.mp3"},
+	})
+
+	if err := deleteLibraryFile("Band/Album/01 - Song.mp3"); err != nil {
+		t.Fatal(err)
+	}
+	// A pasta do álbum ficou vazia e some; a do artista ainda tem outro álbum
+	if _, err := os.Stat(absLibraryPath("Band/Album")); !os.IsNotExist(err) {
+		t.Errorf("empty album folder was not removed: %v", err)
+	}
+	if _, err := os.Stat(absLibraryPath("Band/Other/01 - Kept.mp3")); err != nil {
+		t.Errorf("unrelated file was removed: %v", err)
+	}
+	files := indexedFiles()
+	if files["Band/Album/01 - Song.mp3"] || !files["Band/Other/01 - Kept.mp3"] {
+		t.Errorf("indexed files after delete = %v", files)
+	}
+
+	// Apagar de novo um arquivo que já sumiu não é erro
+	if err := deleteLibraryFile("Band/Album/01 - Song.mp3"); err != nil {
+		t.Errorf("deleting a missing file failed: %v", err)
+	}
+}
+
+func TestMoveLibraryFile(t *testing.T) {
+	useTestLibrary(t)
+	useTestDataDir(t)
+	writeTestFile(t, absLibraryPath("Unsorted/song.mp3"), "audio")
+	writeTestFile(t, absLibraryPath("Unsorted/song.lrc"), "lyrics")
+	writeTestFile(t, absLibraryPath("Band/Album/02 - Taken.mp3"), "other")
+	recordDownloads([]IndexEntry{{Platform: "spotify", SourceID: "sp1", File: "Unsorted/song.mp3"}})
+
+	if err := moveLibraryFile("Unsorted/song.mp3", "Band/Album/02 - Taken.mp3"); err == nil {
+		t.Error("moving over an existing file succeeded")
+	}
+	if err := moveLibraryFile("Unsorted/song.mp3", "Band/Album/01 - Song.mp3"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rel := range []string{"Band/Album/01 - Song.mp3", "Band/Album/01 - Song.lrc"} {
+		if _, err := os.Stat(absLibraryPath(rel)); err != nil {
+			t.Errorf("%s was not moved: %v", rel, err)
+		}
+	}
+	if _, err := os.Stat(absLibraryPath("Unsorted")); !os.IsNotExist(err) {
+		t.Errorf("empty source folder was not removed: %v", err)
+	}
+	if entry, ok := lookupDownload("spotify", "sp1"); !ok || entry.File != "Band/Album/01 - Song.mp3" {
+		t.Errorf("index entry = %+v, %v; want the new path", entry, ok)
+	}
+}
+
+func TestCleanLibraryPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"Band/Album/01 - Song.mp3", "Band/Album/01 - Song.mp3", false},
+		{"Band\\Album\\song.flac", "Band/Album/song.flac", false},
+		{"Band/../song.mp3", "song.mp3", false},
+		{"../outside.mp3", "", true},
+		{"/etc/song.mp3", "", true},
+		{"Band/cover.jpg", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := cleanLibraryPath(tt.path)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("cleanLibraryPath(%q) = %q, %v; want %q (error %v)", tt.path, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestTemplateDestination(t *testing.T) {
+	track := LibraryTrack{
+		Path: "Unsorted/file.flac", Title: "Song", Artist: "Band", AlbumArtist: "Band",
+		Album: "Album", Year: "2010", TrackNumber: 3, Tags: map[string]string{"tsrc": "USRC17607839"},
+	}
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"{album_artist}/{album}/{track:02} - {title}.{ext}", "Band/Album/03 - Song.flac"},
+		{"{artist}/{year} - {album}/{isrc}.{ext}", "Band/2010 - Album/USRC17607839.flac"},
+	}
+	for _, tt := range tests {
+		got, err := templateDestination(track, tt.template)
+		if err != nil || got != tt.want {
+			t.Errorf("templateDestination(%q) = %q, %v; want %q", tt.template, got, err, tt.want)
+		}
+	}
+}
+
+func TestRetagLibraryTrackRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestLibrary(t)
+	useTestLibraryIndex(t, []LibraryTrack{{ID: "l1", Path: "Band/Album/01 - Song.mp3"}}, time.Now())
+
+	router := gin.New()
+	router.POST("/library/tracks/:id/retag", retagLibraryTrack)
+	tests := []struct {
+		name string
+		id   string
+		body string
+		want int
+	}{
+		{"missing source", "l1", `{}`, http.StatusBadRequest},
+		{"invalid body", "l1", `{`, http.StatusBadRequest},
+		{"unknown track", "missing", `{"source":"4uLU6hMCjMI75M1A2tKUQC"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/library/tracks/"+tt.id+"/retag", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Diretórios raiz da biblioteca dentro de cada container de download.
+const (
+	ytDlpRoot   = "/downloads"
+	spotDLRoot  = "/music"
+	defaultPath = "{album_artist}/{album} ({year})/{track:02} - {title}.{ext}"
+)
+
+// Políticas de colisão aceitas quando o arquivo de destino já existe. rename mantém os
+// dois arquivos, acrescentando " (2)", " (3)"... ao nome do novo.
+const (
+	conflictSkip      = "skip"
+	conflictOverwrite = "overwrite"
+	conflictRename    = "rename"
+)
+
+var (
+	pathTemplateSetting = os.Getenv("PATH_TEMPLATE")
+	onConflictSetting   = os.Getenv("PATH_ON_CONFLICT")
+)
+
+// templateSegment é um pedaço do template: ou texto literal ou um campo {nome[:pad]}.
+type templateSegment struct {
+	Literal string
+	Field   string
+	Pad     int
+}
+
+// PathTemplate é um template de caminho unificado, ex:
+// {album_artist}/{album} ({year})/{track:02} - {title}.{ext}
+type PathTemplate struct {
+	Raw      string
+	Segments []templateSegment
+}
+
+// backendField descreve como um campo do template é escrito em cada backend.
+type backendField struct {
+	ytDlp   string // expressão dentro de %(...), sem o tipo de conversão
+	ytDlpFB string // valor padrão quando o campo não existe
+	spotDL  string // variável do spotDL, sem as chaves
+	numeric bool
+}
+
+// templateFields mapeia os campos unificados para a sintaxe do yt-dlp e do spotDL.
+var templateFields = map[string]backendField{
+	"title":          {ytDlp: "track,title", spotDL: "title"},
+	"artist":         {ytDlp: "artist,creator,uploader", ytDlpFB: "Unknown Artist", spotDL: "artist"},
+	"artists":        {ytDlp: "artist,creator,uploader", ytDlpFB: "Unknown Artist", spotDL: "artists"},
+	"album":          {ytDlp: "album", ytDlpFB: "Singles", spotDL: "album"},
+	"album_artist":   {ytDlp: "album_artist,artist,creator,uploader", ytDlpFB: "Unknown Artist", spotDL: "album-artist"},
+	"year":           {ytDlp: "release_year,release_date>%Y,upload_date>%Y", ytDlpFB: "Unknown", spotDL: "year"},
+	"genre":          {ytDlp: "genre", ytDlpFB: "Unknown Genre", spotDL: "genre"},
+	"track":          {ytDlp: "track_number,playlist_index", ytDlpFB: "1", spotDL: "track-number", numeric: true},
+	"disc":           {ytDlp: "disc_number", ytDlpFB: "1", spotDL: "disc-number", numeric: true},
+	"isrc":           {ytDlp: "isrc", ytDlpFB: "", spotDL: "isrc"},
+	"playlist":       {ytDlp: "playlist_title,playlist", ytDlpFB: "", spotDL: "list-name"},
+	"playlist_index": {ytDlp: "playlist_index", ytDlpFB: "1", spotDL: "list-position", numeric: true},
+	"id":             {ytDlp: "id", spotDL: "track-id"},
+	"ext":            {ytDlp: "ext", spotDL: "output-ext"},
+}
+
+// parsePathTemplate valida e quebra o template em segmentos. Campos desconhecidos,
+// caminhos absolutos e componentes ".." são rejeitados; se o template não termina
+// com a extensão, ".{ext}" é adicionado automaticamente.
+func parsePathTemplate(raw string) (*PathTemplate, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		raw = defaultPath
+	}
+	if strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "\\") {
+		return nil, fmt.Errorf("path template must be relative")
+	}
+	for _, component := range strings.Split(raw, "/") {
+		if strings.TrimSpace(component) == "" || component == "." || component == ".." {
+			return nil, fmt.Errorf("invalid path component %q in template", component)
+		}
+	}
+	if !strings.HasSuffix(raw, "{ext}") {
+		raw += ".{ext}"
+	}
+
+	tpl := &PathTemplate{Raw: raw}
+	var literal strings.Builder
+	for i := 0; i < len(raw); i++ {
+		ch := raw[i]
+		if ch == '}' {
+			return nil, fmt.Errorf("unbalanced '}' at position %d", i)
+		}
+		if ch != '{' {
+			literal.WriteByte(ch)
+			continue
+		}
+
+		end := strings.IndexByte(raw[i:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed '{' at position %d", i)
+		}
+		expr := raw[i+1 : i+end]
+		i += end
+
+		name, padStr, hasPad := strings.Cut(expr, ":")
+		field, ok := templateFields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown template field %q", name)
+		}
+		pad := 0
+		if hasPad {
+			p, err := strconv.Atoi(padStr)
+			if err != nil || p < 1 || p > 9 || !field.numeric {
+				return nil, fmt.Errorf("invalid padding %q for field %q", padStr, name)
+			}
+			pad = p
+		}
+
+		if literal.Len() > 0 {
+			tpl.Segments = append(tpl.Segments, templateSegment{Literal: sanitizeTemplateLiteral(literal.String())})
+			literal.Reset()
+		}
+		tpl.Segments = append(tpl.Segments, templateSegment{Field: name, Pad: pad})
+	}
+	if literal.Len() > 0 {
+		tpl.Segments = append(tpl.Segments, templateSegment{Literal: sanitizeTemplateLiteral(literal.String())})
+	}
+
+	return tpl, nil
+}
+
+// YtDlp traduz o template para a sintaxe de saída do yt-dlp (-o).
+func (t *PathTemplate) YtDlp() string {
+	return t.YtDlpIn("")
+}
+
+// YtDlpIn é como YtDlp, mas grava abaixo de dir (relativo à raiz da biblioteca).
+func (t *PathTemplate) YtDlpIn(dir string) string {
+	root := ytDlpRoot
+	if dir != "" {
+		root += "/" + dir
+	}
+	var b strings.Builder
+	for _, seg := range t.Segments {
+		if seg.Field == "" {
+			b.WriteString(strings.ReplaceAll(seg.Literal, "%", "%%"))
+			continue
+		}
+		field := templateFields[seg.Field]
+		expr := field.ytDlp
+		if field.ytDlpFB != "" {
+			expr += "|" + field.ytDlpFB
+		}
+		switch {
+		case seg.Pad > 0:
+			fmt.Fprintf(&b, "%%(%s)0%dd", expr, seg.Pad)
+		case field.numeric:
+			fmt.Fprintf(&b, "%%(%s)d", expr)
+		default:
+			fmt.Fprintf(&b, "%%(%s)s", expr)
+		}
+	}
+	return root + "/" + b.String()
+}
+
+// SpotDL traduz o template para a sintaxe de saída do spotDL (--output).
+// O spotDL já preenche números de faixa com dois dígitos, então o padding é ignorado.
+func (t *PathTemplate) SpotDL() string {
+	return t.SpotDLIn("")
+}
+
+// SpotDLIn é como SpotDL, mas grava abaixo de dir (relativo à raiz da biblioteca).
+func (t *PathTemplate) SpotDLIn(dir string) string {
+	root := spotDLRoot
+	if dir != "" {
+		root += "/" + dir
+	}
+	var b strings.Builder
+	for _, seg := range t.Segments {
+		if seg.Field == "" {
+			b.WriteString(seg.Literal)
+			continue
+		}
+		b.WriteString("{" + templateFields[seg.Field].spotDL + "}")
+	}
+	return root + "/" + b.String()
+}
+
+// Render aplica o template a valores já conhecidos, sanitizando cada valor.
+// Usado quando o próprio serviço precisa calcular um caminho de destino.
+func (t *PathTemplate) Render(values map[string]string) string {
+	var b strings.Builder
+	for _, seg := range t.Segments {
+		if seg.Field == "" {
+			b.WriteString(seg.Literal)
+			continue
+		}
+		value := values[seg.Field]
+		if value == "" {
+			value = templateFields[seg.Field].ytDlpFB
+		}
+		if seg.Pad > 0 {
+			if n, err := strconv.Atoi(value); err == nil {
+				value = fmt.Sprintf("%0*d", seg.Pad, n)
+			}
+		}
+		b.WriteString(sanitizeFilename(value))
+	}
+	return b.String()
+}
+
+// sanitizeTemplateLiteral remove caracteres inválidos do texto fixo do template,
+// preservando as barras que separam diretórios.
+func sanitizeTemplateLiteral(s string) string {
+	parts := strings.Split(s, "/")
+	for i, part := range parts {
+		parts[i] = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`<>:"\|?*`, r) || unicode.IsControl(r) {
+				return '_'
+			}
+			return r
+		}, part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// sanitizeFilename transforma um valor arbitrário em um componente de caminho seguro:
+// remove separadores, caracteres inválidos e pontos/espaços nas bordas.
+func sanitizeFilename(name string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if r == '/' || strings.ContainsRune(`<>:"\|?*`, r) || unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, name)
+	cleaned = strings.Trim(cleaned, " .")
+	if cleaned == "" {
+		return "_"
+	}
+	// O limite é em bytes (o dos sistemas de arquivos), mas o corte respeita os caracteres
+	// UTF-8 para não gerar um nome inválido
+	if len(cleaned) > 200 {
+		cut := 0
+		for i := range cleaned {
+			if i > 200 {
+				break
+			}
+			cut = i
+		}
+		cleaned = strings.TrimRight(cleaned[:cut], " .")
+	}
+	return cleaned
+}
+
+// resolvePathOptions combina as opções da requisição com a configuração global,
+// retornando o template já validado e a política de colisão.
+func resolvePathOptions(templateParam, conflictParam string) (*PathTemplate, string, error) {
+	raw := templateParam
+	if raw == "" {
+		raw = pathTemplateSetting
+	}
+	tpl, err := parsePathTemplate(raw)
+	if err != nil {
+		return nil, "", err
+	}
+
+	conflict := conflictParam
+	if conflict == "" {
+		conflict = onConflictSetting
+	}
+	switch conflict {
+	case "":
+		conflict = conflictSkip
+	case conflictSkip, conflictOverwrite, conflictRename:
+	default:
+		return nil, "", fmt.Errorf("invalid on_conflict value: %s", conflict)
+	}
+
+	return tpl, conflict, nil
+}
+
+// ytDlpConflictArgs e spotDLConflictArgs traduzem a política de colisão para cada backend.
+func ytDlpConflictArgs(conflict string) []string {
+	if conflict == conflictOverwrite {
+		return []string{"--force-overwrites"}
+	}
+	return []string{"--no-overwrites"}
+}
+
+func spotDLConflictArgs(conflict string) []string {
+	if conflict == conflictOverwrite {
+		return []string{"--overwrite", "force"}
+	}
+	return []string{"--overwrite", "skip"}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParsePathTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		ytDlp   string
+		spotDL  string
+		wantErr bool
+	}{
+		{
+			name:   "default when empty",
+			raw:    "",
+			ytDlp:  "/downloads/%(album_artist,artist,creator,uploader|Unknown Artist)s/%(album|Singles)s (%(release_year,release_date>%Y,upload_date>%Y|Unknown)s)/%(track_number,playlist_index|1)02d - %(track,title)s.%(ext)s",
+			spotDL: "/music/{album-artist}/{album} ({year})/{track-number} - {title}.{output-ext}",
+		},
+		{
+			name:   "appends extension",
+			raw:    "{artist} - {title}",
+			ytDlp:  "/downloads/%(artist,creator,uploader|Unknown Artist)s - %(track,title)s.%(ext)s",
+			spotDL: "/music/{artist} - {title}.{output-ext}",
+		},
+		{
+			name:   "escapes percent and sanitizes literals",
+			raw:    "100% <live>/{title}",
+			ytDlp:  "/downloads/100%% _live_/%(track,title)s.%(ext)s",
+			spotDL: "/music/100% _live_/{title}.{output-ext}",
+		},
+		{name: "absolute path", raw: "/{title}", wantErr: true},
+		{name: "parent directory", raw: "../{title}", wantErr: true},
+		{name: "empty component", raw: "{artist}//{title}", wantErr: true},
+		{name: "unknown field", raw: "{composer}/{title}", wantErr: true},
+		{name: "unclosed brace", raw: "{artist/{title}", wantErr: true},
+		{name: "unbalanced brace", raw: "artist}/{title}", wantErr: true},
+		{name: "padding on text field", raw: "{title:02}", wantErr: true},
+		{name: "invalid padding", raw: "{track:0}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tpl, err := parsePathTemplate(tt.raw)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parsePathTemplate(%q) succeeded, want error", tt.raw)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePathTemplate(%q) failed: %v", tt.raw, err)
+			}
+			if got := tpl.YtDlp(); got != tt.ytDlp {
+				t.Errorf("YtDlp() = %q, want %q", got, tt.ytDlp)
+			}
+			if got := tpl.SpotDL(); got != tt.spotDL {
+				t.Errorf("SpotDL() = %q, want %q", got, tt.spotDL)
+			}
+		})
+	}
+}
+
+func TestPathTemplateRender(t *testing.T) {
+	tpl, err := parsePathTemplate("{album_artist}/{album}/{track:02} - {title}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := tpl.Render(map[string]string{
+		"album_artist": "AC/DC",
+		"title":        "Back in Black",
+		"track":        "1",
+		"ext":          "mp3",
+	})
+	want := "AC_DC/Singles/01 - Back in Black.mp3"
+	if got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "Song Title", "Song Title"},
+		{"separators", "AC/DC: Live?", "AC_DC_ Live_"},
+		{"control characters", "a\tb\x00c", "a_b_c"},
+		{"trims dots and spaces", " .hidden. ", "hidden"},
+		{"empty", "...", "_"},
+		{"accents kept", "Canção Única", "Canção Única"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeFilename(tt.in); got != tt.want {
+				t.Errorf("sanitizeFilename(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilenameTruncatesOnRuneBoundary(t *testing.T) {
+	for _, prefix := range []string{"", "a"} {
+		name := prefix + strings.Repeat("ã", 150)
+		got := sanitizeFilename(name)
+		if len(got) > 200 {
+			t.Errorf("len = %d, want <= 200", len(got))
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("sanitizeFilename produced invalid UTF-8: %q", got)
+		}
+		if !strings.HasPrefix(name, got) {
+			t.Errorf("sanitizeFilename(%q) = %q, want a prefix", name, got)
+		}
+	}
+}
+
+func TestSanitizeFilenameTruncationTrimsTrailingDots(t *testing.T) {
+	// O corte cai logo após os pontos: o nome não pode terminar em "." (inválido no Windows/SMB)
+	name := strings.Repeat("a", 195) + "....." + strings.Repeat("b", 20)
+	got := sanitizeFilename(name)
+	if want := strings.Repeat("a", 195); got != want {
+		t.Errorf("sanitizeFilename = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// PlaylistEntry é uma faixa de uma playlist (ou álbum) de origem, na ordem original.
+type PlaylistEntry struct {
+	Platform   string   `json:"platform"`
+	SourceID   string   `json:"source_id"`
+	URL        string   `json:"url"`
+	Title      string   `json:"title"`
+	Artists    []string `json:"artists,omitempty"`
+	Album      string   `json:"album,omitempty"`
+	DurationMs int      `json:"duration_ms,omitempty"`
+	ISRC       string   `json:"isrc,omitempty"`
+}
+
+// expandPlaylist lista, em ordem, as faixas de uma playlist/álbum do Spotify ou de uma
+// playlist do YouTube, junto com o nome da coleção. spotifyUser e youtubeAccount
+// (opcionais) são as contas vinculadas usadas para ler playlists privadas e curtidas.
+func expandPlaylist(urlStr, spotifyUser, youtubeAccount string) (string, []PlaylistEntry, error) {
+	if isSpotifyURL(urlStr) {
+		itemType, itemID, err := extractSpotifyID(urlStr)
+		if err != nil {
+			return "", nil, err
+		}
+		switch itemType {
+		case "playlist":
+			return getSpotifyPlaylistEntries(itemID, spotifyUser)
+		case "album":
+			return getSpotifyAlbumEntries(itemID)
+		case "collection":
+			return getSpotifySavedTrackEntries(spotifyUser)
+		}
+		return "", nil, fmt.Errorf("spotify %s is not a playlist or album", itemType)
+	}
+
+	itemType, itemID, err := extractYouTubeID(urlStr)
+	if err != nil {
+		return "", nil, err
+	}
+	if itemType != "playlist" {
+		return "", nil, fmt.Errorf("youtube URL is not a playlist")
+	}
+	return getYouTubePlaylistEntries(itemID, youtubeAccount)
+}
+
+// spotifyEntry converte uma faixa do Spotify para PlaylistEntry.
+func spotifyEntry(track spotifyTrackObject, album string) PlaylistEntry {
+	entry := PlaylistEntry{
+		Platform:   "spotify",
+		SourceID:   track.ID,
+		URL:        fmt.Sprintf("https://open.spotify.com/track/%s", track.ID),
+		Title:      track.Name,
+		Album:      album,
+		DurationMs: track.DurationMs,
+		ISRC:       track.ExternalIDs.ISRC,
+	}
+	for _, artist := range track.Artists {
+		entry.Artists = append(entry.Artists, artist.Name)
+	}
+	return entry
+}
+
+// getSpotifyPlaylistEntries percorre todas as páginas de faixas de uma playlist do Spotify.
+func getSpotifyPlaylistEntries(playlistID, spotifyUser string) (string, []PlaylistEntry, error) {
+	var playlist struct {
+		Name string `json:"name"`
+	}
+	if err := spotifyUserGet(spotifyUser, fmt.Sprintf("https://api.spotify.com/v1/playlists/%s?fields=name", playlistID), &playlist); err != nil {
+		return "", nil, err
+	}
+
+	var entries []PlaylistEntry
+	next := fmt.Sprintf("https://api.spotify.com/v1/playlists/%s/tracks?limit=100", playlistID)
+	for next != "" {
+		var page struct {
+			Items []struct {
+				Track *spotifyTrackObject `json:"track"`
+			} `json:"items"`
+			Next string `json:"next"`
+		}
+		if err := spotifyUserGet(spotifyUser, next, &page); err != nil {
+			return "", nil, err
+		}
+		for _, item := range page.Items {
+			// Faixas removidas ou episódios de podcast vêm sem ID
+			if item.Track == nil || item.Track.ID == "" {
+				continue
+			}
+			entries = append(entries, spotifyEntry(*item.Track, item.Track.Album.Name))
+		}
+		next = page.Next
+	}
+
+	return playlist.Name, entries, nil
+}
+
+// getSpotifySavedTrackEntries percorre as músicas curtidas da conta vinculada, da mais
+// recente para a mais antiga.
+func getSpotifySavedTrackEntries(spotifyUser string) (string, []PlaylistEntry, error) {
+	if spotifyUser == "" {
+		return "", nil, fmt.Errorf("liked songs require a linked Spotify user")
+	}
+
+	var entries []PlaylistEntry
+	next := "https://api.spotify.com/v1/me/tracks?limit=50"
+	for next != "" {
+		var page struct {
+			Items []struct {
+				Track *spotifyTrackObject `json:"track"`
+			} `json:"items"`
+			Next string `json:"next"`
+		}
+		if err := spotifyUserGet(spotifyUser, next, &page); err != nil {
+			return "", nil, err
+		}
+		for _, item := range page.Items {
+			if item.Track == nil || item.Track.ID == "" {
+				continue
+			}
+			entries = append(entries, spotifyEntry(*item.Track, item.Track.Album.Name))
+		}
+		next = page.Next
+	}
+
+	return "Liked Songs", entries, nil
+}
+
+// getSpotifyAlbumEntries percorre todas as páginas de faixas de um álbum do Spotify.
+func getSpotifyAlbumEntries(albumID string) (string, []PlaylistEntry, error) {
+	var album struct {
+		Name string `json:"name"`
+	}
+	if err := spotifyGet(fmt.Sprintf("https://api.spotify.com/v1/albums/%s", albumID), &album); err != nil {
+		return "", nil, err
+	}
+
+	var entries []PlaylistEntry
+	next := fmt.Sprintf("https://api.spotify.com/v1/albums/%s/tracks?limit=50", albumID)
+	for next != "" {
+		var page struct {
+			Items []spotifyTrackObject `json:"items"`
+			Next  string               `json:"next"`
+		}
+		if err := spotifyGet(next, &page); err != nil {
+			return "", nil, err
+		}
+		for _, track := range page.Items {
+			entries = append(entries, spotifyEntry(track, album.Name))
+		}
+		next = page.Next
+	}
+
+	return album.Name, entries, nil
+}
+
+// getYouTubePlaylistEntries percorre todas as páginas de itens de uma playlist do YouTube.
+// youtubeAccount (opcional) é a conta vinculada usada para playlists privadas e para LL.
+func getYouTubePlaylistEntries(playlistID, youtubeAccount string) (string, []PlaylistEntry, error) {
+	info, err := getYouTubePlaylistInfo(playlistID, youtubeAccount)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var entries []PlaylistEntry
+	pageToken := ""
+	for {
+		params := url.Values{
+			"part":       {"snippet,contentDetails"},
+			"maxResults": {"50"},
+			"playlistId": {playlistID},
+		}
+		if pageToken != "" {
+			params.Set("pageToken", pageToken)
+		}
+
+		var page struct {
+			Items []struct {
+				Snippet struct {
+					Title                  string `json:"title"`
+					VideoOwnerChannelTitle string `json:"videoOwnerChannelTitle"`
+				} `json:"snippet"`
+				ContentDetails struct {
+					VideoID string `json:"videoId"`
+				} `json:"contentDetails"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := youtubeAccountGet(youtubeAccount, "playlistItems", params, &page); err != nil {
+			return "", nil, err
+		}
+
+		for _, item := range page.Items {
+			// Vídeos privados/removidos continuam na playlist, mas sem canal
+			if item.ContentDetails.VideoID == "" || item.Snippet.VideoOwnerChannelTitle == "" {
+				continue
+			}
+			parsed := parseVideoTitle(item.Snippet.Title, item.Snippet.VideoOwnerChannelTitle)
+			entries = append(entries, PlaylistEntry{
+				Platform: "youtube",
+				SourceID: item.ContentDetails.VideoID,
+				URL:      fmt.Sprintf("https://www.youtube.com/watch?v=%s", item.ContentDetails.VideoID),
+				Title:    parsed.Title,
+				Artists:  []string{parsed.Artist},
+			})
+		}
+
+		if page.NextPageToken == "" {
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+
+	return info.Title, entries, nil
+}
+
+// isCollectionURL indica se a URL é uma playlist, álbum ou as músicas curtidas (e não
+// uma faixa isolada).
+func isCollectionURL(urlStr string) bool {
+	if isSpotifyURL(urlStr) {
+		return strings.Contains(urlStr, "/playlist/") || strings.Contains(urlStr, "/album/") ||
+			strings.Contains(urlStr, "/collection/")
+	}
+	return strings.Contains(urlStr, "playlist")
+}
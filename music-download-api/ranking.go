@@ -0,0 +1,185 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// clusterMinScore é a nota mínima (título, artista e duração) para duas faixas entrarem
+// no mesmo grupo do modo mesclado.
+var clusterMinScore = getEnvFloat("SEARCH_CLUSTER_MIN_SCORE", 0.8)
+
+// versionPattern encontra marcações de versão que distinguem gravações com o mesmo nome
+// ("Live", "Remix", "Acoustic"...). Faixas com marcações diferentes nunca são agrupadas.
+var versionPattern = regexp.MustCompile(
+	`(?i)\b(live|ao vivo|remix|mix|acoustic|acústico|acustico|instrumental|karaoke|cover|demo|edit|slowed|sped up|reverb|8d|nightcore)\b`,
+)
+
+// SearchCluster reúne resultados de plataformas diferentes que são a mesma gravação.
+// Tracks vem do melhor para o pior candidato ao download; Tracks[0] é o representante.
+type SearchCluster struct {
+	Title      string        `json:"title"`
+	Artist     string        `json:"artist"`
+	DurationMs int           `json:"duration_ms,omitempty"`
+	Platforms  []string      `json:"platforms"`
+	Score      float64       `json:"score"`
+	Relevance  float64       `json:"relevance"`
+	Popularity float64       `json:"popularity"`
+	Tracks     []SearchTrack `json:"tracks"`
+}
+
+// clusterEntry é uma faixa com o título já analisado e sua posição na busca original.
+type clusterEntry struct {
+	track    SearchTrack
+	parsed   ParsedTitle
+	versions string
+	position float64
+}
+
+// parseSearchTrack extrai artista e título comparáveis de um resultado. Vídeos do YouTube
+// passam pelo mesmo parser usado no casamento de downloads.
+func parseSearchTrack(track SearchTrack) ParsedTitle {
+	if track.Platform == "youtube" {
+		channel := ""
+		if len(track.Artists) > 0 {
+			channel = track.Artists[0].Name
+		}
+		return parseVideoTitle(track.Name, channel)
+	}
+
+	parsed := ParsedTitle{Title: cleanTrackTitle(track.Name)}
+	if len(track.Artists) > 0 {
+		parsed.Artist = track.Artists[0].Name
+	}
+	return parsed
+}
+
+// cleanTrackTitle remove do nome sufixos de remasterização e participações.
+func cleanTrackTitle(name string) string {
+	return strings.TrimSpace(featPattern.ReplaceAllString(remasterPattern.ReplaceAllString(name, ""), ""))
+}
+
+// trackVersions devolve as marcações de versão do nome, normalizadas e ordenadas.
+func trackVersions(name string) string {
+	found := make(map[string]bool)
+	for _, m := range versionPattern.FindAllString(name, -1) {
+		found[normalizeText(m)] = true
+	}
+	versions := make([]string, 0, len(found))
+	for v := range found {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	return strings.Join(versions, ",")
+}
+
+// trackPopularity converte a popularidade de cada plataforma para 0 a 1: a do Spotify já
+// vem de 0 a 100; no YouTube usa a escala logarítmica das visualizações (1 bilhão = 1).
+func trackPopularity(track SearchTrack) float64 {
+	switch {
+	case track.Popularity != nil:
+		return float64(*track.Popularity) / 100
+	case track.Views != nil && *track.Views > 0:
+		return math.Min(1, math.Log10(float64(*track.Views))/9)
+	}
+	return 0
+}
+
+// sameRecording diz se duas faixas são a mesma gravação, reaproveitando a nota do
+// casamento automático (título 50%, artista 30%, duração 20%).
+func sameRecording(a, b clusterEntry) bool {
+	if a.versions != b.versions {
+		return false
+	}
+	meta := TrackMetadata{Title: b.parsed.Title, DurationMs: b.track.DurationMs}
+	for _, artist := range b.track.Artists {
+		meta.Artists = append(meta.Artists, artist.Name)
+	}
+	if b.track.Platform == "youtube" {
+		meta.Artists = []string{b.parsed.Artist}
+	}
+	candidate := scoreCandidate(a.parsed, float64(a.track.DurationMs)/1000, meta)
+	// Título parecido é obrigatório: artista e duração sozinhos não bastam
+	return candidate.TitleScore >= 0.85 && candidate.Score >= clusterMinScore
+}
+
+// searchRelevance compara a consulta com "artista título" e com o título sozinho.
+func searchRelevance(query string, parsed ParsedTitle) float64 {
+	return math.Max(
+		stringSimilarity(query, parsed.Title),
+		stringSimilarity(query, strings.TrimSpace(parsed.Artist+" "+parsed.Title)),
+	)
+}
+
+// mergeSearchTracks agrupa as faixas de todas as plataformas que são a mesma gravação e
+// ordena os grupos por relevância (50%), popularidade (30%) e posição nas buscas
+// originais (20%), com um bônus para gravações disponíveis em mais de uma plataforma.
+func mergeSearchTracks(query string, tracks []SearchTrack) []SearchCluster {
+	// A posição é relativa à lista de cada plataforma, para que uma não domine a outra
+	counts := make(map[string]int)
+	for _, track := range tracks {
+		counts[track.Platform]++
+	}
+	seen := make(map[string]int)
+
+	var groups [][]clusterEntry
+	for _, track := range tracks {
+		entry := clusterEntry{
+			track:    track,
+			parsed:   parseSearchTrack(track),
+			versions: trackVersions(track.Name),
+			position: 1 - float64(seen[track.Platform])/float64(counts[track.Platform]),
+		}
+		seen[track.Platform]++
+
+		placed := false
+		for i, group := range groups {
+			if sameRecording(group[0], entry) || sameRecording(entry, group[0]) {
+				groups[i] = append(group, entry)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			groups = append(groups, []clusterEntry{entry})
+		}
+	}
+
+	clusters := make([]SearchCluster, 0, len(groups))
+	for _, group := range groups {
+		// Spotify primeiro (metadados completos) e, dentro da plataforma, os mais populares
+		sort.SliceStable(group, func(i, j int) bool {
+			if group[i].track.Platform != group[j].track.Platform {
+				return group[i].track.Platform == "spotify"
+			}
+			return trackPopularity(group[i].track) > trackPopularity(group[j].track)
+		})
+
+		cluster := SearchCluster{Title: group[0].parsed.Title, Artist: group[0].parsed.Artist}
+		platforms := make(map[string]bool)
+		position := 0.0
+		for _, entry := range group {
+			cluster.Tracks = append(cluster.Tracks, entry.track)
+			if !platforms[entry.track.Platform] {
+				platforms[entry.track.Platform] = true
+				cluster.Platforms = append(cluster.Platforms, entry.track.Platform)
+			}
+			if cluster.DurationMs == 0 {
+				cluster.DurationMs = entry.track.DurationMs
+			}
+			cluster.Relevance = math.Max(cluster.Relevance, searchRelevance(query, entry.parsed))
+			cluster.Popularity = math.Max(cluster.Popularity, trackPopularity(entry.track))
+			position = math.Max(position, entry.position)
+		}
+		cluster.Score = 0.5*cluster.Relevance + 0.3*cluster.Popularity + 0.2*position
+		if len(cluster.Platforms) > 1 {
+			cluster.Score = math.Min(1, cluster.Score+0.05)
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool { return clusters[i].Score > clusters[j].Score })
+	return clusters
+}
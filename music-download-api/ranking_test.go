@@ -0,0 +1,143 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func spotifyTestTrack(id, name, artist string, durationMs, popularity int) SearchTrack {
+	return SearchTrack{
+		ID: id, Platform: "spotify", Name: name, DurationMs: durationMs,
+		Artists: []SearchRef{{Name: artist}}, Popularity: &popularity,
+	}
+}
+
+func youtubeTestTrack(id, title, channel string, durationMs int, views int64) SearchTrack {
+	return SearchTrack{
+		ID: id, Platform: "youtube", Name: title, DurationMs: durationMs,
+		Artists: []SearchRef{{Name: channel}}, Views: &views,
+	}
+}
+
+func TestMergeSearchTracks(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		tracks []SearchTrack
+		// want lista os IDs de cada grupo, na ordem esperada dentro do grupo
+		want [][]string
+	}{
+		{
+			name:  "same recording across platforms",
+			query: "daft punk get lucky",
+			tracks: []SearchTrack{
+				spotifyTestTrack("s1", "Get Lucky (feat. Pharrell Williams)", "Daft Punk", 248000, 80),
+				youtubeTestTrack("y1", "Daft Punk - Get Lucky (Official Video)", "DaftPunkVEVO", 250000, 900000000),
+			},
+			want: [][]string{{"s1", "y1"}},
+		},
+		{
+			name:  "remaster suffix is ignored",
+			query: "song",
+			tracks: []SearchTrack{
+				spotifyTestTrack("s1", "Song - Remastered 2011", "Band", 200000, 50),
+				youtubeTestTrack("y1", "Song", "Band - Topic", 201000, 1000),
+			},
+			want: [][]string{{"s1", "y1"}},
+		},
+		{
+			name:  "live and remix versions stay apart",
+			query: "song",
+			tracks: []SearchTrack{
+				spotifyTestTrack("s1", "Song", "Band", 200000, 60),
+				spotifyTestTrack("s2", "Song - Live", "Band", 230000, 30),
+				youtubeTestTrack("y1", "Band - Song (Remix)", "Band", 200000, 1000),
+			},
+			want: [][]string{{"s1"}, {"s2"}, {"y1"}},
+		},
+		{
+			name:  "different songs with the same artist",
+			query: "band",
+			tracks: []SearchTrack{
+				spotifyTestTrack("s1", "Morning", "Band", 200000, 50),
+				spotifyTestTrack("s2", "Evening", "Band", 200000, 50),
+			},
+			want: [][]string{{"s1"}, {"s2"}},
+		},
+		{
+			name:  "popular youtube upload first within the platform",
+			query: "song",
+			tracks: []SearchTrack{
+				youtubeTestTrack("y1", "Band - Song (Lyrics)", "Lyrics Channel", 200000, 1000),
+				youtubeTestTrack("y2", "Band - Song", "BandVEVO", 200000, 50000000),
+			},
+			want: [][]string{{"y2", "y1"}},
+		},
+		{
+			name:   "empty",
+			query:  "nothing",
+			tracks: nil,
+			want:   [][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusters := mergeSearchTracks(tt.query, tt.tracks)
+			got := make([][]string, 0, len(clusters))
+			for _, cluster := range clusters {
+				var ids []string
+				for _, track := range cluster.Tracks {
+					ids = append(ids, track.ID)
+				}
+				got = append(got, ids)
+			}
+			// Grupos com nota igual podem vir em qualquer ordem relativa; compara como conjunto
+			if !sameGroups(got, tt.want) {
+				t.Errorf("mergeSearchTracks groups = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func sameGroups(got, want [][]string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	used := make([]bool, len(got))
+	for _, w := range want {
+		found := false
+		for i, g := range got {
+			if !used[i] && reflect.DeepEqual(g, w) {
+				used[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMergeSearchTracksRanking(t *testing.T) {
+	tracks := []SearchTrack{
+		spotifyTestTrack("s1", "Unrelated Tune", "Other", 180000, 10),
+		spotifyTestTrack("s2", "Get Lucky", "Daft Punk", 248000, 80),
+		youtubeTestTrack("y1", "Daft Punk - Get Lucky", "Daft Punk", 248000, 500000000),
+	}
+	clusters := mergeSearchTracks("get lucky", tracks)
+	if len(clusters) != 2 {
+		t.Fatalf("got %d clusters, want 2", len(clusters))
+	}
+	top := clusters[0]
+	if top.Tracks[0].ID != "s2" || !reflect.DeepEqual(top.Platforms, []string{"spotify", "youtube"}) {
+		t.Errorf("top cluster = %+v, want the Spotify track first on both platforms", top)
+	}
+	if top.Title != "Get Lucky" || top.Artist != "Daft Punk" || top.DurationMs != 248000 {
+		t.Errorf("top cluster metadata = %q / %q / %d", top.Title, top.Artist, top.DurationMs)
+	}
+	if top.Score <= clusters[1].Score || top.Score > 1 {
+		t.Errorf("scores = %v, %v; want the matching cluster first and capped at 1", top.Score, clusters[1].Score)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter espaça as chamadas a uma API externa para respeitar o limite de requisições
+// por segundo do serviço. É compartilhado por todos os jobs e importações.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// Limites documentados pelos serviços: MusicBrainz aceita 1 req/s e AcoustID 3 req/s.
+var (
+	musicBrainzLimiter = newRateLimiter(1)
+	acoustIDLimiter    = newRateLimiter(3)
+)
+
+func newRateLimiter(perSecond float64) *rateLimiter {
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// Wait bloqueia até a próxima janela livre e a reserva para o chamador.
+func (l *rateLimiter) Wait() {
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+
+	time.Sleep(time.Until(start))
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterSpacesCalls(t *testing.T) {
+	limiter := newRateLimiter(20)
+
+	var mu sync.Mutex
+	var calls []time.Time
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limiter.Wait()
+			mu.Lock()
+			calls = append(calls, time.Now())
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	first, last := calls[0], calls[0]
+	for _, call := range calls {
+		if call.Before(first) {
+			first = call
+		}
+		if call.After(last) {
+			last = call
+		}
+	}
+	// Quatro chamadas a 20/s ocupam pelo menos três intervalos de 50ms
+	if elapsed := last.Sub(first); elapsed < 140*time.Millisecond {
+		t.Errorf("4 calls took %s, want about 150ms", elapsed)
+	}
+}
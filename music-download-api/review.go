@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Estados de um item da fila de revisão.
+const (
+	reviewPending  = "pending"
+	reviewAccepted = "accepted"
+	reviewSkipped  = "skipped"
+)
+
+// ReviewItem é um casamento de metadados de baixa confiança aguardando decisão do usuário.
+type ReviewItem struct {
+	ID         string           `json:"id"`
+	JobID      string           `json:"job_id"`
+	File       string           `json:"file"`
+	Parsed     ParsedTitle      `json:"parsed"`
+	Candidates []MatchCandidate `json:"candidates"`
+	Status     string           `json:"status"`
+	Chosen     *TrackMetadata   `json:"chosen,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+
+	job   *DownloadJob
+	track *JobTrack
+}
+
+// ReviewDecisionRequest escolhe um candidato pelo índice ou informa outra origem
+// de metadados (mesmo formato de tag_sources em /download).
+type ReviewDecisionRequest struct {
+	Candidate *int   `json:"candidate,omitempty"`
+	Source    string `json:"source,omitempty"`
+}
+
+var (
+	reviewQueue   = make(map[string]*ReviewItem)
+	reviewQueueMu sync.Mutex
+)
+
+// enqueueReview coloca a faixa na fila de revisão com os candidatos encontrados.
+func enqueueReview(job *DownloadJob, track *JobTrack, parsed ParsedTitle, candidates []MatchCandidate) *ReviewItem {
+	item := &ReviewItem{
+		ID:         newJobID(),
+		JobID:      job.ID,
+		File:       track.File,
+		Parsed:     parsed,
+		Candidates: candidates,
+		Status:     reviewPending,
+		CreatedAt:  time.Now(),
+		job:        job,
+		track:      track,
+	}
+
+	reviewQueueMu.Lock()
+	reviewQueue[item.ID] = item
+	reviewQueueMu.Unlock()
+
+	updateJob(job, func(j *DownloadJob) { track.ReviewID = item.ID })
+	return item
+}
+
+// listReviews retorna os itens da fila; por padrão apenas os pendentes (?status=all para todos).
+func listReviews(c *gin.Context) {
+	status := c.DefaultQuery("status", reviewPending)
+
+	reviewQueueMu.Lock()
+	items := make([]ReviewItem, 0, len(reviewQueue))
+	for _, item := range reviewQueue {
+		if status == "all" || item.Status == status {
+			items = append(items, *item)
+		}
+	}
+	reviewQueueMu.Unlock()
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	})
+
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// takePendingReview busca um item pendente e o marca com o novo estado, evitando
+// que duas decisões simultâneas processem o mesmo item.
+func takePendingReview(c *gin.Context, status string) (*ReviewItem, bool) {
+	reviewQueueMu.Lock()
+	defer reviewQueueMu.Unlock()
+
+	item, ok := reviewQueue[c.Param("id")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review item not found"})
+		return nil, false
+	}
+	if item.Status != reviewPending {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Review item already %s", item.Status)})
+		return nil, false
+	}
+	item.Status = status
+	return item, true
+}
+
+// releaseReview devolve à fila um item retirado por takePendingReview.
+func releaseReview(item *ReviewItem) {
+	reviewQueueMu.Lock()
+	item.Status = reviewPending
+	reviewQueueMu.Unlock()
+}
+
+// acceptReview aplica o candidato escolhido (o melhor, se nenhum for informado) ou outra
+// origem de metadados ao arquivo, e grava as tags.
+func acceptReview(c *gin.Context) {
+	var request ReviewDecisionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	item, ok := takePendingReview(c, reviewAccepted)
+	if !ok {
+		return
+	}
+
+	// Enquanto o job roda, o pós-processamento ainda regrava o mesmo arquivo (tags, volume
+	// e letras); aplicar a revisão agora perderia uma das gravações
+	if status := snapshotJob(item.job).Status; status == jobQueued || status == jobRunning {
+		releaseReview(item)
+		c.JSON(http.StatusConflict, gin.H{"error": "Job is still running, accept the review after it finishes"})
+		return
+	}
+
+	var meta *TrackMetadata
+	var err error
+	switch {
+	case request.Source != "":
+		meta, err = fetchTrackMetadata(request.Source)
+	case request.Candidate != nil:
+		if *request.Candidate < 0 || *request.Candidate >= len(item.Candidates) {
+			err = fmt.Errorf("candidate index out of range")
+		} else {
+			meta = completeMetadata(item.Candidates[*request.Candidate].Metadata)
+		}
+	case len(item.Candidates) > 0:
+		meta = completeMetadata(item.Candidates[0].Metadata)
+	default:
+		err = fmt.Errorf("no candidates available, provide a source")
+	}
+	if err != nil {
+		releaseReview(item)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reviewQueueMu.Lock()
+	item.Chosen = meta
+	reviewQueueMu.Unlock()
+
+	updateJob(item.job, func(j *DownloadJob) { item.track.Metadata = meta })
+	tagTrack(item.job, item.track, meta, func(message string) {
+		log.Infof("[review %s] %s", item.ID, message)
+	})
+
+	c.JSON(http.StatusOK, snapshotReview(item))
+}
+
+// skipReview descarta o item, mantendo o arquivo sem novas tags.
+func skipReview(c *gin.Context) {
+	item, ok := takePendingReview(c, reviewSkipped)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, snapshotReview(item))
+}
+
+func snapshotReview(item *ReviewItem) ReviewItem {
+	reviewQueueMu.Lock()
+	defer reviewQueueMu.Unlock()
+	return *item
+}
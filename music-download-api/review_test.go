@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAcceptReviewWhileJobRuns(t *testing.T) {
+	job := &DownloadJob{ID: "review-job", Status: jobRunning}
+	track := &JobTrack{File: "Artist/Song.mp3"}
+	job.Tracks = []*JobTrack{track}
+	candidates := []MatchCandidate{{Metadata: TrackMetadata{Title: "Song", Artists: []string{"Artist"}}}}
+	item := enqueueReview(job, track, ParsedTitle{Artist: "Artist", Title: "Song"}, candidates)
+	t.Cleanup(func() {
+		reviewQueueMu.Lock()
+		delete(reviewQueue, item.ID)
+		reviewQueueMu.Unlock()
+	})
+
+	router := gin.New()
+	router.POST("/review/:id/accept", acceptReview)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/review/"+item.ID+"/accept", nil))
+
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusConflict)
+	}
+	if got := snapshotReview(item); got.Status != reviewPending || got.Chosen != nil {
+		t.Errorf("review item = %+v, want it still pending", got)
+	}
+	if snapshotJob(job).Tracks[0].Metadata != nil {
+		t.Error("metadata was applied while the job was running")
+	}
+}
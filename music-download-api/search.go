@@ -0,0 +1,808 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// searchSchemaVersion identifica o formato da resposta de /v2/search. /search continua
+// devolvendo os payloads originais do YouTube e do Spotify.
+const searchSchemaVersion = 2
+
+// searchTypes são os tipos aceitos no parâmetro type de /v2/search.
+var searchTypes = []string{"track", "album", "artist", "playlist"}
+
+// SearchImage é uma imagem (capa, foto ou miniatura) de um resultado.
+type SearchImage struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// SearchRef referencia um artista ou álbum dentro de outro resultado.
+type SearchRef struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+// SearchTrack é uma faixa do Spotify, um vídeo do YouTube ou uma faixa da biblioteca
+// local. InLibrary indica que a faixa já foi baixada (LibraryID é a faixa local).
+// Explicit só vem do Spotify: a restrição de idade do YouTube não indica letra explícita.
+type SearchTrack struct {
+	ID          string        `json:"id"`
+	Platform    string        `json:"platform"`
+	Name        string        `json:"name"`
+	Artists     []SearchRef   `json:"artists"`
+	Album       *SearchRef    `json:"album,omitempty"`
+	DurationMs  int           `json:"duration_ms,omitempty"`
+	Explicit    *bool         `json:"explicit,omitempty"`
+	Popularity  *int          `json:"popularity,omitempty"`
+	Views       *int64        `json:"views,omitempty"`
+	PreviewURL  string        `json:"preview_url,omitempty"`
+	ISRC        string        `json:"isrc,omitempty"`
+	PublishedAt string        `json:"published_at,omitempty"`
+	Images      []SearchImage `json:"images"`
+	URL         string        `json:"url"`
+	InLibrary   bool          `json:"in_library"`
+	LibraryID   string        `json:"library_id,omitempty"`
+}
+
+// SearchAlbum é um álbum do Spotify ou da biblioteca local.
+type SearchAlbum struct {
+	ID          string        `json:"id"`
+	Platform    string        `json:"platform"`
+	Name        string        `json:"name"`
+	AlbumType   string        `json:"album_type,omitempty"`
+	Artists     []SearchRef   `json:"artists"`
+	ReleaseDate string        `json:"release_date,omitempty"`
+	TotalTracks int           `json:"total_tracks,omitempty"`
+	Images      []SearchImage `json:"images"`
+	URL         string        `json:"url"`
+	InLibrary   bool          `json:"in_library"`
+	LibraryID   string        `json:"library_id,omitempty"`
+}
+
+// SearchArtist é um artista do Spotify ou da biblioteca local.
+type SearchArtist struct {
+	ID         string        `json:"id"`
+	Platform   string        `json:"platform"`
+	Name       string        `json:"name"`
+	Genres     []string      `json:"genres,omitempty"`
+	Popularity *int          `json:"popularity,omitempty"`
+	Followers  *int          `json:"followers,omitempty"`
+	Images     []SearchImage `json:"images"`
+	URL        string        `json:"url"`
+	InLibrary  bool          `json:"in_library"`
+}
+
+// SearchPlaylist é uma playlist do Spotify ou do YouTube. TrackCount não vem na busca
+// do YouTube.
+type SearchPlaylist struct {
+	ID          string        `json:"id"`
+	Platform    string        `json:"platform"`
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Owner       string        `json:"owner,omitempty"`
+	TrackCount  *int          `json:"track_count,omitempty"`
+	Public      *bool         `json:"public,omitempty"`
+	PublishedAt string        `json:"published_at,omitempty"`
+	Images      []SearchImage `json:"images"`
+	URL         string        `json:"url"`
+}
+
+// SearchTotals traz o total de resultados informado por cada plataforma. O total do
+// YouTube é o pageInfo.totalResults do search.list, uma estimativa da própria API.
+type SearchTotals struct {
+	Tracks    int `json:"tracks"`
+	Albums    int `json:"albums"`
+	Artists   int `json:"artists"`
+	Playlists int `json:"playlists"`
+}
+
+// SearchPagination descreve a página atual. NextCursor guarda a posição de cada tipo nas
+// duas plataformas e deve ser reenviado como cursor para obter a próxima página; vazio
+// quando nenhuma plataforma tem mais resultados.
+//
+// Os filtros de duração e explicit são aplicados depois da paginação: Total, HasNext e
+// NextCursor contam os resultados antes deles, e Filtered informa quantas faixas da página
+// foram descartadas. Uma página filtrada pode vir com menos de Limit faixas (até vazia) e
+// ainda ter HasNext; o cliente deve seguir o cursor até HasNext ser falso.
+type SearchPagination struct {
+	Limit       int          `json:"limit"`
+	Offset      int          `json:"offset"`
+	Total       SearchTotals `json:"total"`
+	Filtered    int          `json:"filtered,omitempty"`
+	HasNext     bool         `json:"has_next"`
+	HasPrevious bool         `json:"has_previous"`
+	NextCursor  string       `json:"next_cursor,omitempty"`
+}
+
+// SearchResponseV2 é a resposta normalizada de /v2/search.
+type SearchResponseV2 struct {
+	Version    int              `json:"version"`
+	Query      string           `json:"query"`
+	Tracks     []SearchTrack    `json:"tracks"`
+	Albums     []SearchAlbum    `json:"albums"`
+	Artists    []SearchArtist   `json:"artists"`
+	Playlists  []SearchPlaylist `json:"playlists"`
+	Merged     []SearchCluster  `json:"merged,omitempty"`
+	Pagination SearchPagination `json:"pagination"`
+	Errors     []string         `json:"errors,omitempty"`
+}
+
+// searchParams são os parâmetros já validados de uma busca. SpotifyOffsets e
+// LibraryOffsets trazem o offset de cada tipo ainda a buscar no Spotify e na biblioteca,
+// e YouTubeTokens o pageToken de cada tipo (video, playlist) ainda a buscar no YouTube
+// ("" na primeira página).
+type searchParams struct {
+	Query          string
+	Types          map[string]bool
+	Sources        map[string]bool
+	Limit          int
+	Offset         int
+	Merged         bool
+	Filters        SearchFilters
+	SpotifyOffsets map[string]int
+	YouTubeTokens  map[string]string
+	LibraryOffsets map[string]int
+}
+
+// searchCursor é o conteúdo do cursor opaco de /v2/search: o próximo offset de cada tipo
+// no Spotify e na biblioteca e o nextPageToken de cada tipo no YouTube. Tipos esgotados
+// ficam de fora. Types e Sources guardam os parâmetros da busca que gerou o cursor, que
+// só vale para uma busca com os mesmos tipos e origens.
+type searchCursor struct {
+	Spotify map[string]int    `json:"s,omitempty"`
+	YouTube map[string]string `json:"y,omitempty"`
+	Library map[string]int    `json:"l,omitempty"`
+	Types   []string          `json:"t,omitempty"`
+	Sources []string          `json:"o,omitempty"`
+}
+
+// youtubeKinds mapeia os tipos de /v2/search para os tipos do search.list do YouTube.
+var youtubeKinds = map[string]string{"track": "video", "playlist": "playlist"}
+
+// encodeSearchCursor serializa o cursor em base64 (URL-safe); cursor vazio vira "".
+func encodeSearchCursor(cursor searchCursor) string {
+	if len(cursor.Spotify) == 0 && len(cursor.YouTube) == 0 && len(cursor.Library) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeSearchCursor lê um cursor gerado por encodeSearchCursor.
+func decodeSearchCursor(raw string) (searchCursor, error) {
+	var cursor searchCursor
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cursor, fmt.Errorf("invalid cursor")
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, fmt.Errorf("invalid cursor")
+	}
+	for t, offset := range cursor.Spotify {
+		if !contains(searchTypes, t) || offset < 0 {
+			return cursor, fmt.Errorf("invalid cursor")
+		}
+	}
+	for t, offset := range cursor.Library {
+		if !contains(searchTypes, t) || offset < 0 {
+			return cursor, fmt.Errorf("invalid cursor")
+		}
+	}
+	for kind := range cursor.YouTube {
+		if kind != "video" && kind != "playlist" {
+			return cursor, fmt.Errorf("invalid cursor")
+		}
+	}
+	return cursor, nil
+}
+
+// searchSet devolve as chaves marcadas em ordem, para gravar e comparar tipos e origens.
+func searchSet(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key, ok := range set {
+		if ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// matchesSearch indica se o cursor foi gerado por uma busca com os mesmos tipos e origens.
+func (cursor searchCursor) matchesSearch(params searchParams) bool {
+	return strings.Join(cursor.Types, ",") == strings.Join(searchSet(params.Types), ",") &&
+		strings.Join(cursor.Sources, ",") == strings.Join(searchSet(params.Sources), ",")
+}
+
+// spotifyPaging são os campos de paginação de cada tipo em /v1/search do Spotify.
+type spotifyPaging struct {
+	Total    int    `json:"total"`
+	Next     string `json:"next"`
+	Previous string `json:"previous"`
+}
+
+// spotifySearchResult é o payload de /v1/search do Spotify para os quatro tipos.
+type spotifySearchResult struct {
+	Tracks struct {
+		spotifyPaging
+		Items []spotifyTrackObject `json:"items"`
+	} `json:"tracks"`
+	Albums struct {
+		spotifyPaging
+		Items []spotifyAlbumRef `json:"items"`
+	} `json:"albums"`
+	Artists struct {
+		spotifyPaging
+		Items []spotifyArtistObject `json:"items"`
+	} `json:"artists"`
+	Playlists struct {
+		spotifyPaging
+		Items []*spotifyPlaylistObject `json:"items"`
+	} `json:"playlists"`
+}
+
+type spotifyArtistObject struct {
+	ID         string         `json:"id"`
+	Name       string         `json:"name"`
+	Genres     []string       `json:"genres"`
+	Popularity int            `json:"popularity"`
+	Images     []spotifyImage `json:"images"`
+	Followers  struct {
+		Total int `json:"total"`
+	} `json:"followers"`
+}
+
+type spotifyPlaylistObject struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Public      *bool          `json:"public"`
+	Images      []spotifyImage `json:"images"`
+	Owner       struct {
+		DisplayName string `json:"display_name"`
+	} `json:"owner"`
+	Tracks struct {
+		Total int `json:"total"`
+	} `json:"tracks"`
+}
+
+// youtubeSearchItem é um item de search.list do YouTube.
+type youtubeSearchItem struct {
+	ID struct {
+		Kind       string `json:"kind"`
+		VideoID    string `json:"videoId"`
+		PlaylistID string `json:"playlistId"`
+	} `json:"id"`
+	Snippet struct {
+		Title        string `json:"title"`
+		Description  string `json:"description"`
+		ChannelID    string `json:"channelId"`
+		ChannelTitle string `json:"channelTitle"`
+		PublishedAt  string `json:"publishedAt"`
+		Thumbnails   map[string]struct {
+			URL    string `json:"url"`
+			Width  int    `json:"width"`
+			Height int    `json:"height"`
+		} `json:"thumbnails"`
+	} `json:"snippet"`
+}
+
+var iso8601DurationPattern = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// iso8601ToMs converte durações do YouTube (PT1H2M3S) para milissegundos.
+func iso8601ToMs(duration string) int {
+	m := iso8601DurationPattern.FindStringSubmatch(duration)
+	if m == nil {
+		return 0
+	}
+	var total int
+	for i, unit := range []int{86400, 3600, 60, 1} {
+		n, _ := strconv.Atoi(m[i+1])
+		total += n * unit
+	}
+	return total * 1000
+}
+
+func spotifyImages(images []spotifyImage) []SearchImage {
+	result := make([]SearchImage, 0, len(images))
+	for _, img := range images {
+		result = append(result, SearchImage{URL: img.URL, Width: img.Width, Height: img.Height})
+	}
+	return result
+}
+
+func spotifyArtistRefs(artists []spotifyArtistRef) []SearchRef {
+	refs := make([]SearchRef, 0, len(artists))
+	for _, artist := range artists {
+		refs = append(refs, SearchRef{
+			ID: artist.ID, Name: artist.Name, URL: "https://open.spotify.com/artist/" + artist.ID,
+		})
+	}
+	return refs
+}
+
+// youtubeImages devolve as miniaturas da maior para a menor.
+func youtubeImages(item youtubeSearchItem) []SearchImage {
+	images := []SearchImage{}
+	for _, size := range []string{"maxres", "high", "medium", "default"} {
+		if thumb, ok := item.Snippet.Thumbnails[size]; ok {
+			images = append(images, SearchImage{URL: thumb.URL, Width: thumb.Width, Height: thumb.Height})
+		}
+	}
+	return images
+}
+
+// normalizeSpotifyTrack converte uma faixa do Spotify para o modelo normalizado.
+func normalizeSpotifyTrack(track spotifyTrackObject) SearchTrack {
+	popularity, explicit := track.Popularity, track.Explicit
+	return SearchTrack{
+		ID:         track.ID,
+		Platform:   "spotify",
+		Name:       track.Name,
+		Artists:    spotifyArtistRefs(track.Artists),
+		Album:      &SearchRef{ID: track.Album.ID, Name: track.Album.Name, URL: "https://open.spotify.com/album/" + track.Album.ID},
+		DurationMs: track.DurationMs,
+		Explicit:   &explicit,
+		Popularity: &popularity,
+		PreviewURL: track.PreviewURL,
+		ISRC:       track.ExternalIDs.ISRC,
+		Images:     spotifyImages(track.Album.Images),
+		URL:        "https://open.spotify.com/track/" + track.ID,
+	}
+}
+
+// normalizeSpotifyAlbum converte um álbum do Spotify para o modelo normalizado.
+func normalizeSpotifyAlbum(album spotifyAlbumRef) SearchAlbum {
+	return SearchAlbum{
+		ID:          album.ID,
+		Platform:    "spotify",
+		Name:        album.Name,
+		AlbumType:   album.AlbumType,
+		Artists:     spotifyArtistRefs(album.Artists),
+		ReleaseDate: album.ReleaseDate,
+		TotalTracks: album.TotalTracks,
+		Images:      spotifyImages(album.Images),
+		URL:         "https://open.spotify.com/album/" + album.ID,
+	}
+}
+
+// normalizeSpotifyArtist converte um artista do Spotify para o modelo normalizado.
+func normalizeSpotifyArtist(artist spotifyArtistObject) SearchArtist {
+	popularity, followers := artist.Popularity, artist.Followers.Total
+	return SearchArtist{
+		ID:         artist.ID,
+		Platform:   "spotify",
+		Name:       artist.Name,
+		Genres:     artist.Genres,
+		Popularity: &popularity,
+		Followers:  &followers,
+		Images:     spotifyImages(artist.Images),
+		URL:        "https://open.spotify.com/artist/" + artist.ID,
+	}
+}
+
+// normalizeSpotifyPlaylist converte uma playlist do Spotify para o modelo normalizado.
+func normalizeSpotifyPlaylist(playlist spotifyPlaylistObject) SearchPlaylist {
+	count := playlist.Tracks.Total
+	return SearchPlaylist{
+		ID:          playlist.ID,
+		Platform:    "spotify",
+		Name:        playlist.Name,
+		Description: playlist.Description,
+		Owner:       playlist.Owner.DisplayName,
+		TrackCount:  &count,
+		Public:      playlist.Public,
+		Images:      spotifyImages(playlist.Images),
+		URL:         "https://open.spotify.com/playlist/" + playlist.ID,
+	}
+}
+
+// searchSpotify busca os tipos pedidos no Spotify, com uma chamada ao /v1/search por
+// offset distinto (na primeira página, uma só), e anota em next o offset seguinte de
+// cada tipo que ainda tem resultados.
+func searchSpotify(params searchParams, response *SearchResponseV2, next *searchCursor) error {
+	byOffset := make(map[int][]string)
+	var offsets []int
+	for _, t := range searchTypes {
+		offset, ok := params.SpotifyOffsets[t]
+		if !ok || !params.Types[t] {
+			continue
+		}
+		if _, seen := byOffset[offset]; !seen {
+			offsets = append(offsets, offset)
+		}
+		byOffset[offset] = append(byOffset[offset], t)
+	}
+	sort.Ints(offsets)
+
+	for _, offset := range offsets {
+		if err := searchSpotifyPage(params, byOffset[offset], offset, response, next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// searchSpotifyPage busca uma página dos tipos informados a partir do offset.
+func searchSpotifyPage(params searchParams, types []string, offset int, response *SearchResponseV2, next *searchCursor) error {
+	endpoint := fmt.Sprintf(
+		"https://api.spotify.com/v1/search?q=%s&type=%s&limit=%d&offset=%d",
+		url.QueryEscape(spotifyQuery(params.Query, params.Filters)), strings.Join(types, ","), params.Limit, offset,
+	)
+	if params.Filters.Market != "" {
+		endpoint += "&market=" + params.Filters.Market
+	}
+	var result spotifySearchResult
+	if err := spotifyGet(endpoint, &result); err != nil {
+		return err
+	}
+
+	for _, track := range result.Tracks.Items {
+		response.Tracks = append(response.Tracks, normalizeSpotifyTrack(track))
+	}
+	for _, album := range result.Albums.Items {
+		response.Albums = append(response.Albums, normalizeSpotifyAlbum(album))
+	}
+	for _, artist := range result.Artists.Items {
+		response.Artists = append(response.Artists, normalizeSpotifyArtist(artist))
+	}
+	// A busca de playlists do Spotify pode trazer itens nulos
+	for _, playlist := range result.Playlists.Items {
+		if playlist != nil {
+			response.Playlists = append(response.Playlists, normalizeSpotifyPlaylist(*playlist))
+		}
+	}
+
+	pagination := &response.Pagination
+	pagination.Total.Tracks += result.Tracks.Total
+	pagination.Total.Albums += result.Albums.Total
+	pagination.Total.Artists += result.Artists.Total
+	pagination.Total.Playlists += result.Playlists.Total
+	pagination.HasPrevious = pagination.HasPrevious || offset > 0
+
+	pages := map[string]spotifyPaging{
+		"track":    result.Tracks.spotifyPaging,
+		"album":    result.Albums.spotifyPaging,
+		"artist":   result.Artists.spotifyPaging,
+		"playlist": result.Playlists.spotifyPaging,
+	}
+	for _, t := range types {
+		if pages[t].Next != "" {
+			if next.Spotify == nil {
+				next.Spotify = make(map[string]int)
+			}
+			next.Spotify[t] = offset + params.Limit
+		}
+	}
+	return nil
+}
+
+// searchYouTube busca vídeos e/ou playlists no YouTube e anota em next o token da
+// próxima página de cada tipo. Como o filtro de categoria (música) só vale para vídeos,
+// cada tipo é buscado separadamente. A duração dos vídeos, que o search.list não informa,
+// vem de uma chamada extra ao videos.list.
+func searchYouTube(params searchParams, response *SearchResponseV2, next *searchCursor) ([]SearchTrack, []SearchPlaylist, error) {
+	var tracks []SearchTrack
+	var playlists []SearchPlaylist
+	var videoIDs []string
+
+	for _, kind := range []string{"video", "playlist"} {
+		token, ok := params.YouTubeTokens[kind]
+		if !ok {
+			continue
+		}
+
+		query := url.Values{
+			"part":       {"snippet"},
+			"q":          {params.Query},
+			"type":       {kind},
+			"maxResults": {strconv.Itoa(params.Limit)},
+		}
+		if kind == "video" {
+			query.Set("videoCategoryId", youtubeMusicCategory)
+			query.Set("videoDuration", youtubeDuration(params.Filters))
+		}
+		if params.Filters.Market != "" {
+			query.Set("regionCode", params.Filters.Market)
+		}
+		if token != "" {
+			query.Set("pageToken", token)
+			response.Pagination.HasPrevious = true
+		}
+		var result struct {
+			Items         []youtubeSearchItem `json:"items"`
+			NextPageToken string              `json:"nextPageToken"`
+			PageInfo      struct {
+				TotalResults int `json:"totalResults"`
+			} `json:"pageInfo"`
+		}
+		if err := youtubeGet("search", query, &result); err != nil {
+			return nil, nil, err
+		}
+		if kind == "video" {
+			response.Pagination.Total.Tracks += result.PageInfo.TotalResults
+		} else {
+			response.Pagination.Total.Playlists += result.PageInfo.TotalResults
+		}
+		if result.NextPageToken != "" {
+			if next.YouTube == nil {
+				next.YouTube = make(map[string]string)
+			}
+			next.YouTube[kind] = result.NextPageToken
+		}
+
+		for _, item := range result.Items {
+			switch {
+			case item.ID.VideoID != "":
+				videoIDs = append(videoIDs, item.ID.VideoID)
+				tracks = append(tracks, SearchTrack{
+					ID:       item.ID.VideoID,
+					Platform: "youtube",
+					Name:     item.Snippet.Title,
+					Artists: []SearchRef{{
+						ID: item.Snippet.ChannelID, Name: item.Snippet.ChannelTitle,
+						URL: "https://www.youtube.com/channel/" + item.Snippet.ChannelID,
+					}},
+					PublishedAt: item.Snippet.PublishedAt,
+					Images:      youtubeImages(item),
+					URL:         "https://www.youtube.com/watch?v=" + item.ID.VideoID,
+				})
+			case item.ID.PlaylistID != "":
+				playlists = append(playlists, SearchPlaylist{
+					ID:          item.ID.PlaylistID,
+					Platform:    "youtube",
+					Name:        item.Snippet.Title,
+					Description: item.Snippet.Description,
+					Owner:       item.Snippet.ChannelTitle,
+					PublishedAt: item.Snippet.PublishedAt,
+					Images:      youtubeImages(item),
+					URL:         "https://www.youtube.com/playlist?list=" + item.ID.PlaylistID,
+				})
+			}
+		}
+	}
+
+	if len(videoIDs) > 0 {
+		var details struct {
+			Items []struct {
+				ID             string `json:"id"`
+				ContentDetails struct {
+					Duration string `json:"duration"`
+				} `json:"contentDetails"`
+				Statistics struct {
+					ViewCount *int64 `json:"viewCount,string"`
+				} `json:"statistics"`
+			} `json:"items"`
+		}
+		detailQuery := url.Values{"part": {"contentDetails,statistics"}, "id": {strings.Join(videoIDs, ",")}}
+		if err := youtubeGet("videos", detailQuery, &details); err != nil {
+			log.WithError(err).Warn("Failed to get YouTube video details")
+		}
+		for _, item := range details.Items {
+			for i := range tracks {
+				if tracks[i].ID != item.ID {
+					continue
+				}
+				tracks[i].DurationMs = iso8601ToMs(item.ContentDetails.Duration)
+				tracks[i].Views = item.Statistics.ViewCount
+			}
+		}
+	}
+
+	return tracks, playlists, nil
+}
+
+// parseSearchParams valida os parâmetros de /v2/search.
+func parseSearchParams(c *gin.Context) (searchParams, error) {
+	params := searchParams{
+		Query: strings.TrimSpace(c.Query("query")),
+		Types: make(map[string]bool),
+	}
+	if params.Query == "" {
+		return params, fmt.Errorf("Missing query")
+	}
+
+	switch mode := c.DefaultQuery("mode", "split"); mode {
+	case "split":
+	case "merged":
+		params.Merged = true
+	default:
+		return params, fmt.Errorf("invalid mode %q (use split or merged)", mode)
+	}
+
+	for _, t := range strings.Split(c.DefaultQuery("type", strings.Join(searchTypes, ",")), ",") {
+		t = strings.TrimSpace(t)
+		if !contains(searchTypes, t) {
+			return params, fmt.Errorf("invalid type %q (use track, album, artist or playlist)", t)
+		}
+		params.Types[t] = true
+	}
+
+	var err error
+	if params.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "10")); err != nil || params.Limit < 1 || params.Limit > 50 {
+		return params, fmt.Errorf("limit must be between 1 and 50")
+	}
+	if params.Offset, err = strconv.Atoi(c.DefaultQuery("offset", "0")); err != nil || params.Offset < 0 {
+		return params, fmt.Errorf("offset must be a non-negative integer")
+	}
+	if params.Filters, err = parseSearchFilters(c); err != nil {
+		return params, err
+	}
+
+	params.Sources = make(map[string]bool)
+	for _, source := range strings.Split(c.DefaultQuery("sources", "spotify,youtube"), ",") {
+		source = strings.TrimSpace(source)
+		if !contains(searchSources, source) {
+			return params, fmt.Errorf("invalid source %q (use library, spotify or youtube)", source)
+		}
+		params.Sources[source] = true
+	}
+
+	// O cursor substitui o offset: só continuam os tipos e plataformas que ainda tinham
+	// resultados na página anterior
+	params.SpotifyOffsets = make(map[string]int)
+	params.YouTubeTokens = make(map[string]string)
+	params.LibraryOffsets = make(map[string]int)
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := decodeSearchCursor(raw)
+		if err != nil {
+			return params, err
+		}
+		if !cursor.matchesSearch(params) {
+			return params, fmt.Errorf("cursor does not match the requested type and sources")
+		}
+		params.Offset = -1
+		for t, offset := range cursor.Spotify {
+			params.SpotifyOffsets[t] = offset
+			if params.Offset < 0 || offset < params.Offset {
+				params.Offset = offset
+			}
+		}
+		params.Offset = max(params.Offset, 0)
+		for kind, token := range cursor.YouTube {
+			params.YouTubeTokens[kind] = token
+		}
+		for t, offset := range cursor.Library {
+			params.LibraryOffsets[t] = offset
+		}
+		return params, nil
+	}
+
+	// O YouTube pagina só por token: um offset repetiria a primeira página dele
+	if params.Offset > 0 && params.Sources["youtube"] {
+		return params, fmt.Errorf("offset is not supported with the youtube source, use cursor")
+	}
+
+	for t := range params.Types {
+		if params.Sources["spotify"] {
+			params.SpotifyOffsets[t] = params.Offset
+		}
+		if kind, ok := youtubeKinds[t]; ok && params.Sources["youtube"] {
+			params.YouTubeTokens[kind] = ""
+		}
+		// A biblioteca não tem playlists
+		if t != "playlist" && params.Sources["library"] {
+			params.LibraryOffsets[t] = params.Offset
+		}
+	}
+	return params, nil
+}
+
+// searchV2 busca no Spotify e no YouTube em paralelo, e na biblioteca local, e devolve
+// um único esquema normalizado (ver SearchResponseV2). Parâmetros: query, type (track,
+// album, artist, playlist), sources (library, spotify, youtube; padrão spotify,youtube),
+// limit, offset, cursor, mode e os filtros de parseSearchFilters. cursor (de
+// pagination.next_cursor) avança todas as origens juntas e só é aceito com os mesmos
+// type e sources da busca que o gerou; offset só vale sem o YouTube entre as origens.
+// Com mode=merged, as faixas também vêm agrupadas por gravação em merged (ver
+// mergeSearchTracks). Resultados remotos que já estão na biblioteca vêm com in_library.
+func searchV2(c *gin.Context) {
+	params, err := parseSearchParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := SearchResponseV2{
+		Version:    searchSchemaVersion,
+		Query:      params.Query,
+		Tracks:     []SearchTrack{},
+		Albums:     []SearchAlbum{},
+		Artists:    []SearchArtist{},
+		Playlists:  []SearchPlaylist{},
+		Pagination: SearchPagination{Limit: params.Limit, Offset: params.Offset},
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var next searchCursor
+	var youtubeTracks []SearchTrack
+	var youtubePlaylists []SearchPlaylist
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		mu.Lock()
+		defer mu.Unlock()
+		if err := searchSpotify(params, &response, &next); err != nil {
+			log.WithError(err).Error("Failed to search Spotify")
+			response.Errors = append(response.Errors, "spotify: "+err.Error())
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		var yt SearchResponseV2
+		var ytNext searchCursor
+		tracks, playlists, err := searchYouTube(params, &yt, &ytNext)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			log.WithError(err).Error("Failed to search YouTube")
+			response.Errors = append(response.Errors, "youtube: "+err.Error())
+			return
+		}
+		youtubeTracks, youtubePlaylists = tracks, playlists
+		next.YouTube = ytNext.YouTube
+		response.Pagination.HasPrevious = response.Pagination.HasPrevious || yt.Pagination.HasPrevious
+		response.Pagination.Total.Tracks += yt.Pagination.Total.Tracks
+		response.Pagination.Total.Playlists += yt.Pagination.Total.Playlists
+	}()
+	wg.Wait()
+
+	combineSearchResults(params, &response, cachedLibraryTracks(), youtubeTracks, youtubePlaylists, &next)
+	if c.Query("cursor") == "" && params.Offset == 0 {
+		go recordSearch(response)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// combineSearchResults junta à resposta do Spotify os resultados da biblioteca e do
+// YouTube, marca os remotos que já estão na biblioteca, fecha o cursor e aplica os
+// filtros e o agrupamento de mode=merged.
+func combineSearchResults(params searchParams, response *SearchResponseV2, library []LibraryTrack, youtubeTracks []SearchTrack, youtubePlaylists []SearchPlaylist, next *searchCursor) {
+	// A biblioteca vem na frente: é o que já temos e não precisa ser baixado
+	var local SearchResponseV2
+	searchLibrary(params, library, &local, next)
+	response.Tracks = append(local.Tracks, response.Tracks...)
+	response.Albums = append(local.Albums, response.Albums...)
+	response.Artists = append(local.Artists, response.Artists...)
+	response.Pagination.Total.Tracks += local.Pagination.Total.Tracks
+	response.Pagination.Total.Albums += local.Pagination.Total.Albums
+	response.Pagination.Total.Artists += local.Pagination.Total.Artists
+	response.Pagination.HasPrevious = response.Pagination.HasPrevious || local.Pagination.HasPrevious
+
+	next.Types, next.Sources = searchSet(params.Types), searchSet(params.Sources)
+	response.Pagination.NextCursor = encodeSearchCursor(*next)
+	response.Pagination.HasNext = response.Pagination.NextCursor != ""
+
+	// Spotify primeiro, YouTube depois, como na resposta antiga montada pelo frontend
+	response.Tracks = append(response.Tracks, youtubeTracks...)
+	response.Playlists = append(response.Playlists, youtubePlaylists...)
+	markInLibrary(response, library)
+
+	unfiltered := len(response.Tracks)
+	response.Tracks = filterSearchTracks(response.Tracks, params.Filters)
+	response.Pagination.Filtered = unfiltered - len(response.Tracks)
+	if params.Merged {
+		response.Merged = mergeSearchTracks(params.Query, response.Tracks)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func TestIso8601ToMs(t *testing.T) {
+	tests := []struct {
+		duration string
+		want     int
+	}{
+		{"PT3M20S", 200000},
+		{"PT1H2M3S", 3723000},
+		{"PT45S", 45000},
+		{"P1DT1S", 86401000},
+		{"P0D", 0},
+		{"3:20", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := iso8601ToMs(tt.duration); got != tt.want {
+			t.Errorf("iso8601ToMs(%q) = %d, want %d", tt.duration, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeSpotifyTrack(t *testing.T) {
+	track := spotifyTrackObject{
+		ID: "t1", Name: "Song", DurationMs: 200000, Popularity: 70,
+		Artists: []spotifyArtistRef{{ID: "a1", Name: "Band"}},
+		Album:   spotifyAlbumRef{ID: "al1", Name: "Album", Images: []spotifyImage{{URL: "https://i.scdn.co/cover", Width: 640, Height: 640}}},
+	}
+	track.ExternalIDs.ISRC = "USRC17607839"
+
+	got := normalizeSpotifyTrack(track)
+	if got.ID != "t1" || got.Platform != "spotify" || got.Name != "Song" || got.URL != "https://open.spotify.com/track/t1" {
+		t.Errorf("track = %+v", got)
+	}
+	if got.DurationMs != 200000 || got.Popularity == nil || *got.Popularity != 70 || got.ISRC != "USRC17607839" {
+		t.Errorf("track details = %+v", got)
+	}
+	wantArtists := []SearchRef{{ID: "a1", Name: "Band", URL: "https://open.spotify.com/artist/a1"}}
+	if !reflect.DeepEqual(got.Artists, wantArtists) {
+		t.Errorf("artists = %+v, want %+v", got.Artists, wantArtists)
+	}
+	if got.Album == nil || got.Album.Name != "Album" || got.Album.URL != "https://open.spotify.com/album/al1" {
+		t.Errorf("album = %+v", got.Album)
+	}
+	wantImages := []SearchImage{{URL: "https://i.scdn.co/cover", Width: 640, Height: 640}}
+	if !reflect.DeepEqual(got.Images, wantImages) {
+		t.Errorf("images = %+v, want %+v", got.Images, wantImages)
+	}
+}
+
+func TestSearchCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor searchCursor
+	}{
+		{"spotify only", searchCursor{Spotify: map[string]int{"track": 10, "album": 20}}},
+		{"youtube only", searchCursor{YouTube: map[string]string{"video": "CAoQAA", "playlist": "CBQQAA"}}},
+		{
+			name: "all sources with search parameters",
+			cursor: searchCursor{
+				Spotify: map[string]int{"track": 10},
+				YouTube: map[string]string{"video": "CAoQAA"},
+				Library: map[string]int{"artist": 5},
+				Types:   []string{"artist", "track"},
+				Sources: []string{"library", "spotify", "youtube"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := encodeSearchCursor(tt.cursor)
+			if raw == "" {
+				t.Fatal("encodeSearchCursor returned an empty cursor")
+			}
+			got, err := decodeSearchCursor(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.cursor) {
+				t.Errorf("decodeSearchCursor = %+v, want %+v", got, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestEncodeSearchCursorWithoutPositions(t *testing.T) {
+	// Sem posições não há próxima página, mesmo com os parâmetros da busca preenchidos
+	cursor := searchCursor{Types: []string{"track"}, Sources: []string{"spotify"}}
+	if raw := encodeSearchCursor(cursor); raw != "" {
+		t.Errorf("encodeSearchCursor = %q, want empty", raw)
+	}
+}
+
+func TestDecodeSearchCursorRejectsInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"not base64", "%%%"},
+		{"not json", encode("hello")},
+		{"unknown spotify type", encode(`{"s":{"song":10}}`)},
+		{"negative offset", encode(`{"s":{"track":-10}}`)},
+		{"unknown library type", encode(`{"l":{"playlist2":0}}`)},
+		{"unknown youtube kind", encode(`{"y":{"channel":"abc"}}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeSearchCursor(tt.raw); err == nil {
+				t.Errorf("decodeSearchCursor(%q) succeeded, want error", tt.raw)
+			}
+		})
+	}
+}
+
+func TestSearchCursorMatchesSearch(t *testing.T) {
+	params := searchParams{
+		Types:   map[string]bool{"track": true, "album": true},
+		Sources: map[string]bool{"spotify": true, "youtube": true},
+	}
+	tests := []struct {
+		name   string
+		cursor searchCursor
+		want   bool
+	}{
+		{"same types and sources", searchCursor{Types: []string{"album", "track"}, Sources: []string{"spotify", "youtube"}}, true},
+		{"other types", searchCursor{Types: []string{"track"}, Sources: []string{"spotify", "youtube"}}, false},
+		{"other sources", searchCursor{Types: []string{"album", "track"}, Sources: []string{"library", "spotify", "youtube"}}, false},
+		{"without search parameters", searchCursor{Spotify: map[string]int{"track": 10}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cursor.matchesSearch(params); got != tt.want {
+				t.Errorf("matchesSearch = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSearchParamsOffset(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{"first page with youtube", "query=song", false},
+		{"offset without youtube", "query=song&sources=spotify,library&offset=20", false},
+		{"offset with youtube", "query=song&offset=20", true},
+		{"offset with youtube only", "query=song&sources=youtube&offset=10", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := parseSearchParams(testSearchContext(tt.query))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseSearchParams(%q) succeeded, want error", tt.query)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSearchParams(%q) failed: %v", tt.query, err)
+			}
+			for typ, offset := range params.SpotifyOffsets {
+				if offset != params.Offset {
+					t.Errorf("Spotify offset for %s = %d, want %d", typ, offset, params.Offset)
+				}
+			}
+		})
+	}
+}
+
+func TestCombineSearchResultsMarksYouTubeTracks(t *testing.T) {
+	useTestLibrary(t)
+	useTestDataDir(t)
+	writeTestFile(t, absLibraryPath("Other/Record/01 - Third Song.mp3"), "audio")
+	recordDownloads([]IndexEntry{{Platform: "youtube", SourceID: "yt1", File: "Other/Record/01 - Third Song.mp3"}})
+
+	params, err := parseSearchParams(testSearchContext("query=song&sources=library,spotify,youtube&type=track"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := SearchResponseV2{Tracks: []SearchTrack{{ID: "sp1", Platform: "spotify", Name: "Nothing Local"}}}
+	youtube := []SearchTrack{{ID: "yt1", Platform: "youtube", Name: "Official clip"}}
+	var next searchCursor
+	combineSearchResults(params, &response, testLibraryTracks(), youtube, nil, &next)
+
+	var order []string
+	for _, track := range response.Tracks {
+		order = append(order, track.Platform+":"+track.ID)
+	}
+	if want := []string{"library:l1", "library:l2", "library:l3", "spotify:sp1", "youtube:yt1"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("tracks = %v, want %v", order, want)
+	}
+	if yt := response.Tracks[4]; !yt.InLibrary || yt.LibraryID != "l3" {
+		t.Errorf("YouTube track in_library = %v, library_id = %q; want true, l3", yt.InLibrary, yt.LibraryID)
+	}
+}
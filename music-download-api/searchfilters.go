@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// youtubeMusicCategory é o videoCategoryId de "Music" no YouTube.
+const youtubeMusicCategory = "10"
+
+var (
+	yearFilterPattern   = regexp.MustCompile(`^\d{4}(-\d{4})?$`)
+	marketFilterPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// SearchFilters são os filtros estruturados de /v2/search. Year, Genre, Artist e Album
+// viram filtros de campo do Spotify; duração e conteúdo explícito são aplicados aqui,
+// sobre a página já buscada nas duas plataformas (ver SearchPagination).
+type SearchFilters struct {
+	Year          string
+	Genre         string
+	Artist        string
+	Album         string
+	Market        string
+	MinDurationMs int
+	MaxDurationMs int
+	Explicit      *bool
+}
+
+// parseSearchFilters lê os filtros: year (2001 ou 1990-1999), genre, artist, album,
+// market (código ISO de país), min_duration e max_duration (em segundos) e explicit
+// (true traz só faixas explícitas; false, só as limpas).
+func parseSearchFilters(c *gin.Context) (SearchFilters, error) {
+	filters := SearchFilters{
+		Year:   strings.TrimSpace(c.Query("year")),
+		Genre:  strings.TrimSpace(c.Query("genre")),
+		Artist: strings.TrimSpace(c.Query("artist")),
+		Album:  strings.TrimSpace(c.Query("album")),
+		Market: strings.ToUpper(strings.TrimSpace(c.Query("market"))),
+	}
+
+	if filters.Year != "" && !yearFilterPattern.MatchString(filters.Year) {
+		return filters, fmt.Errorf("year must be YYYY or YYYY-YYYY")
+	}
+	if filters.Market != "" && !marketFilterPattern.MatchString(filters.Market) {
+		return filters, fmt.Errorf("market must be a two-letter country code")
+	}
+
+	for param, target := range map[string]*int{"min_duration": &filters.MinDurationMs, "max_duration": &filters.MaxDurationMs} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds < 0 {
+			return filters, fmt.Errorf("%s must be a non-negative number of seconds", param)
+		}
+		*target = seconds * 1000
+	}
+	if filters.MaxDurationMs > 0 && filters.MinDurationMs > filters.MaxDurationMs {
+		return filters, fmt.Errorf("min_duration must not exceed max_duration")
+	}
+
+	if raw := c.Query("explicit"); raw != "" {
+		explicit, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filters, fmt.Errorf("explicit must be true or false")
+		}
+		filters.Explicit = &explicit
+	}
+	return filters, nil
+}
+
+// spotifyFieldValue coloca entre aspas valores com espaços ("artist:\"Miles Davis\"").
+func spotifyFieldValue(value string) string {
+	value = strings.ReplaceAll(value, `"`, "")
+	if strings.ContainsAny(value, " \t") {
+		return `"` + value + `"`
+	}
+	return value
+}
+
+// spotifyQuery monta o parâmetro q do Spotify com os filtros de campo.
+func spotifyQuery(query string, filters SearchFilters) string {
+	parts := []string{query}
+	for _, field := range []struct{ name, value string }{
+		{"artist", filters.Artist},
+		{"album", filters.Album},
+		{"genre", filters.Genre},
+		{"year", filters.Year},
+	} {
+		if field.value != "" {
+			parts = append(parts, field.name+":"+spotifyFieldValue(field.value))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// youtubeDuration escolhe o videoDuration do YouTube (short < 4min, medium 4–20min,
+// long > 20min) quando a faixa pedida cabe em uma só categoria. O filtro exato é
+// aplicado depois por filterSearchTracks.
+func youtubeDuration(filters SearchFilters) string {
+	const fourMinutes, twentyMinutes = 4 * 60 * 1000, 20 * 60 * 1000
+	minMs, maxMs := filters.MinDurationMs, filters.MaxDurationMs
+	switch {
+	case maxMs > 0 && maxMs <= fourMinutes:
+		return "short"
+	case minMs >= fourMinutes && maxMs > 0 && maxMs <= twentyMinutes:
+		return "medium"
+	case minMs >= twentyMinutes:
+		return "long"
+	}
+	return "any"
+}
+
+// filterSearchTracks aplica os filtros de duração e de conteúdo explícito. Faixas sem o
+// dado filtrado (duração desconhecida, ou vídeos do YouTube no filtro explicit) são
+// descartadas.
+func filterSearchTracks(tracks []SearchTrack, filters SearchFilters) []SearchTrack {
+	if filters.MinDurationMs == 0 && filters.MaxDurationMs == 0 && filters.Explicit == nil {
+		return tracks
+	}
+
+	filtered := make([]SearchTrack, 0, len(tracks))
+	for _, track := range tracks {
+		if filters.MinDurationMs > 0 || filters.MaxDurationMs > 0 {
+			if track.DurationMs == 0 || track.DurationMs < filters.MinDurationMs {
+				continue
+			}
+			if filters.MaxDurationMs > 0 && track.DurationMs > filters.MaxDurationMs {
+				continue
+			}
+		}
+		if filters.Explicit != nil && (track.Explicit == nil || *track.Explicit != *filters.Explicit) {
+			continue
+		}
+		filtered = append(filtered, track)
+	}
+	return filtered
+}
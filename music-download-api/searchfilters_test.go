@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func testSearchContext(rawQuery string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/v2/search?"+rawQuery, nil)
+	return c
+}
+
+func TestParseSearchFilters(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name    string
+		query   string
+		want    SearchFilters
+		wantErr bool
+	}{
+		{name: "no filters", query: "", want: SearchFilters{}},
+		{
+			name:  "field filters and market",
+			query: "year=1990-1999&genre=rock&artist=+Nirvana+&album=Nevermind&market=br",
+			want:  SearchFilters{Year: "1990-1999", Genre: "rock", Artist: "Nirvana", Album: "Nevermind", Market: "BR"},
+		},
+		{
+			name:  "durations in seconds",
+			query: "min_duration=120&max_duration=300",
+			want:  SearchFilters{MinDurationMs: 120000, MaxDurationMs: 300000},
+		},
+		{name: "explicit true", query: "explicit=true", want: SearchFilters{Explicit: &yes}},
+		{name: "explicit false", query: "explicit=0", want: SearchFilters{Explicit: &no}},
+		{name: "invalid year", query: "year=90s", wantErr: true},
+		{name: "year range without end", query: "year=1990-", wantErr: true},
+		{name: "invalid market", query: "market=BRA", wantErr: true},
+		{name: "negative duration", query: "min_duration=-1", wantErr: true},
+		{name: "non-numeric duration", query: "max_duration=long", wantErr: true},
+		{name: "min above max", query: "min_duration=300&max_duration=120", wantErr: true},
+		{name: "invalid explicit", query: "explicit=maybe", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSearchFilters(testSearchContext(tt.query))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseSearchFilters(%q) succeeded, want error", tt.query)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSearchFilters(%q) failed: %v", tt.query, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSearchFilters(%q) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterSearchTracks(t *testing.T) {
+	yes, no := true, false
+	tracks := []SearchTrack{
+		{ID: "short-clean", DurationMs: 100000, Explicit: &no},
+		{ID: "long-explicit", DurationMs: 400000, Explicit: &yes},
+		{ID: "medium-clean", DurationMs: 200000, Explicit: &no},
+		// Vídeo do YouTube: sem a informação de conteúdo explícito
+		{ID: "video", DurationMs: 200000},
+		{ID: "unknown-duration", Explicit: &no},
+	}
+	ids := func(tracks []SearchTrack) []string {
+		result := []string{}
+		for _, track := range tracks {
+			result = append(result, track.ID)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name    string
+		filters SearchFilters
+		want    []string
+	}{
+		{"no filters", SearchFilters{}, []string{"short-clean", "long-explicit", "medium-clean", "video", "unknown-duration"}},
+		{"min duration", SearchFilters{MinDurationMs: 150000}, []string{"long-explicit", "medium-clean", "video"}},
+		{"duration range", SearchFilters{MinDurationMs: 150000, MaxDurationMs: 300000}, []string{"medium-clean", "video"}},
+		{"explicit only", SearchFilters{Explicit: &yes}, []string{"long-explicit"}},
+		{"clean only skips videos without explicit", SearchFilters{Explicit: &no}, []string{"short-clean", "medium-clean", "unknown-duration"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ids(filterSearchTracks(tracks, tt.filters)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterSearchTracks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYouTubeDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters SearchFilters
+		want    string
+	}{
+		{"no duration filter", SearchFilters{}, "any"},
+		{"up to three minutes", SearchFilters{MaxDurationMs: 180000}, "short"},
+		{"five to ten minutes", SearchFilters{MinDurationMs: 300000, MaxDurationMs: 600000}, "medium"},
+		{"over half an hour", SearchFilters{MinDurationMs: 1800000}, "long"},
+		{"range across categories", SearchFilters{MinDurationMs: 120000, MaxDurationMs: 600000}, "any"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := youtubeDuration(tt.filters); got != tt.want {
+				t.Errorf("youtubeDuration = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpotifyQuery(t *testing.T) {
+	got := spotifyQuery("smells like", SearchFilters{Artist: "Nirvana", Album: `In "Utero"`, Year: "1991"})
+	want := `smells like artist:Nirvana album:"In Utero" year:1991`
+	if got != want {
+		t.Errorf("spotifyQuery = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// searchSources são as origens aceitas no parâmetro sources de /v2/search.
+var searchSources = []string{"library", "spotify", "youtube"}
+
+// libraryMatchMinScore é a nota mínima para considerar que uma faixa remota já está na
+// biblioteca quando ela não consta no índice de downloads (arquivos importados ou
+// baixados por outro caminho).
+const libraryMatchMinScore = 0.9
+
+// libraryYearMatches verifica o filtro de ano (YYYY ou YYYY-YYYY) contra a tag de data.
+func libraryYearMatches(year, filter string) bool {
+	if filter == "" {
+		return true
+	}
+	if len(year) < 4 {
+		return false
+	}
+	from, to, ok := strings.Cut(filter, "-")
+	if !ok {
+		to = from
+	}
+	return year[:4] >= from && year[:4] <= to
+}
+
+// libraryTrackMatches aplica a consulta e os filtros de campo a uma faixa local.
+func libraryTrackMatches(track LibraryTrack, query string, filters SearchFilters) bool {
+	return containsQuery(query, track.Title, track.Artist, track.AlbumArtist, track.Album) &&
+		(filters.Artist == "" || containsQuery(filters.Artist, track.Artist, track.AlbumArtist)) &&
+		(filters.Album == "" || containsQuery(filters.Album, track.Album)) &&
+		(filters.Genre == "" || containsQuery(filters.Genre, track.Genre)) &&
+		libraryYearMatches(track.Year, filters.Year)
+}
+
+// normalizeLibraryTrack converte uma faixa local para o modelo normalizado.
+func normalizeLibraryTrack(track LibraryTrack) SearchTrack {
+	result := SearchTrack{
+		ID:         track.ID,
+		Platform:   "library",
+		Name:       track.Title,
+		Artists:    []SearchRef{},
+		DurationMs: int(track.DurationSec * 1000),
+		ISRC:       track.Tags["isrc"],
+		Images:     []SearchImage{},
+		URL:        "/library/tracks/" + track.ID,
+		InLibrary:  true,
+		LibraryID:  track.ID,
+	}
+	if track.Artist != "" {
+		result.Artists = append(result.Artists, SearchRef{Name: track.Artist})
+	}
+	if track.Album != "" {
+		albumID := libraryID(libraryAlbumKey(track))
+		result.Album = &SearchRef{ID: albumID, Name: track.Album, URL: "/library/albums?q=" + url.QueryEscape(track.Album)}
+	}
+	if result.ISRC == "" {
+		result.ISRC = track.Tags["tsrc"]
+	}
+	return result
+}
+
+// searchLibrary procura na biblioteca local as faixas, álbuns e artistas que casam com a
+// consulta e anota em next o offset seguinte de cada tipo com mais resultados.
+func searchLibrary(params searchParams, tracks []LibraryTrack, response *SearchResponseV2, next *searchCursor) {
+	if len(params.LibraryOffsets) == 0 {
+		return
+	}
+	advance := func(t string, offset, total int) {
+		if offset+params.Limit < total {
+			if next.Library == nil {
+				next.Library = make(map[string]int)
+			}
+			next.Library[t] = offset + params.Limit
+		}
+		if offset > 0 {
+			response.Pagination.HasPrevious = true
+		}
+	}
+
+	if offset, ok := params.LibraryOffsets["track"]; ok {
+		var matched []SearchTrack
+		for _, track := range tracks {
+			if libraryTrackMatches(track, params.Query, params.Filters) {
+				matched = append(matched, normalizeLibraryTrack(track))
+			}
+		}
+		matched = filterSearchTracks(matched, params.Filters)
+		start, end := pageBounds(len(matched), params.Limit, offset)
+		response.Tracks = append(response.Tracks, matched[start:end]...)
+		response.Pagination.Total.Tracks += len(matched)
+		advance("track", offset, len(matched))
+	}
+
+	if offset, ok := params.LibraryOffsets["album"]; ok {
+		var matched []SearchAlbum
+		for _, album := range libraryAlbums(tracks) {
+			if !containsQuery(params.Query, album.Name, album.Artist) ||
+				(params.Filters.Artist != "" && !containsQuery(params.Filters.Artist, album.Artist)) ||
+				(params.Filters.Genre != "" && !containsQuery(params.Filters.Genre, album.Genre)) ||
+				!libraryYearMatches(album.Year, params.Filters.Year) {
+				continue
+			}
+			matched = append(matched, SearchAlbum{
+				ID:          album.ID,
+				Platform:    "library",
+				Name:        album.Name,
+				Artists:     []SearchRef{{Name: album.Artist}},
+				ReleaseDate: album.Year,
+				TotalTracks: album.TrackCount,
+				Images:      []SearchImage{},
+				URL:         "/library/albums?q=" + url.QueryEscape(album.Name),
+				InLibrary:   true,
+				LibraryID:   album.ID,
+			})
+		}
+		start, end := pageBounds(len(matched), params.Limit, offset)
+		response.Albums = append(response.Albums, matched[start:end]...)
+		response.Pagination.Total.Albums += len(matched)
+		advance("album", offset, len(matched))
+	}
+
+	if offset, ok := params.LibraryOffsets["artist"]; ok {
+		var matched []SearchArtist
+		for _, artist := range libraryArtists(tracks) {
+			if artist.Name == "" || !containsQuery(params.Query, artist.Name) {
+				continue
+			}
+			matched = append(matched, SearchArtist{
+				ID:        libraryID(normalizeText(artist.Name)),
+				Platform:  "library",
+				Name:      artist.Name,
+				Images:    []SearchImage{},
+				URL:       "/library/artists?q=" + url.QueryEscape(artist.Name),
+				InLibrary: true,
+			})
+		}
+		start, end := pageBounds(len(matched), params.Limit, offset)
+		response.Artists = append(response.Artists, matched[start:end]...)
+		response.Pagination.Total.Artists += len(matched)
+		advance("artist", offset, len(matched))
+	}
+}
+
+// libraryTrackFor procura a faixa local correspondente a um resultado remoto: primeiro
+// pelo índice de downloads e depois por título, artista e duração.
+func libraryTrackFor(track SearchTrack, byTitle map[string][]LibraryTrack, byPath map[string]LibraryTrack) (LibraryTrack, bool) {
+	if entry, ok := lookupDownload(track.Platform, track.ID); ok {
+		if local, ok := byPath[entry.File]; ok {
+			return local, true
+		}
+	}
+
+	parsed := parseSearchTrack(track)
+	best, bestScore := LibraryTrack{}, 0.0
+	for _, local := range byTitle[normalizeText(parsed.Title)] {
+		meta := TrackMetadata{Title: local.Title, Artists: []string{local.Artist, local.AlbumArtist}, DurationMs: int(local.DurationSec * 1000)}
+		if score := scoreCandidate(parsed, float64(track.DurationMs)/1000, meta).Score; score > bestScore {
+			best, bestScore = local, score
+		}
+	}
+	return best, bestScore >= libraryMatchMinScore
+}
+
+// markInLibrary sinaliza os resultados remotos que já existem na biblioteca.
+func markInLibrary(response *SearchResponseV2, tracks []LibraryTrack) {
+	byTitle := make(map[string][]LibraryTrack)
+	byPath := make(map[string]LibraryTrack, len(tracks))
+	albums := make(map[string]string)
+	artists := make(map[string]bool)
+	for _, track := range tracks {
+		title := normalizeText(cleanTrackTitle(track.Title))
+		byTitle[title] = append(byTitle[title], track)
+		byPath[track.Path] = track
+		albums[libraryAlbumKey(track)] = libraryID(libraryAlbumKey(track))
+		artists[normalizeText(track.AlbumArtist)] = true
+		artists[normalizeText(track.Artist)] = true
+	}
+
+	for i := range response.Tracks {
+		track := &response.Tracks[i]
+		if track.Platform == "library" {
+			continue
+		}
+		if local, ok := libraryTrackFor(*track, byTitle, byPath); ok {
+			track.InLibrary = true
+			track.LibraryID = local.ID
+		}
+	}
+	for i := range response.Albums {
+		album := &response.Albums[i]
+		if album.Platform == "library" || len(album.Artists) == 0 {
+			continue
+		}
+		key := normalizeText(album.Artists[0].Name) + "|" + normalizeText(album.Name)
+		if id, ok := albums[key]; ok {
+			album.InLibrary = true
+			album.LibraryID = id
+		}
+	}
+	for i := range response.Artists {
+		artist := &response.Artists[i]
+		if artist.Platform != "library" && artists[normalizeText(artist.Name)] {
+			artist.InLibrary = true
+		}
+	}
+}
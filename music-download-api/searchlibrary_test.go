@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testLibraryTracks() []LibraryTrack {
+	return []LibraryTrack{
+		{ID: "l1", Path: "Band/Album/01 - First Song.mp3", Title: "First Song", Artist: "Band", AlbumArtist: "Band", Album: "Album", DurationSec: 200, Year: "2010"},
+		{ID: "l2", Path: "Band/Album/02 - Second Song.mp3", Title: "Second Song", Artist: "Band", AlbumArtist: "Band", Album: "Album", DurationSec: 180, Year: "2010"},
+		{ID: "l3", Path: "Other/Record/01 - Third Song.mp3", Title: "Third Song", Artist: "Other", AlbumArtist: "Other", Album: "Record", DurationSec: 240, Year: "2020"},
+	}
+}
+
+func TestSearchLibrary(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantTracks []string
+		wantAlbums []string
+		wantNext   map[string]int
+	}{
+		{"tracks matching the query", "query=song&sources=library&type=track", []string{"l1", "l2", "l3"}, nil, nil},
+		{"year filter", "query=song&sources=library&type=track&year=2015-2025", []string{"l3"}, nil, nil},
+		{"first page has a next offset", "query=song&sources=library&type=track&limit=2", []string{"l1", "l2"}, nil, map[string]int{"track": 2}},
+		{"albums by artist", "query=album&sources=library&type=album", nil, []string{"Album"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := parseSearchParams(testSearchContext(tt.query))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var response SearchResponseV2
+			var next searchCursor
+			searchLibrary(params, testLibraryTracks(), &response, &next)
+
+			var tracks, albums []string
+			for _, track := range response.Tracks {
+				if !track.InLibrary || track.Platform != "library" {
+					t.Errorf("track %s is not a library result: %+v", track.ID, track)
+				}
+				tracks = append(tracks, track.ID)
+			}
+			for _, album := range response.Albums {
+				albums = append(albums, album.Name)
+			}
+			if !reflect.DeepEqual(tracks, tt.wantTracks) {
+				t.Errorf("tracks = %v, want %v", tracks, tt.wantTracks)
+			}
+			if !reflect.DeepEqual(albums, tt.wantAlbums) {
+				t.Errorf("albums = %v, want %v", albums, tt.wantAlbums)
+			}
+			if !reflect.DeepEqual(next.Library, tt.wantNext) {
+				t.Errorf("next library offsets = %v, want %v", next.Library, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestMarkInLibrary(t *testing.T) {
+	useTestLibrary(t)
+	useTestDataDir(t)
+	// Baixado pelo YouTube com outro título: só o índice de downloads liga os dois
+	writeTestFile(t, absLibraryPath("Other/Record/01 - Third Song.mp3"), "audio")
+	recordDownloads([]IndexEntry{{Platform: "youtube", SourceID: "yt1", File: "Other/Record/01 - Third Song.mp3"}})
+
+	response := SearchResponseV2{
+		Tracks: []SearchTrack{
+			{ID: "sp1", Platform: "spotify", Name: "First Song - Remastered 2011", Artists: []SearchRef{{Name: "Band"}}, DurationMs: 201000},
+			{ID: "sp2", Platform: "spotify", Name: "Unknown Song", Artists: []SearchRef{{Name: "Band"}}, DurationMs: 200000},
+			{ID: "yt1", Platform: "youtube", Name: "Official clip", Artists: []SearchRef{{Name: "Some Channel"}}},
+		},
+		Albums:  []SearchAlbum{{ID: "a1", Platform: "spotify", Name: "Album", Artists: []SearchRef{{Name: "Band"}}}},
+		Artists: []SearchArtist{{ID: "ar1", Platform: "spotify", Name: "Other"}, {ID: "ar2", Platform: "spotify", Name: "Nobody"}},
+	}
+	markInLibrary(&response, testLibraryTracks())
+
+	want := map[string]string{"sp1": "l1", "sp2": "", "yt1": "l3"}
+	for _, track := range response.Tracks {
+		if track.InLibrary != (want[track.ID] != "") || track.LibraryID != want[track.ID] {
+			t.Errorf("track %s: in_library = %v, library_id = %q; want %q", track.ID, track.InLibrary, track.LibraryID, want[track.ID])
+		}
+	}
+	if album := response.Albums[0]; !album.InLibrary || album.LibraryID != libraryID("band|album") {
+		t.Errorf("album in_library = %v, library_id = %q", album.InLibrary, album.LibraryID)
+	}
+	if !response.Artists[0].InLibrary || response.Artists[1].InLibrary {
+		t.Errorf("artists in_library = %v, %v; want true, false", response.Artists[0].InLibrary, response.Artists[1].InLibrary)
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	spotifyUsersFile = "spotify-users.json"
+	// spotifyUserScopes dá acesso de leitura às playlists privadas/colaborativas e às
+	// músicas curtidas.
+	spotifyUserScopes = "playlist-read-private playlist-read-collaborative user-library-read"
+	// spotifyLoginTTL é o tempo que um login iniciado aguarda o callback.
+	spotifyLoginTTL = 10 * time.Minute
+)
+
+// spotifyRedirectURI é a URL de /auth/spotify/callback cadastrada no app do Spotify.
+var spotifyRedirectURI = getEnv("SPOTIFY_REDIRECT_URI", "")
+
+// SpotifyUser é uma conta do Spotify vinculada pelo fluxo Authorization Code + PKCE.
+// O access token é renovado com o refresh token quando expira. As músicas curtidas da
+// conta são endereçadas pela URL do player web, https://open.spotify.com/collection/tracks.
+type SpotifyUser struct {
+	ID           string    `json:"id"`
+	DisplayName  string    `json:"display_name,omitempty"`
+	Scope        string    `json:"scope"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	LinkedAt     time.Time `json:"linked_at"`
+}
+
+// SpotifyUserInfo é a visão pública de uma conta vinculada, sem os tokens.
+type SpotifyUserInfo struct {
+	ID          string    `json:"id"`
+	DisplayName string    `json:"display_name,omitempty"`
+	Scope       string    `json:"scope"`
+	LinkedAt    time.Time `json:"linked_at"`
+}
+
+// spotifyLogin é um login em andamento, chaveado pelo parâmetro state.
+type spotifyLogin struct {
+	verifier  string
+	createdAt time.Time
+}
+
+// spotifyUserTokenResponse é a resposta de /api/token nos grants authorization_code e
+// refresh_token.
+type spotifyUserTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+var (
+	spotifyUsers       = make(map[string]*SpotifyUser)
+	spotifyUsersMu     sync.Mutex
+	spotifyUsersLoaded bool
+	// spotifyRefreshMu serializa as renovações de token, que rodam fora de spotifyUsersMu,
+	// para que duas chamadas não gastem o mesmo refresh token
+	spotifyRefreshMu sync.Mutex
+
+	spotifyLogins   = make(map[string]spotifyLogin)
+	spotifyLoginsMu sync.Mutex
+)
+
+// loadSpotifyUsers carrega as contas vinculadas na primeira chamada. Deve ser chamada com
+// spotifyUsersMu travado.
+func loadSpotifyUsers() {
+	if spotifyUsersLoaded {
+		return
+	}
+	spotifyUsersLoaded = true
+	if err := loadJSONFile(spotifyUsersFile, &spotifyUsers); err != nil {
+		log.WithError(err).Error("Failed to load Spotify users")
+	}
+}
+
+// saveSpotifyUsers persiste as contas; deve ser chamada com spotifyUsersMu travado.
+func saveSpotifyUsers() {
+	if err := saveSecretJSONFile(spotifyUsersFile, spotifyUsers); err != nil {
+		log.WithError(err).Error("Failed to save Spotify users")
+	}
+}
+
+// spotifyUserInfo remove os tokens de uma conta vinculada.
+func spotifyUserInfo(user *SpotifyUser) SpotifyUserInfo {
+	return SpotifyUserInfo{ID: user.ID, DisplayName: user.DisplayName, Scope: user.Scope, LinkedAt: user.LinkedAt}
+}
+
+// spotifyUserLinked indica se há uma conta vinculada com o ID informado.
+func spotifyUserLinked(userID string) bool {
+	spotifyUsersMu.Lock()
+	defer spotifyUsersMu.Unlock()
+	loadSpotifyUsers()
+	_, ok := spotifyUsers[userID]
+	return ok
+}
+
+// randomURLString gera n bytes aleatórios em base64 sem padding, seguro para URLs.
+func randomURLString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// requestSpotifyUserToken chama /api/token com o formulário do grant. Com PKCE o app se
+// identifica só pelo client_id, sem o secret.
+func requestSpotifyUserToken(form url.Values) (*spotifyUserTokenResponse, error) {
+	form.Set("client_id", clientID)
+	req, err := http.NewRequest("POST", "https://accounts.spotify.com/api/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("spotify token endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var token spotifyUserTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// cachedSpotifyUserToken devolve o access token guardado da conta se ele ainda vale por
+// mais de um minuto; caso contrário, devolve só o refresh token para renová-lo.
+func cachedSpotifyUserToken(userID string) (token, refreshToken string, err error) {
+	spotifyUsersMu.Lock()
+	defer spotifyUsersMu.Unlock()
+	loadSpotifyUsers()
+
+	user, ok := spotifyUsers[userID]
+	if !ok {
+		return "", "", fmt.Errorf("spotify user %s is not linked", userID)
+	}
+	if time.Now().Add(time.Minute).Before(user.ExpiresAt) {
+		return user.AccessToken, "", nil
+	}
+	return "", user.RefreshToken, nil
+}
+
+// spotifyUserToken devolve um access token válido da conta, renovando-o com o refresh
+// token quando faltar menos de um minuto para expirar. A renovação roda fora de
+// spotifyUsersMu, para não travar as demais contas durante a chamada ao Spotify.
+func spotifyUserToken(userID string) (string, error) {
+	if token, _, err := cachedSpotifyUserToken(userID); err != nil || token != "" {
+		return token, err
+	}
+
+	spotifyRefreshMu.Lock()
+	defer spotifyRefreshMu.Unlock()
+	// Outra chamada pode ter renovado o token enquanto esta esperava
+	token, refreshToken, err := cachedSpotifyUserToken(userID)
+	if err != nil || token != "" {
+		return token, err
+	}
+
+	refreshed, err := requestSpotifyUserToken(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh token of Spotify user %s: %w", userID, err)
+	}
+
+	spotifyUsersMu.Lock()
+	defer spotifyUsersMu.Unlock()
+	// A conta pode ter sido desvinculada durante a renovação
+	user, ok := spotifyUsers[userID]
+	if !ok {
+		return "", fmt.Errorf("spotify user %s is not linked", userID)
+	}
+	user.AccessToken = refreshed.AccessToken
+	user.ExpiresAt = time.Now().Add(time.Duration(refreshed.ExpiresIn) * time.Second)
+	// O refresh token pode ser rotacionado; sem um novo, o anterior continua válido
+	if refreshed.RefreshToken != "" {
+		user.RefreshToken = refreshed.RefreshToken
+	}
+	if refreshed.Scope != "" {
+		user.Scope = refreshed.Scope
+	}
+	saveSpotifyUsers()
+	return user.AccessToken, nil
+}
+
+// spotifyUserGet faz o GET com o token da conta vinculada. Sem usuário, usa o token do
+// app (Client Credentials), como spotifyGet.
+func spotifyUserGet(userID, endpoint string, out interface{}) error {
+	if userID == "" {
+		return spotifyGet(endpoint, out)
+	}
+	token, err := spotifyUserToken(userID)
+	if err != nil {
+		return err
+	}
+	return spotifyGetWithToken(token, endpoint, out)
+}
+
+// spotifyAuthLogin inicia o fluxo Authorization Code + PKCE e redireciona para a tela de
+// consentimento do Spotify.
+func spotifyAuthLogin(c *gin.Context) {
+	if clientID == "" || spotifyRedirectURI == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "SPOTIFY_CLIENT_ID and SPOTIFY_REDIRECT_URI must be set"})
+		return
+	}
+
+	state, err := randomURLString(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	verifier, err := randomURLString(64)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	spotifyLoginsMu.Lock()
+	now := time.Now()
+	for k, login := range spotifyLogins {
+		if now.Sub(login.createdAt) > spotifyLoginTTL {
+			delete(spotifyLogins, k)
+		}
+	}
+	spotifyLogins[state] = spotifyLogin{verifier: verifier, createdAt: now}
+	spotifyLoginsMu.Unlock()
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {spotifyRedirectURI},
+		"scope":                 {spotifyUserScopes},
+		"state":                 {state},
+		"code_challenge_method": {"S256"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+	}
+	c.Redirect(http.StatusFound, "https://accounts.spotify.com/authorize?"+query.Encode())
+}
+
+// spotifyAuthCallback recebe o código de autorização, troca-o pelos tokens e vincula a
+// conta (identificada pelo ID de usuário do Spotify).
+func spotifyAuthCallback(c *gin.Context) {
+	if reason := c.Query("error"); reason != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Spotify authorization failed: " + reason})
+		return
+	}
+
+	state := c.Query("state")
+	spotifyLoginsMu.Lock()
+	login, ok := spotifyLogins[state]
+	delete(spotifyLogins, state)
+	spotifyLoginsMu.Unlock()
+	if !ok || time.Since(login.createdAt) > spotifyLoginTTL {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+		return
+	}
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing authorization code"})
+		return
+	}
+
+	token, err := requestSpotifyUserToken(url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {spotifyRedirectURI},
+		"code_verifier": {login.verifier},
+	})
+	if err != nil {
+		log.WithError(err).Error("Failed to exchange Spotify authorization code")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to exchange authorization code"})
+		return
+	}
+
+	var profile struct {
+		ID          string `json:"id"`
+		DisplayName string `json:"display_name"`
+	}
+	if err := spotifyGetWithToken(token.AccessToken, "https://api.spotify.com/v1/me", &profile); err != nil {
+		log.WithError(err).Error("Failed to get Spotify user profile")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to get Spotify user profile"})
+		return
+	}
+
+	user := &SpotifyUser{
+		ID:           profile.ID,
+		DisplayName:  profile.DisplayName,
+		Scope:        token.Scope,
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(token.ExpiresIn) * time.Second),
+		LinkedAt:     time.Now(),
+	}
+	spotifyUsersMu.Lock()
+	loadSpotifyUsers()
+	spotifyUsers[user.ID] = user
+	saveSpotifyUsers()
+	spotifyUsersMu.Unlock()
+
+	log.Infof("Linked Spotify user %s", user.ID)
+	c.JSON(http.StatusOK, spotifyUserInfo(user))
+}
+
+// listSpotifyUsers lista as contas vinculadas (sem os tokens).
+func listSpotifyUsers(c *gin.Context) {
+	spotifyUsersMu.Lock()
+	loadSpotifyUsers()
+	list := make([]SpotifyUserInfo, 0, len(spotifyUsers))
+	for _, user := range spotifyUsers {
+		list = append(list, spotifyUserInfo(user))
+	}
+	spotifyUsersMu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LinkedAt.Before(list[j].LinkedAt)
+	})
+	c.JSON(http.StatusOK, gin.H{"users": list})
+}
+
+// deleteSpotifyUser desvincula a conta e apaga seus tokens.
+func deleteSpotifyUser(c *gin.Context) {
+	spotifyUsersMu.Lock()
+	defer spotifyUsersMu.Unlock()
+	loadSpotifyUsers()
+
+	if _, ok := spotifyUsers[c.Param("id")]; !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Spotify user not found"})
+		return
+	}
+	delete(spotifyUsers, c.Param("id"))
+	saveSpotifyUsers()
+	c.Status(http.StatusNoContent)
+}
+
+// listSpotifyUserPlaylists lista as músicas curtidas e as playlists da conta (inclusive
+// privadas, colaborativas e mixes pessoais seguidos), no formato de /process-urls.
+func listSpotifyUserPlaylists(c *gin.Context) {
+	userID := c.Param("id")
+	if !spotifyUserLinked(userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Spotify user not found"})
+		return
+	}
+
+	liked, err := getSpotifyItemInfo("collection", "tracks", userID)
+	if err != nil {
+		log.WithError(err).Errorf("Failed to get liked songs of Spotify user %s", userID)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to get liked songs"})
+		return
+	}
+	playlists := []TrackInfo{*liked}
+
+	next := "https://api.spotify.com/v1/me/playlists?limit=50"
+	for next != "" {
+		var page struct {
+			Items []*struct {
+				ID     string `json:"id"`
+				Name   string `json:"name"`
+				Images []struct {
+					URL string `json:"url"`
+				} `json:"images"`
+				Tracks struct {
+					Total int `json:"total"`
+				} `json:"tracks"`
+			} `json:"items"`
+			Next string `json:"next"`
+		}
+		if err := spotifyUserGet(userID, next, &page); err != nil {
+			log.WithError(err).Errorf("Failed to list playlists of Spotify user %s", userID)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to list playlists"})
+			return
+		}
+		for _, item := range page.Items {
+			if item == nil {
+				continue
+			}
+			count := item.Tracks.Total
+			info := TrackInfo{
+				URL:        fmt.Sprintf("https://open.spotify.com/playlist/%s", item.ID),
+				Title:      item.Name,
+				Platform:   "spotify",
+				Type:       "playlist",
+				TrackCount: &count,
+			}
+			if len(item.Images) > 0 {
+				info.Thumbnail = item.Images[0].URL
+			}
+			playlists = append(playlists, info)
+		}
+		next = page.Next
+	}
+
+	c.JSON(http.StatusOK, gin.H{"playlists": playlists})
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// roundTripFunc permite responder às chamadas HTTP do teste sem rede.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// useTestTransport troca o transporte padrão, usado pelos clientes de token, por fn.
+func useTestTransport(t *testing.T, fn roundTripFunc) {
+	t.Helper()
+	previous := http.DefaultTransport
+	http.DefaultTransport = fn
+	t.Cleanup(func() { http.DefaultTransport = previous })
+}
+
+// jsonResponse monta uma resposta HTTP com corpo JSON.
+func jsonResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// useTestSpotifyUsers troca as contas vinculadas por users durante o teste.
+func useTestSpotifyUsers(t *testing.T, users map[string]*SpotifyUser) {
+	t.Helper()
+	useTestDataDir(t)
+	spotifyUsersMu.Lock()
+	previous, previousLoaded := spotifyUsers, spotifyUsersLoaded
+	spotifyUsers, spotifyUsersLoaded = users, true
+	spotifyUsersMu.Unlock()
+	t.Cleanup(func() {
+		spotifyUsersMu.Lock()
+		spotifyUsers, spotifyUsersLoaded = previous, previousLoaded
+		spotifyUsersMu.Unlock()
+	})
+}
+
+func TestSpotifyUserTokenCached(t *testing.T) {
+	useTestSpotifyUsers(t, map[string]*SpotifyUser{
+		"user1": {ID: "user1", AccessToken: "valid", RefreshToken: "refresh", ExpiresAt: time.Now().Add(time.Hour)},
+	})
+	useTestTransport(t, func(req *http.Request) (*http.Response, error) {
+		t.Errorf("unexpected request to %s", req.URL)
+		return jsonResponse(http.StatusInternalServerError, `{}`), nil
+	})
+
+	if token, err := spotifyUserToken("user1"); err != nil || token != "valid" {
+		t.Errorf("spotifyUserToken = %q, %v; want the cached token", token, err)
+	}
+	if _, err := spotifyUserToken("missing"); err == nil {
+		t.Error("spotifyUserToken succeeded for an unlinked user")
+	}
+}
+
+func TestSpotifyUserTokenRefresh(t *testing.T) {
+	useTestSpotifyUsers(t, map[string]*SpotifyUser{
+		"user1": {ID: "user1", AccessToken: "expired", RefreshToken: "refresh1", Scope: "old", ExpiresAt: time.Now().Add(30 * time.Second)},
+	})
+	var requests atomic.Int32
+	useTestTransport(t, func(req *http.Request) (*http.Response, error) {
+		requests.Add(1)
+		body, _ := io.ReadAll(req.Body)
+		form, _ := url.ParseQuery(string(body))
+		if req.URL.Host != "accounts.spotify.com" || form.Get("grant_type") != "refresh_token" || form.Get("refresh_token") != "refresh1" {
+			t.Errorf("unexpected token request %s %v", req.URL, form)
+		}
+		return jsonResponse(http.StatusOK, `{"access_token":"fresh","refresh_token":"refresh2","scope":"new","expires_in":3600}`), nil
+	})
+
+	// Chamadas simultâneas renovam uma vez só: a segunda encontra o token novo
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if token, err := spotifyUserToken("user1"); err != nil || token != "fresh" {
+				t.Errorf("spotifyUserToken = %q, %v; want the refreshed token", token, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := requests.Load(); n != 1 {
+		t.Errorf("token endpoint called %d times, want 1", n)
+	}
+
+	spotifyUsersMu.Lock()
+	user := *spotifyUsers["user1"]
+	spotifyUsersMu.Unlock()
+	if user.RefreshToken != "refresh2" || user.Scope != "new" || time.Until(user.ExpiresAt) < 59*time.Minute {
+		t.Errorf("user after refresh = %+v", user)
+	}
+	info, err := os.Stat(filepath.Join(dataDir, spotifyUsersFile))
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("saved users file = %v, %v; want mode 0600", info, err)
+	}
+}
+
+func TestSpotifyUserTokenRefreshFailure(t *testing.T) {
+	useTestSpotifyUsers(t, map[string]*SpotifyUser{
+		"user1": {ID: "user1", AccessToken: "expired", RefreshToken: "revoked"},
+	})
+	useTestTransport(t, func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusBadRequest, `{"error":"invalid_grant"}`), nil
+	})
+
+	if _, err := spotifyUserToken("user1"); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("spotifyUserToken error = %v, want the endpoint error", err)
+	}
+	spotifyUsersMu.Lock()
+	defer spotifyUsersMu.Unlock()
+	if user := spotifyUsers["user1"]; user.AccessToken != "expired" || user.RefreshToken != "revoked" {
+		t.Errorf("user changed after a failed refresh: %+v", user)
+	}
+}
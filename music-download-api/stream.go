@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamDefaultBitrate é usado quando a transcodificação é pedida sem bitrate.
+var streamDefaultBitrate = getEnv("STREAM_DEFAULT_BITRATE", "128k")
+
+// audioContentTypes mapeia as extensões de áudio da biblioteca para o Content-Type.
+var audioContentTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".mp4":  "audio/mp4",
+	".aac":  "audio/aac",
+	".flac": "audio/flac",
+	".ogg":  "audio/ogg",
+	".opus": "audio/ogg",
+	".wav":  "audio/wav",
+	".webm": "audio/webm",
+}
+
+// streamFormat descreve um formato de saída da transcodificação.
+type streamFormat struct {
+	codec       string
+	muxer       string
+	contentType string
+}
+
+var streamFormats = map[string]streamFormat{
+	"mp3":  {codec: "libmp3lame", muxer: "mp3", contentType: "audio/mpeg"},
+	"opus": {codec: "libopus", muxer: "ogg", contentType: "audio/ogg"},
+	"aac":  {codec: "aac", muxer: "adts", contentType: "audio/aac"},
+}
+
+var bitratePattern = regexp.MustCompile(`^[0-9]{2,3}k$`)
+
+// streamLibraryTrack serve o arquivo de uma faixa da biblioteca. Sem parâmetros o arquivo
+// original é enviado com suporte a Range; com format (mp3, opus, aac) e/ou bitrate
+// (ex.: 96k) o áudio é transcodificado pelo ffmpeg durante o envio, sem suporte a Range.
+func streamLibraryTrack(c *gin.Context) {
+	track, ok := findLibraryTrack(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Track not found"})
+		return
+	}
+	path := absLibraryPath(track.Path)
+
+	formatName := c.Query("format")
+	bitrate := c.Query("bitrate")
+	if formatName == "" && bitrate == "" {
+		file, err := os.Open(path)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Track file not found"})
+			return
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read track file"})
+			return
+		}
+
+		if contentType, ok := audioContentTypes[strings.ToLower(filepath.Ext(path))]; ok {
+			c.Header("Content-Type", contentType)
+		}
+		http.ServeContent(c.Writer, c.Request, filepath.Base(path), info.ModTime(), file)
+		return
+	}
+
+	if formatName == "" {
+		formatName = "mp3"
+	}
+	format, ok := streamFormats[formatName]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be mp3, opus or aac"})
+		return
+	}
+	if bitrate == "" {
+		bitrate = streamDefaultBitrate
+	}
+	if !bitratePattern.MatchString(bitrate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bitrate must look like 128k"})
+		return
+	}
+
+	// O contexto da requisição encerra o ffmpeg se o cliente desconectar
+	cmd := exec.CommandContext(
+		c.Request.Context(), "ffmpeg", "-v", "error", "-i", path,
+		"-map", "0:a:0", "-vn", "-c:a", format.codec, "-b:a", bitrate,
+		"-f", format.muxer, "pipe:1",
+	)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transcoder"})
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		log.WithError(err).Error("Failed to start ffmpeg")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transcoder"})
+		return
+	}
+
+	c.Header("Content-Type", format.contentType)
+	c.Header("Accept-Ranges", "none")
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, stdout); err != nil {
+		log.WithError(err).Debugf("Stream of %s interrupted", track.Path)
+	}
+	if err := cmd.Wait(); err != nil && c.Request.Context().Err() == nil {
+		log.WithError(err).Errorf("ffmpeg failed while streaming %s", track.Path)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStreamLibraryTrack(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestLibrary(t)
+	writeTestFile(t, absLibraryPath("Band/Album/01 - Song.mp3"), "0123456789")
+	useTestLibraryIndex(t, []LibraryTrack{
+		{ID: "l1", Path: "Band/Album/01 - Song.mp3"},
+		{ID: "l2", Path: "Band/Album/02 - Gone.mp3"},
+	}, time.Now())
+
+	router := gin.New()
+	router.GET("/library/tracks/:id/stream", streamLibraryTrack)
+	tests := []struct {
+		name     string
+		path     string
+		rangeHdr string
+		wantCode int
+		wantBody string
+	}{
+		{"whole file", "l1/stream", "", http.StatusOK, "0123456789"},
+		{"byte range", "l1/stream", "bytes=2-5", http.StatusPartialContent, "2345"},
+		{"unknown track", "missing/stream", "", http.StatusNotFound, ""},
+		{"indexed file is gone", "l2/stream", "", http.StatusNotFound, ""},
+		{"invalid format", "l1/stream?format=wma", "", http.StatusBadRequest, ""},
+		{"invalid bitrate", "l1/stream?bitrate=loud", "", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/library/tracks/"+tt.path, nil)
+			if tt.rangeHdr != "" {
+				req.Header.Set("Range", tt.rangeHdr)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantCode, w.Body.String())
+			}
+			if tt.wantBody == "" {
+				return
+			}
+			if got := w.Body.String(); got != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+			if got := w.Header().Get("Content-Type"); got != "audio/mpeg" {
+				t.Errorf("Content-Type = %q, want audio/mpeg", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Políticas para faixas que saíram da playlist assinada.
+const (
+	removedKeep   = "keep"
+	removedFlag   = "flag"
+	removedDelete = "delete"
+)
+
+const subscriptionsFile = "subscriptions.json"
+
+// RemovedTrack é uma faixa que saiu da playlist desde a sincronização anterior. InUse
+// indica que o arquivo foi mantido, apesar de on_removed=delete, porque outra assinatura
+// ou outra faixa de origem ainda o usa.
+type RemovedTrack struct {
+	SourceID  string    `json:"source_id"`
+	Title     string    `json:"title"`
+	File      string    `json:"file,omitempty"`
+	Deleted   bool      `json:"deleted,omitempty"`
+	InUse     bool      `json:"in_use,omitempty"`
+	RemovedAt time.Time `json:"removed_at"`
+}
+
+// Subscription é uma playlist acompanhada periodicamente: a cada execução do agendamento
+// as faixas novas são baixadas e as removidas tratadas conforme OnRemoved. Known guarda as
+// faixas vistas na playlist e Synced as que a assinatura já baixou com sucesso.
+type Subscription struct {
+	ID                string         `json:"id"`
+	URL               string         `json:"url"`
+	Name              string         `json:"name,omitempty"`
+	Schedule          string         `json:"schedule"`
+	OnRemoved         string         `json:"on_removed"`
+	PathTemplate      string         `json:"path_template,omitempty"`
+	OnConflict        string         `json:"on_conflict,omitempty"`
+	NavidromePlaylist bool           `json:"navidrome_playlist,omitempty"`
+	SpotifyUser       string         `json:"spotify_user,omitempty"`
+	YouTubeAccount    string         `json:"youtube_account,omitempty"`
+	Known             []string       `json:"known,omitempty"`
+	Synced            []string       `json:"synced"`
+	Removed           []RemovedTrack `json:"removed,omitempty"`
+	Syncing           bool           `json:"syncing"`
+	LastSyncAt        *time.Time     `json:"last_sync_at,omitempty"`
+	NextSyncAt        time.Time      `json:"next_sync_at"`
+	LastJobID         string         `json:"last_job_id,omitempty"`
+	LastError         string         `json:"last_error,omitempty"`
+	CreatedAt         time.Time      `json:"created_at"`
+}
+
+// SubscriptionRequest é o corpo aceito por POST /subscriptions. SpotifyUser e
+// YouTubeAccount são as contas vinculadas necessárias para assinar playlists privadas ou
+// as músicas/vídeos curtidos.
+type SubscriptionRequest struct {
+	URL               string `json:"url"`
+	Schedule          string `json:"schedule"`
+	OnRemoved         string `json:"on_removed,omitempty"`
+	PathTemplate      string `json:"path_template,omitempty"`
+	OnConflict        string `json:"on_conflict,omitempty"`
+	NavidromePlaylist bool   `json:"navidrome_playlist,omitempty"`
+	SpotifyUser       string `json:"spotify_user,omitempty"`
+	YouTubeAccount    string `json:"youtube_account,omitempty"`
+}
+
+var (
+	subscriptions   = make(map[string]*Subscription)
+	subscriptionsMu sync.Mutex
+)
+
+// loadSubscriptions carrega as assinaturas salvas em DATA_DIR.
+func loadSubscriptions() {
+	subscriptionsMu.Lock()
+	defer subscriptionsMu.Unlock()
+	if err := loadJSONFile(subscriptionsFile, &subscriptions); err != nil {
+		log.WithError(err).Error("Failed to load subscriptions")
+	}
+	// Uma sincronização interrompida por reinício não está mais em andamento
+	for _, sub := range subscriptions {
+		sub.Syncing = false
+	}
+}
+
+// saveSubscriptions persiste as assinaturas; deve ser chamada com subscriptionsMu travado.
+func saveSubscriptions() {
+	if err := saveJSONFile(subscriptionsFile, subscriptions); err != nil {
+		log.WithError(err).Error("Failed to save subscriptions")
+	}
+}
+
+// runDueSubscriptions inicia a sincronização das assinaturas cujo horário chegou.
+func runDueSubscriptions(now time.Time) {
+	subscriptionsMu.Lock()
+	var due []*Subscription
+	for _, sub := range subscriptions {
+		if !sub.Syncing && !now.Before(sub.NextSyncAt) {
+			sub.Syncing = true
+			due = append(due, sub)
+		}
+	}
+	subscriptionsMu.Unlock()
+
+	for _, sub := range due {
+		go syncSubscription(sub)
+	}
+}
+
+// syncSubscription expande a playlist, baixa as faixas que a assinatura ainda não
+// sincronizou, trata as removidas e regenera a playlist (M3U/Navidrome) com um job da
+// coleção. O chamador deve ter marcado sub.Syncing.
+func syncSubscription(sub *Subscription) {
+	subscriptionsMu.Lock()
+	urlStr := sub.URL
+	previous := make(map[string]bool, len(sub.Known))
+	for _, id := range sub.Known {
+		previous[id] = true
+	}
+	synced := make(map[string]bool, len(sub.Synced))
+	for _, id := range sub.Synced {
+		synced[id] = true
+	}
+	// Assinaturas gravadas antes de Synced existir ainda dependem do índice de downloads
+	legacy := sub.Synced == nil && len(sub.Known) > 0
+	opts, err := subscriptionOptions(sub)
+	syncPlaylist := sub.NavidromePlaylist
+	onRemoved := sub.OnRemoved
+	subscriptionsMu.Unlock()
+
+	job := createJob(urlStr)
+	updateJob(job, func(j *DownloadJob) {
+		j.Status = jobRunning
+		j.NavidromePlaylist = syncPlaylist
+	})
+	emit := logEmitter(urlStr)
+
+	var name string
+	var entries []PlaylistEntry
+	if err == nil {
+		name, entries, err = expandPlaylist(urlStr, opts.SpotifyUser, opts.YouTubeAccount)
+	}
+	if err != nil {
+		log.WithError(err).Errorf("Failed to sync subscription %s", sub.ID)
+		finishJob(job, err)
+		finishSubscriptionSync(sub, job, nil, nil, nil, err)
+		return
+	}
+	updateJob(job, func(j *DownloadJob) { j.Collection = &JobCollection{Name: name, Entries: entries} })
+
+	current := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		current[entry.SourceID] = true
+		if synced[entry.SourceID] {
+			continue
+		}
+		if _, ok := lookupDownload(entry.Platform, entry.SourceID); ok && legacy && previous[entry.SourceID] {
+			synced[entry.SourceID] = true
+			continue
+		}
+
+		emit(fmt.Sprintf("New track in subscription: %s", describeEntry(entry)))
+		child := runJob(entry.URL, opts)
+		childSnapshot := snapshotJob(child)
+		for _, track := range childSnapshot.Tracks {
+			addJobTrack(job, track)
+		}
+		// Faixas que falharam ficam fora de Synced e são tentadas de novo na próxima vez
+		if childSnapshot.Status == jobCompleted {
+			synced[entry.SourceID] = true
+		}
+	}
+
+	var removed []RemovedTrack
+	deleted := false
+	for id := range previous {
+		if current[id] {
+			continue
+		}
+		track := handleRemovedTrack(sub.ID, onRemoved, job.Platform, id)
+		if track.Deleted {
+			delete(synced, id)
+			deleted = true
+		}
+		removed = append(removed, track)
+	}
+	if deleted {
+		libraryChanged()
+	}
+
+	writeJobM3U(job)
+	if syncPlaylist {
+		scheduleLibraryScan(job)
+	}
+	finishJob(job, nil)
+
+	known := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		known = append(known, entry.SourceID)
+	}
+	syncedIDs := make([]string, 0, len(synced))
+	for id := range synced {
+		syncedIDs = append(syncedIDs, id)
+	}
+	sort.Strings(syncedIDs)
+	finishSubscriptionSync(sub, job, known, syncedIDs, removed, nil)
+}
+
+// handleRemovedTrack aplica a política OnRemoved a uma faixa que saiu da playlist da
+// assinatura subID. O arquivo só é apagado se nenhuma outra assinatura ou faixa de origem
+// o usa, para não quebrar as playlists delas.
+func handleRemovedTrack(subID, onRemoved, platform, sourceID string) RemovedTrack {
+	removed := RemovedTrack{SourceID: sourceID, RemovedAt: time.Now()}
+	indexed, ok := lookupDownload(platform, sourceID)
+	if ok {
+		removed.File = indexed.File
+		removed.Title = indexed.Title
+	}
+	if onRemoved != removedDelete || !ok {
+		return removed
+	}
+
+	if syncedByOtherSubscription(subID, sourceID) || fileIndexedByOthers(indexed.File, platform, sourceID) {
+		log.Infof("Keeping %s removed from subscription %s: still used elsewhere", indexed.File, subID)
+		removed.InUse = true
+		return removed
+	}
+	if err := deleteLibraryFile(indexed.File); err != nil {
+		log.WithError(err).Errorf("Failed to delete %s removed from subscription", indexed.File)
+		return removed
+	}
+	removed.Deleted = true
+	return removed
+}
+
+// syncedByOtherSubscription indica se outra assinatura já baixou a faixa de origem.
+func syncedByOtherSubscription(subID, sourceID string) bool {
+	subscriptionsMu.Lock()
+	defer subscriptionsMu.Unlock()
+	for id, sub := range subscriptions {
+		if id != subID && slices.Contains(sub.Synced, sourceID) {
+			return true
+		}
+	}
+	return false
+}
+
+// finishSubscriptionSync grava o resultado da sincronização e agenda a próxima.
+func finishSubscriptionSync(sub *Subscription, job *DownloadJob, known, synced []string, removed []RemovedTrack, err error) {
+	subscriptionsMu.Lock()
+	defer subscriptionsMu.Unlock()
+
+	now := time.Now()
+	sub.Syncing = false
+	sub.LastSyncAt = &now
+	sub.LastJobID = job.ID
+	sub.LastError = ""
+	if err != nil {
+		sub.LastError = err.Error()
+	} else {
+		sub.Known = known
+		sub.Synced = synced
+		if job.Collection != nil {
+			sub.Name = job.Collection.Name
+		}
+		if sub.OnRemoved != removedKeep {
+			sub.Removed = append(sub.Removed, removed...)
+		}
+	}
+	if schedule, err := parseSchedule(sub.Schedule); err == nil {
+		sub.NextSyncAt = schedule.Next(now)
+	}
+	saveSubscriptions()
+}
+
+// subscriptionOptions resolve as opções de download da assinatura.
+func subscriptionOptions(sub *Subscription) (downloadOptions, error) {
+	tpl, conflict, err := resolvePathOptions(sub.PathTemplate, sub.OnConflict)
+	if err != nil {
+		return downloadOptions{}, err
+	}
+	return downloadOptions{
+		PathTemplate:   tpl,
+		OnConflict:     conflict,
+		SpotifyUser:    sub.SpotifyUser,
+		YouTubeAccount: sub.YouTubeAccount,
+	}, nil
+}
+
+// createSubscription registra uma nova assinatura. A primeira sincronização ocorre no
+// próximo horário do agendamento (ou imediatamente com POST /subscriptions/:id/sync).
+func createSubscription(c *gin.Context) {
+	var request SubscriptionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.WithError(err).Error("Invalid request body")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if request.URL == "" || !isCollectionURL(request.URL) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A playlist URL is required"})
+		return
+	}
+
+	schedule, err := parseSchedule(request.Schedule)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if request.SpotifyUser != "" && !spotifyUserLinked(request.SpotifyUser) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Spotify user is not linked"})
+		return
+	}
+	if request.YouTubeAccount != "" && !youtubeAccountLinked(request.YouTubeAccount) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "YouTube account is not linked"})
+		return
+	}
+
+	switch request.OnRemoved {
+	case "":
+		request.OnRemoved = removedFlag
+	case removedKeep, removedFlag, removedDelete:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "on_removed must be keep, flag or delete"})
+		return
+	}
+
+	sub := &Subscription{
+		ID:                newJobID(),
+		URL:               request.URL,
+		Schedule:          request.Schedule,
+		OnRemoved:         request.OnRemoved,
+		PathTemplate:      request.PathTemplate,
+		OnConflict:        request.OnConflict,
+		NavidromePlaylist: request.NavidromePlaylist,
+		SpotifyUser:       request.SpotifyUser,
+		YouTubeAccount:    request.YouTubeAccount,
+		NextSyncAt:        schedule.Next(time.Now()),
+		CreatedAt:         time.Now(),
+	}
+	if _, err := subscriptionOptions(sub); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscriptionsMu.Lock()
+	subscriptions[sub.ID] = sub
+	saveSubscriptions()
+	copied := *sub
+	subscriptionsMu.Unlock()
+
+	c.JSON(http.StatusCreated, copied)
+}
+
+// listSubscriptions retorna todas as assinaturas.
+func listSubscriptions(c *gin.Context) {
+	subscriptionsMu.Lock()
+	list := make([]Subscription, 0, len(subscriptions))
+	for _, sub := range subscriptions {
+		list = append(list, *sub)
+	}
+	subscriptionsMu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	c.JSON(http.StatusOK, gin.H{"subscriptions": list})
+}
+
+// getSubscription retorna uma assinatura pelo ID.
+func getSubscription(c *gin.Context) {
+	subscriptionsMu.Lock()
+	sub, ok := subscriptions[c.Param("id")]
+	var copied Subscription
+	if ok {
+		copied = *sub
+	}
+	subscriptionsMu.Unlock()
+
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+	c.JSON(http.StatusOK, copied)
+}
+
+// deleteSubscription remove a assinatura (os arquivos já baixados são mantidos).
+func deleteSubscription(c *gin.Context) {
+	subscriptionsMu.Lock()
+	defer subscriptionsMu.Unlock()
+
+	if _, ok := subscriptions[c.Param("id")]; !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+	delete(subscriptions, c.Param("id"))
+	saveSubscriptions()
+	c.Status(http.StatusNoContent)
+}
+
+// syncSubscriptionNow dispara uma sincronização imediata em segundo plano.
+func syncSubscriptionNow(c *gin.Context) {
+	subscriptionsMu.Lock()
+	sub, ok := subscriptions[c.Param("id")]
+	if ok && sub.Syncing {
+		subscriptionsMu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "Subscription is already syncing"})
+		return
+	}
+	if ok {
+		sub.Syncing = true
+	}
+	subscriptionsMu.Unlock()
+
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+
+	go syncSubscription(sub)
+	c.JSON(http.StatusAccepted, gin.H{"status": "syncing"})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHandleRemovedTrack(t *testing.T) {
+	tests := []struct {
+		name        string
+		onRemoved   string
+		otherSynced bool
+		otherIndex  bool
+		wantDeleted bool
+		wantInUse   bool
+	}{
+		{"keep leaves the file", removedKeep, false, false, false, false},
+		{"delete removes file, lyrics and empty folders", removedDelete, false, false, true, false},
+		{"another subscription still has the track", removedDelete, true, false, false, true},
+		{"another source points to the file", removedDelete, false, true, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestLibrary(t)
+			useTestDataDir(t)
+			const file = "Artist/Album/01 - Song.mp3"
+			writeTestFile(t, absLibraryPath(file), "audio")
+			writeTestFile(t, absLibraryPath("Artist/Album/01 - Song.lrc"), "lyrics")
+			entries := []IndexEntry{{Platform: "spotify", SourceID: "track1", File: file, Title: "Song", DownloadedAt: time.Now()}}
+			if tt.otherIndex {
+				entries = append(entries, IndexEntry{Platform: "youtube", SourceID: "video1", File: file, DownloadedAt: time.Now()})
+			}
+			recordDownloads(entries)
+
+			synced := []string{}
+			if tt.otherSynced {
+				synced = []string{"track1"}
+			}
+			subscriptionsMu.Lock()
+			subscriptions["other"] = &Subscription{ID: "other", Synced: synced}
+			subscriptionsMu.Unlock()
+			t.Cleanup(func() {
+				subscriptionsMu.Lock()
+				delete(subscriptions, "other")
+				subscriptionsMu.Unlock()
+			})
+
+			removed := handleRemovedTrack("sub", tt.onRemoved, "spotify", "track1")
+			if removed.File != file || removed.Title != "Song" {
+				t.Errorf("removed = %+v, want file and title from the index", removed)
+			}
+			if removed.Deleted != tt.wantDeleted || removed.InUse != tt.wantInUse {
+				t.Errorf("removed = %+v, want deleted %v and in use %v", removed, tt.wantDeleted, tt.wantInUse)
+			}
+
+			_, err := os.Stat(absLibraryPath(file))
+			if exists := err == nil; exists == tt.wantDeleted {
+				t.Errorf("file exists = %v after handling removal", exists)
+			}
+			if tt.wantDeleted {
+				if _, err := os.Stat(absLibraryPath("Artist")); err == nil {
+					t.Error("empty album folders were left behind")
+				}
+				if _, ok := lookupDownload("spotify", "track1"); ok {
+					t.Error("deleted track is still in the download index")
+				}
+			}
+		})
+	}
+}
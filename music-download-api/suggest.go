@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	searchHistoryFile = "search-history.json"
+	// searchHistoryLimit é o número de consultas guardadas; as mais antigas saem primeiro.
+	searchHistoryLimit = 500
+	// suggestUpstreamTTL é o tempo que as sugestões externas ficam em cache por consulta.
+	suggestUpstreamTTL = 10 * time.Minute
+)
+
+// suggestUpstream é a fonte externa opcional de sugestões: "youtube" usa o autocompletar
+// público do YouTube (sem custo de cota da API); vazio desliga.
+var suggestUpstream = getEnv("SUGGEST_UPSTREAM", "")
+
+// SuggestEntity é um artista, álbum, faixa ou playlist sugerido enquanto o usuário digita.
+type SuggestEntity struct {
+	Type     string `json:"type"`
+	Platform string `json:"platform"`
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name"`
+	Subtitle string `json:"subtitle,omitempty"`
+	Image    string `json:"image,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+// SearchHistoryEntry é uma consulta feita em /v2/search com os principais resultados.
+type SearchHistoryEntry struct {
+	Query    string          `json:"query"`
+	Count    int             `json:"count"`
+	LastAt   time.Time       `json:"last_at"`
+	Entities []SuggestEntity `json:"entities,omitempty"`
+}
+
+// searchHistory é chaveado pela consulta normalizada.
+var (
+	searchHistory       = make(map[string]*SearchHistoryEntry)
+	searchHistoryMu     sync.Mutex
+	searchHistoryLoaded bool
+)
+
+// suggestCacheEntry guarda as sugestões externas de uma consulta.
+type suggestCacheEntry struct {
+	suggestions []string
+	expiresAt   time.Time
+}
+
+var (
+	suggestCache   = make(map[string]suggestCacheEntry)
+	suggestCacheMu sync.Mutex
+)
+
+// loadSearchHistory carrega o histórico na primeira chamada. Deve ser chamada com
+// searchHistoryMu travado.
+func loadSearchHistory() {
+	if searchHistoryLoaded {
+		return
+	}
+	searchHistoryLoaded = true
+	if err := loadJSONFile(searchHistoryFile, &searchHistory); err != nil {
+		log.WithError(err).Error("Failed to load search history")
+	}
+}
+
+// firstImage devolve a URL da primeira imagem, se houver.
+func firstImage(images []SearchImage) string {
+	if len(images) > 0 {
+		return images[0].URL
+	}
+	return ""
+}
+
+// refNames junta os nomes dos artistas de um resultado.
+func refNames(refs []SearchRef) string {
+	names := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		names = append(names, ref.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// searchEntities escolhe os primeiros resultados de cada tipo para guardar no histórico.
+func searchEntities(response SearchResponseV2) []SuggestEntity {
+	var entities []SuggestEntity
+	for i, artist := range response.Artists {
+		if i == 2 {
+			break
+		}
+		entities = append(entities, SuggestEntity{
+			Type: "artist", Platform: artist.Platform, ID: artist.ID, Name: artist.Name,
+			Image: firstImage(artist.Images), URL: artist.URL,
+		})
+	}
+	for i, track := range response.Tracks {
+		if i == 3 {
+			break
+		}
+		entities = append(entities, SuggestEntity{
+			Type: "track", Platform: track.Platform, ID: track.ID, Name: track.Name,
+			Subtitle: refNames(track.Artists), Image: firstImage(track.Images), URL: track.URL,
+		})
+	}
+	for i, album := range response.Albums {
+		if i == 2 {
+			break
+		}
+		entities = append(entities, SuggestEntity{
+			Type: "album", Platform: album.Platform, ID: album.ID, Name: album.Name,
+			Subtitle: refNames(album.Artists), Image: firstImage(album.Images), URL: album.URL,
+		})
+	}
+	if len(response.Playlists) > 0 {
+		playlist := response.Playlists[0]
+		entities = append(entities, SuggestEntity{
+			Type: "playlist", Platform: playlist.Platform, ID: playlist.ID, Name: playlist.Name,
+			Subtitle: playlist.Owner, Image: firstImage(playlist.Images), URL: playlist.URL,
+		})
+	}
+	return entities
+}
+
+// recordSearch guarda a consulta e seus principais resultados no histórico usado pelo
+// /suggest. Só a primeira página de cada busca é registrada.
+func recordSearch(response SearchResponseV2) {
+	key := normalizeText(response.Query)
+	if key == "" {
+		return
+	}
+
+	searchHistoryMu.Lock()
+	defer searchHistoryMu.Unlock()
+	loadSearchHistory()
+
+	entry, ok := searchHistory[key]
+	if !ok {
+		entry = &SearchHistoryEntry{}
+		searchHistory[key] = entry
+	}
+	entry.Query = response.Query
+	entry.Count++
+	entry.LastAt = time.Now()
+	if entities := searchEntities(response); len(entities) > 0 {
+		entry.Entities = entities
+	}
+
+	if len(searchHistory) > searchHistoryLimit {
+		oldest := ""
+		for k, e := range searchHistory {
+			if oldest == "" || e.LastAt.Before(searchHistory[oldest].LastAt) {
+				oldest = k
+			}
+		}
+		delete(searchHistory, oldest)
+	}
+
+	if err := saveJSONFile(searchHistoryFile, searchHistory); err != nil {
+		log.WithError(err).Error("Failed to save search history")
+	}
+}
+
+// matchesPrefix indica se o texto começa com q ou tem uma palavra que começa com q,
+// ignorando acentos e caixa. q já deve estar normalizado.
+func matchesPrefix(text, q string) bool {
+	text = normalizeText(text)
+	return strings.HasPrefix(text, q) || strings.Contains(text, " "+q)
+}
+
+// upstreamSuggestions busca sugestões na fonte externa configurada, com cache por
+// consulta. Falhas só são registradas no log: as sugestões externas são opcionais.
+func upstreamSuggestions(q string) []string {
+	if suggestUpstream != "youtube" {
+		return nil
+	}
+
+	key := normalizeText(q)
+	suggestCacheMu.Lock()
+	cached, ok := suggestCache[key]
+	suggestCacheMu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.suggestions
+	}
+
+	endpoint := "https://suggestqueries.google.com/complete/search?client=firefox&ds=yt&q=" + url.QueryEscape(q)
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		log.WithError(err).Warn("Failed to get upstream suggestions")
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Warnf("Upstream suggestions returned status %d", resp.StatusCode)
+		return nil
+	}
+
+	// Formato: ["consulta", ["sugestão 1", "sugestão 2", ...]]
+	var payload []json.RawMessage
+	var suggestions []string
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil || len(payload) < 2 {
+		log.WithError(err).Warn("Failed to decode upstream suggestions")
+		return nil
+	}
+	if err := json.Unmarshal(payload[1], &suggestions); err != nil {
+		log.WithError(err).Warn("Failed to decode upstream suggestions")
+		return nil
+	}
+
+	suggestCacheMu.Lock()
+	now := time.Now()
+	for k, e := range suggestCache {
+		if now.After(e.expiresAt) {
+			delete(suggestCache, k)
+		}
+	}
+	suggestCache[key] = suggestCacheEntry{suggestions: suggestions, expiresAt: now.Add(suggestUpstreamTTL)}
+	suggestCacheMu.Unlock()
+	return suggestions
+}
+
+// librarySuggestions procura artistas, álbuns e faixas da biblioteca cujo nome casa com
+// o início de q.
+func librarySuggestions(q string, limit int) []SuggestEntity {
+	tracks := cachedLibraryTracks()
+	var entities []SuggestEntity
+
+	for _, artist := range libraryArtists(tracks) {
+		if len(entities) < limit && matchesPrefix(artist.Name, q) {
+			entities = append(entities, SuggestEntity{
+				Type: "artist", Platform: "library", Name: artist.Name,
+				Subtitle: fmt.Sprintf("%d albums", artist.AlbumCount),
+			})
+		}
+	}
+	for _, album := range libraryAlbums(tracks) {
+		if len(entities) < limit && matchesPrefix(album.Name, q) {
+			entities = append(entities, SuggestEntity{
+				Type: "album", Platform: "library", ID: album.ID, Name: album.Name, Subtitle: album.Artist,
+			})
+		}
+	}
+	for _, track := range tracks {
+		if len(entities) < limit && matchesPrefix(track.Title, q) {
+			entities = append(entities, SuggestEntity{
+				Type: "track", Platform: "library", ID: track.ID, Name: track.Title, Subtitle: track.Artist,
+			})
+		}
+	}
+	return entities
+}
+
+// suggest devolve sugestões de consulta e entidades para o texto digitado (q), a partir
+// do histórico de buscas, da biblioteca local e, se configurado, do autocompletar do
+// YouTube. Não consome cota da API do YouTube; limit vai de 1 a 20 (padrão 8).
+func suggest(c *gin.Context) {
+	q := normalizeText(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing q"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "8"))
+	if err != nil || limit < 1 || limit > 20 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 20"})
+		return
+	}
+
+	// Histórico: as consultas mais frequentes e, no empate, as mais recentes primeiro
+	searchHistoryMu.Lock()
+	loadSearchHistory()
+	history := make([]SearchHistoryEntry, 0, len(searchHistory))
+	for _, entry := range searchHistory {
+		history = append(history, *entry)
+	}
+	searchHistoryMu.Unlock()
+	sort.Slice(history, func(i, j int) bool {
+		if history[i].Count != history[j].Count {
+			return history[i].Count > history[j].Count
+		}
+		return history[i].LastAt.After(history[j].LastAt)
+	})
+
+	suggestions := []string{}
+	seen := make(map[string]bool)
+	addSuggestion := func(s string) {
+		if key := normalizeText(s); len(suggestions) < limit && key != "" && !seen[key] {
+			seen[key] = true
+			suggestions = append(suggestions, s)
+		}
+	}
+	for _, entry := range history {
+		if matchesPrefix(entry.Query, q) {
+			addSuggestion(entry.Query)
+		}
+	}
+	for _, s := range upstreamSuggestions(c.Query("q")) {
+		addSuggestion(s)
+	}
+
+	// Entidades: primeiro o que já está na biblioteca, depois os resultados de buscas
+	// anteriores cujo nome casa com o texto digitado
+	entities := []SuggestEntity{}
+	seenEntities := make(map[string]bool)
+	addEntity := func(e SuggestEntity) {
+		key := e.Type + "|" + e.Platform + "|" + normalizeText(e.Name) + "|" + normalizeText(e.Subtitle)
+		if len(entities) < limit && !seenEntities[key] {
+			seenEntities[key] = true
+			entities = append(entities, e)
+		}
+	}
+	for _, e := range librarySuggestions(q, limit) {
+		addEntity(e)
+	}
+	for _, entry := range history {
+		for _, e := range entry.Entities {
+			if matchesPrefix(e.Name, q) || matchesPrefix(e.Subtitle, q) {
+				addEntity(e)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"query": c.Query("q"), "suggestions": suggestions, "entities": entities})
+}
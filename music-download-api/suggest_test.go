@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTestSearchHistory começa o teste com o histórico de buscas vazio.
+func useTestSearchHistory(t *testing.T) {
+	t.Helper()
+	useTestDataDir(t)
+	searchHistoryMu.Lock()
+	previous, previousLoaded := searchHistory, searchHistoryLoaded
+	searchHistory, searchHistoryLoaded = make(map[string]*SearchHistoryEntry), true
+	searchHistoryMu.Unlock()
+	t.Cleanup(func() {
+		searchHistoryMu.Lock()
+		searchHistory, searchHistoryLoaded = previous, previousLoaded
+		searchHistoryMu.Unlock()
+	})
+}
+
+func TestMatchesPrefix(t *testing.T) {
+	tests := []struct {
+		text string
+		q    string
+		want bool
+	}{
+		{"Nirvana", "nir", true},
+		{"Smells Like Teen Spirit", "teen", true},
+		{"Ótimo Disco", "otimo", true},
+		{"Nirvana", "vana", false},
+		{"", "nir", false},
+	}
+	for _, tt := range tests {
+		if got := matchesPrefix(tt.text, tt.q); got != tt.want {
+			t.Errorf("matchesPrefix(%q, %q) = %v, want %v", tt.text, tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestRecordSearch(t *testing.T) {
+	useTestSearchHistory(t)
+	response := SearchResponseV2{
+		Query:   "Nirvana",
+		Artists: []SearchArtist{{ID: "ar1", Platform: "spotify", Name: "Nirvana"}},
+		Tracks: []SearchTrack{
+			{ID: "t1", Platform: "spotify", Name: "Lithium", Artists: []SearchRef{{Name: "Nirvana"}}},
+		},
+	}
+	recordSearch(response)
+	recordSearch(SearchResponseV2{Query: "  nirvana "})
+
+	searchHistoryMu.Lock()
+	defer searchHistoryMu.Unlock()
+	entry := searchHistory["nirvana"]
+	if entry == nil || len(searchHistory) != 1 || entry.Count != 2 {
+		t.Fatalf("history = %v, want one entry searched twice", searchHistory)
+	}
+	// Uma busca sem resultados não apaga as entidades da anterior
+	want := []SuggestEntity{
+		{Type: "artist", Platform: "spotify", ID: "ar1", Name: "Nirvana"},
+		{Type: "track", Platform: "spotify", ID: "t1", Name: "Lithium", Subtitle: "Nirvana"},
+	}
+	if !reflect.DeepEqual(entry.Entities, want) {
+		t.Errorf("entities = %+v, want %+v", entry.Entities, want)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestSearchHistory(t)
+	useTestLibraryIndex(t, []LibraryTrack{
+		{ID: "l1", Path: "Nirvana/Nevermind/01.mp3", Title: "Smells Like Teen Spirit", Artist: "Nirvana", AlbumArtist: "Nirvana", Album: "Nevermind"},
+	}, time.Now())
+	recordSearch(SearchResponseV2{
+		Query:   "nirvana lithium",
+		Artists: []SearchArtist{{ID: "ar1", Platform: "spotify", Name: "Nirvana"}},
+	})
+
+	router := gin.New()
+	router.GET("/suggest", suggest)
+	tests := []struct {
+		name            string
+		query           string
+		wantCode        int
+		wantSuggestions []string
+		wantEntities    []string
+	}{
+		{"history and library", "q=nir", http.StatusOK, []string{"nirvana lithium"}, []string{"artist:library:Nirvana", "artist:spotify:Nirvana"}},
+		{"album prefix", "q=never", http.StatusOK, []string{}, []string{"album:library:Nevermind"}},
+		{"limit", "q=nir&limit=1", http.StatusOK, []string{"nirvana lithium"}, []string{"artist:library:Nirvana"}},
+		{"missing q", "q=", http.StatusBadRequest, nil, nil},
+		{"invalid limit", "q=nir&limit=50", http.StatusBadRequest, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/suggest?"+tt.query, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantCode, w.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var body struct {
+				Suggestions []string        `json:"suggestions"`
+				Entities    []SuggestEntity `json:"entities"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			entities := []string{}
+			for _, e := range body.Entities {
+				entities = append(entities, e.Type+":"+e.Platform+":"+e.Name)
+			}
+			if !reflect.DeepEqual(body.Suggestions, tt.wantSuggestions) {
+				t.Errorf("suggestions = %v, want %v", body.Suggestions, tt.wantSuggestions)
+			}
+			if !reflect.DeepEqual(entities, tt.wantEntities) {
+				t.Errorf("entities = %v, want %v", entities, tt.wantEntities)
+			}
+		})
+	}
+}
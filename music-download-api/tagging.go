@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// coverSize é o lado máximo (em pixels) da capa embutida nos arquivos.
+const coverSize = 600
+
+var taggingEnabled = getEnv("TAGGING_ENABLED", "true") == "true"
+
+// tagFormatKeys devolve as chaves de metadados do ffmpeg adequadas ao container
+// e se ele aceita capa embutida. AAC puro (ADTS) não tem onde guardar a capa.
+func tagFormatKeys(ext string) (isrcKey string, supportsCover bool) {
+	switch strings.ToLower(ext) {
+	case ".mp3":
+		return "TSRC", true
+	case ".m4a", ".mp4", ".flac":
+		return "ISRC", true
+	default:
+		return "ISRC", false
+	}
+}
+
+// metadataMuxerArgs devolve as opções do muxer para regravar as tags de path: ID3v2.3 em
+// MP3 e, em MP4, chaves livres como ISRC e REPLAYGAIN_*, que sem use_metadata_tags o
+// muxer descarta.
+func metadataMuxerArgs(path string) []string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3":
+		return []string{"-id3v2_version", "3"}
+	case ".m4a", ".mp4":
+		return []string{"-movflags", "use_metadata_tags"}
+	}
+	return nil
+}
+
+// downloadCover baixa e redimensiona a capa para um JPEG temporário.
+// Quem chama deve remover o arquivo retornado.
+func downloadCover(coverURL string) (string, error) {
+	tmp, err := os.CreateTemp("", "cover-*.jpg")
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+
+	cmd := exec.Command(
+		"ffmpeg", "-y", "-loglevel", "error",
+		"-i", coverURL,
+		"-vf", fmt.Sprintf("scale='min(%d,iw)':-2", coverSize),
+		"-frames:v", "1", tmp.Name(),
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to fetch cover: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return tmp.Name(), nil
+}
+
+// writeTags reescreve as tags de um arquivo de áudio com ffmpeg, sem recodificar o áudio.
+func writeTags(path string, meta *TrackMetadata) error {
+	ext := filepath.Ext(path)
+	isrcKey, supportsCover := tagFormatKeys(ext)
+
+	args := []string{"-y", "-loglevel", "error", "-i", path}
+
+	var coverPath string
+	if supportsCover && meta.CoverURL != "" {
+		cover, err := downloadCover(meta.CoverURL)
+		if err != nil {
+			log.WithError(err).Warnf("Skipping cover art for %s", path)
+		} else {
+			coverPath = cover
+			defer os.Remove(coverPath)
+			args = append(args, "-i", coverPath)
+		}
+	}
+
+	args = append(args, "-map", "0:a", "-map_metadata", "0", "-c:a", "copy")
+	if coverPath != "" {
+		args = append(args,
+			"-map", "1:v", "-c:v", "mjpeg", "-disposition:v:0", "attached_pic",
+			"-metadata:s:v", "title=Album cover", "-metadata:s:v", "comment=Cover (front)",
+		)
+	}
+	args = append(args, metadataMuxerArgs(path)...)
+
+	addTag := func(key, value string) {
+		if key != "" && value != "" {
+			args = append(args, "-metadata", key+"="+value)
+		}
+	}
+	addTag("title", meta.Title)
+	addTag("artist", strings.Join(meta.Artists, "; "))
+	addTag("album", meta.Album)
+	addTag("album_artist", meta.AlbumArtist)
+	addTag("track", numberWithTotal(meta.TrackNumber, meta.TrackTotal))
+	addTag("disc", numberWithTotal(meta.DiscNumber, meta.DiscTotal))
+	addTag("date", meta.Year)
+	addTag("genre", meta.Genre)
+	addTag(isrcKey, meta.ISRC)
+
+	return rewriteWithFFmpeg(path, args)
+}
+
+// rewriteWithFFmpeg roda o ffmpeg com args (que já devem incluir a entrada) gravando em
+// um arquivo temporário ao lado do original, que depois substitui o original.
+func rewriteWithFFmpeg(path string, args []string) error {
+	tmpPath := filepath.Join(filepath.Dir(path), ".tagging-"+filepath.Base(path))
+	args = append(args, tmpPath)
+
+	cmd := exec.Command("ffmpeg", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// numberWithTotal formata números de faixa/disco no formato "n/total".
+func numberWithTotal(n, total int) string {
+	if n <= 0 {
+		return ""
+	}
+	if total <= 0 {
+		return strconv.Itoa(n)
+	}
+	return fmt.Sprintf("%d/%d", n, total)
+}
+
+// tagJobFiles aplica os metadados de cada faixa do job aos arquivos gravados. Os
+// metadados do job inteiro só valem para um job de uma única faixa: em playlists e
+// álbuns eles descreveriam todos os arquivos como a mesma música.
+func tagJobFiles(job *DownloadJob, emit func(string)) {
+	snapshot := snapshotJob(job)
+	single := len(snapshot.Tracks) == 1 && !isCollectionURL(snapshot.URL)
+	for _, track := range downloadedTracks(snapshot) {
+		meta := track.Metadata
+		if meta == nil && single {
+			meta = snapshot.Metadata
+		}
+		if meta == nil {
+			continue
+		}
+		tagTrack(job, track, meta, emit)
+	}
+}
+
+// tagTrack grava as tags em um arquivo do job e registra o resultado.
+func tagTrack(job *DownloadJob, track *JobTrack, meta *TrackMetadata, emit func(string)) {
+	if !taggingEnabled {
+		return
+	}
+
+	if err := writeTags(absLibraryPath(track.File), meta); err != nil {
+		log.WithError(err).Errorf("Failed to tag %s", track.File)
+		emit(fmt.Sprintf("Failed to tag %s: %s", track.File, err.Error()))
+		updateJob(job, func(j *DownloadJob) {
+			j.Result.TagErrors = append(j.Result.TagErrors, fmt.Sprintf("%s: %s", track.File, err.Error()))
+		})
+		return
+	}
+
+	emit(fmt.Sprintf("Tagged %s", track.File))
+	updateJob(job, func(j *DownloadJob) {
+		j.Result.Tagged = append(j.Result.Tagged, track.File)
+	})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTagFormatKeys(t *testing.T) {
+	tests := []struct {
+		ext       string
+		isrcKey   string
+		withCover bool
+	}{
+		{".mp3", "TSRC", true},
+		{".M4A", "ISRC", true},
+		{".mp4", "ISRC", true},
+		{".flac", "ISRC", true},
+		{".aac", "ISRC", false},
+		{".opus", "ISRC", false},
+	}
+	for _, tt := range tests {
+		key, cover := tagFormatKeys(tt.ext)
+		if key != tt.isrcKey || cover != tt.withCover {
+			t.Errorf("tagFormatKeys(%q) = %q, %v; want %q, %v", tt.ext, key, cover, tt.isrcKey, tt.withCover)
+		}
+	}
+}
+
+func TestMetadataMuxerArgs(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"a/Song.mp3", []string{"-id3v2_version", "3"}},
+		{"a/Song.m4a", []string{"-movflags", "use_metadata_tags"}},
+		{"a/Song.MP4", []string{"-movflags", "use_metadata_tags"}},
+		{"a/Song.flac", nil},
+	}
+	for _, tt := range tests {
+		if got := metadataMuxerArgs(tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("metadataMuxerArgs(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,529 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	youtubeAccountsFile = "youtube-accounts.json"
+	// youtubeAccountScope dá acesso de leitura às playlists (inclusive privadas) e aos
+	// vídeos curtidos da conta.
+	youtubeAccountScope = "https://www.googleapis.com/auth/youtube.readonly"
+	// youtubeLikedPlaylist é o ID da playlist de vídeos curtidos da conta autenticada.
+	youtubeLikedPlaylist = "LL"
+	// youtubeCookiesLimit é o tamanho máximo aceito para um cookies.txt.
+	youtubeCookiesLimit = 1 << 20
+)
+
+// Estados possíveis de um login pelo fluxo de dispositivo.
+const (
+	youtubeLoginPending = "pending"
+	youtubeLoginLinked  = "linked"
+	youtubeLoginFailed  = "failed"
+)
+
+var (
+	// Cliente OAuth do Google do tipo "TVs e dispositivos de entrada limitada". O secret
+	// só é lido do ambiente e enviado ao Google; nunca é gravado em DATA_DIR.
+	youtubeOAuthClientID     = getEnv("YOUTUBE_OAUTH_CLIENT_ID", "")
+	youtubeOAuthClientSecret = getEnv("YOUTUBE_OAUTH_CLIENT_SECRET", "")
+	// ytDlpCookiesDir é onde o container do yt-dlp enxerga DATA_DIR/cookies.
+	ytDlpCookiesDir = getEnv("YTDLP_COOKIES_DIR", "/cookies")
+	// ytDlpCookiesFile é um cookies.txt (caminho no container do yt-dlp) usado em todos
+	// os downloads sem conta, para conteúdo com restrição de idade.
+	ytDlpCookiesFile = getEnv("YTDLP_COOKIES_FILE", "")
+)
+
+// YouTubeAccount é uma conta do Google vinculada pelo fluxo de dispositivo, identificada
+// pelo ID do canal. Os cookies (formato Netscape) são opcionais e só usados pelo yt-dlp.
+type YouTubeAccount struct {
+	ID           string    `json:"id"`
+	Title        string    `json:"title,omitempty"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	LinkedAt     time.Time `json:"linked_at"`
+}
+
+// YouTubeAccountInfo é a visão pública de uma conta vinculada, sem os tokens.
+type YouTubeAccountInfo struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title,omitempty"`
+	HasCookies bool      `json:"has_cookies"`
+	LinkedAt   time.Time `json:"linked_at"`
+}
+
+// YouTubeLogin é um login pelo fluxo de dispositivo: o usuário abre VerificationURL em
+// qualquer navegador e digita UserCode; o serviço consulta o Google até a autorização.
+type YouTubeLogin struct {
+	ID              string    `json:"id"`
+	Status          string    `json:"status"`
+	UserCode        string    `json:"user_code"`
+	VerificationURL string    `json:"verification_url"`
+	AccountID       string    `json:"account_id,omitempty"`
+	Error           string    `json:"error,omitempty"`
+	ExpiresAt       time.Time `json:"expires_at"`
+}
+
+// youtubeTokenResponse é a resposta do endpoint de token do Google; Error vem preenchido
+// enquanto o fluxo de dispositivo aguarda o usuário (authorization_pending, slow_down).
+type youtubeTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	Error        string `json:"error"`
+}
+
+var (
+	youtubeAccounts       = make(map[string]*YouTubeAccount)
+	youtubeAccountsMu     sync.Mutex
+	youtubeAccountsLoaded bool
+	// youtubeRefreshMu serializa as renovações de token, que rodam fora de
+	// youtubeAccountsMu, como em spotifyRefreshMu
+	youtubeRefreshMu sync.Mutex
+
+	youtubeLogins   = make(map[string]*YouTubeLogin)
+	youtubeLoginsMu sync.Mutex
+)
+
+// loadYouTubeAccounts carrega as contas vinculadas na primeira chamada. Deve ser chamada
+// com youtubeAccountsMu travado.
+func loadYouTubeAccounts() {
+	if youtubeAccountsLoaded {
+		return
+	}
+	youtubeAccountsLoaded = true
+	if err := loadJSONFile(youtubeAccountsFile, &youtubeAccounts); err != nil {
+		log.WithError(err).Error("Failed to load YouTube accounts")
+	}
+}
+
+// saveYouTubeAccounts persiste as contas (com os tokens, por isso em modo 0600); deve ser
+// chamada com youtubeAccountsMu travado.
+func saveYouTubeAccounts() {
+	if err := saveSecretJSONFile(youtubeAccountsFile, youtubeAccounts); err != nil {
+		log.WithError(err).Error("Failed to save YouTube accounts")
+	}
+}
+
+// youtubeAccountLinked indica se há uma conta vinculada com o ID informado.
+func youtubeAccountLinked(accountID string) bool {
+	youtubeAccountsMu.Lock()
+	defer youtubeAccountsMu.Unlock()
+	loadYouTubeAccounts()
+	_, ok := youtubeAccounts[accountID]
+	return ok
+}
+
+// youtubeCookiesPath é o cookies.txt da conta em DATA_DIR, visto por este serviço. O
+// arquivo é gravado em modo 0600 e lido pelo container do yt-dlp pelo volume /cookies,
+// o que só funciona porque os dois containers rodam como root (o mesmo uid); se algum
+// deles passar a rodar com outro usuário, os uids precisam continuar iguais.
+func youtubeCookiesPath(accountID string) string {
+	return filepath.Join(dataDir, "cookies", accountID+".txt")
+}
+
+// youtubeAccountInfo remove os tokens de uma conta vinculada.
+func youtubeAccountInfo(account *YouTubeAccount) YouTubeAccountInfo {
+	_, err := os.Stat(youtubeCookiesPath(account.ID))
+	return YouTubeAccountInfo{ID: account.ID, Title: account.Title, HasCookies: err == nil, LinkedAt: account.LinkedAt}
+}
+
+// ytDlpCookiesArgs devolve o --cookies do yt-dlp: o cookies.txt da conta, se enviado, ou
+// o arquivo global de YTDLP_COOKIES_FILE.
+func ytDlpCookiesArgs(accountID string) []string {
+	if accountID != "" {
+		if _, err := os.Stat(youtubeCookiesPath(accountID)); err == nil {
+			return []string{"--cookies", ytDlpCookiesDir + "/" + accountID + ".txt"}
+		}
+	}
+	if ytDlpCookiesFile != "" {
+		return []string{"--cookies", ytDlpCookiesFile}
+	}
+	return nil
+}
+
+// requestYouTubeToken chama o endpoint de token do Google com o formulário do grant.
+// Em caso de erro, a resposta é devolvida junto para que o chamador veja o código.
+func requestYouTubeToken(form url.Values) (*youtubeTokenResponse, error) {
+	form.Set("client_id", youtubeOAuthClientID)
+	form.Set("client_secret", youtubeOAuthClientSecret)
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.PostForm("https://oauth2.googleapis.com/token", form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var token youtubeTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &token, fmt.Errorf("google token endpoint returned status %d: %s", resp.StatusCode, token.Error)
+	}
+	return &token, nil
+}
+
+// cachedYouTubeAccountToken devolve o access token guardado da conta se ele ainda vale
+// por mais de um minuto; caso contrário, devolve só o refresh token para renová-lo.
+func cachedYouTubeAccountToken(accountID string) (token, refreshToken string, err error) {
+	youtubeAccountsMu.Lock()
+	defer youtubeAccountsMu.Unlock()
+	loadYouTubeAccounts()
+
+	account, ok := youtubeAccounts[accountID]
+	if !ok {
+		return "", "", fmt.Errorf("youtube account %s is not linked", accountID)
+	}
+	if time.Now().Add(time.Minute).Before(account.ExpiresAt) {
+		return account.AccessToken, "", nil
+	}
+	return "", account.RefreshToken, nil
+}
+
+// youtubeAccountToken devolve um access token válido da conta, renovando-o com o
+// refresh token quando faltar menos de um minuto para expirar. A renovação roda fora de
+// youtubeAccountsMu, para não travar as demais contas durante a chamada ao Google.
+func youtubeAccountToken(accountID string) (string, error) {
+	if token, _, err := cachedYouTubeAccountToken(accountID); err != nil || token != "" {
+		return token, err
+	}
+
+	youtubeRefreshMu.Lock()
+	defer youtubeRefreshMu.Unlock()
+	// Outra chamada pode ter renovado o token enquanto esta esperava
+	token, refreshToken, err := cachedYouTubeAccountToken(accountID)
+	if err != nil || token != "" {
+		return token, err
+	}
+
+	refreshed, err := requestYouTubeToken(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh token of YouTube account %s: %w", accountID, err)
+	}
+
+	youtubeAccountsMu.Lock()
+	defer youtubeAccountsMu.Unlock()
+	// A conta pode ter sido desvinculada durante a renovação
+	account, ok := youtubeAccounts[accountID]
+	if !ok {
+		return "", fmt.Errorf("youtube account %s is not linked", accountID)
+	}
+	account.AccessToken = refreshed.AccessToken
+	account.ExpiresAt = time.Now().Add(time.Duration(refreshed.ExpiresIn) * time.Second)
+	if refreshed.RefreshToken != "" {
+		account.RefreshToken = refreshed.RefreshToken
+	}
+	saveYouTubeAccounts()
+	return account.AccessToken, nil
+}
+
+// youtubeGetWithToken faz o GET na YouTube Data API com o token OAuth de uma conta.
+func youtubeGetWithToken(token, resource string, params url.Values, out interface{}) error {
+	req, err := http.NewRequest("GET", "https://www.googleapis.com/youtube/v3/"+resource+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("youtube returned status %d for %s", resp.StatusCode, resource)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// youtubeAccountGet faz o GET com o token da conta vinculada. Sem conta, usa a API key,
+// como youtubeGet.
+func youtubeAccountGet(accountID, resource string, params url.Values, out interface{}) error {
+	if accountID == "" {
+		return youtubeGet(resource, params, out)
+	}
+	token, err := youtubeAccountToken(accountID)
+	if err != nil {
+		return err
+	}
+	return youtubeGetWithToken(token, resource, params, out)
+}
+
+// updateYouTubeLogin aplica fn ao login sob o mutex do registro.
+func updateYouTubeLogin(login *YouTubeLogin, fn func(l *YouTubeLogin)) {
+	youtubeLoginsMu.Lock()
+	defer youtubeLoginsMu.Unlock()
+	fn(login)
+}
+
+// pollYouTubeLogin consulta o Google no intervalo pedido até o usuário autorizar, negar
+// ou o código expirar, e então vincula a conta.
+func pollYouTubeLogin(login *YouTubeLogin, deviceCode string, interval time.Duration) {
+	fail := func(err error) {
+		log.WithError(err).Errorf("YouTube login %s failed", login.ID)
+		updateYouTubeLogin(login, func(l *YouTubeLogin) {
+			l.Status = youtubeLoginFailed
+			l.Error = err.Error()
+		})
+	}
+
+	var token *youtubeTokenResponse
+	for {
+		time.Sleep(interval)
+		if time.Now().After(login.ExpiresAt) {
+			fail(fmt.Errorf("device code expired"))
+			return
+		}
+
+		response, err := requestYouTubeToken(url.Values{
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+			"device_code": {deviceCode},
+		})
+		if err == nil {
+			token = response
+			break
+		}
+		if response == nil {
+			fail(err)
+			return
+		}
+		switch response.Error {
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			fail(err)
+			return
+		}
+	}
+
+	var channels struct {
+		Items []struct {
+			ID      string `json:"id"`
+			Snippet struct {
+				Title string `json:"title"`
+			} `json:"snippet"`
+		} `json:"items"`
+	}
+	params := url.Values{"part": {"snippet"}, "mine": {"true"}}
+	if err := youtubeGetWithToken(token.AccessToken, "channels", params, &channels); err != nil {
+		fail(fmt.Errorf("failed to get YouTube channel: %w", err))
+		return
+	}
+	if len(channels.Items) == 0 {
+		fail(fmt.Errorf("google account has no YouTube channel"))
+		return
+	}
+
+	account := &YouTubeAccount{
+		ID:           channels.Items[0].ID,
+		Title:        channels.Items[0].Snippet.Title,
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(token.ExpiresIn) * time.Second),
+		LinkedAt:     time.Now(),
+	}
+	youtubeAccountsMu.Lock()
+	loadYouTubeAccounts()
+	youtubeAccounts[account.ID] = account
+	saveYouTubeAccounts()
+	youtubeAccountsMu.Unlock()
+
+	log.Infof("Linked YouTube account %s", account.ID)
+	updateYouTubeLogin(login, func(l *YouTubeLogin) {
+		l.Status = youtubeLoginLinked
+		l.AccountID = account.ID
+	})
+}
+
+// startYouTubeLogin inicia o fluxo de dispositivo do Google. A resposta traz o código e
+// a URL que o usuário deve abrir; o andamento é consultado em GET /auth/youtube/device/:id.
+func startYouTubeLogin(c *gin.Context) {
+	if youtubeOAuthClientID == "" || youtubeOAuthClientSecret == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "YOUTUBE_OAUTH_CLIENT_ID and YOUTUBE_OAUTH_CLIENT_SECRET must be set"})
+		return
+	}
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.PostForm("https://oauth2.googleapis.com/device/code", url.Values{
+		"client_id": {youtubeOAuthClientID},
+		"scope":     {youtubeAccountScope},
+	})
+	if err != nil {
+		log.WithError(err).Error("Failed to request Google device code")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to start login"})
+		return
+	}
+	defer resp.Body.Close()
+
+	var device struct {
+		DeviceCode      string `json:"device_code"`
+		UserCode        string `json:"user_code"`
+		VerificationURL string `json:"verification_url"`
+		ExpiresIn       int    `json:"expires_in"`
+		Interval        int    `json:"interval"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		log.Errorf("Google device code request returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to start login"})
+		return
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&device); err != nil {
+		log.WithError(err).Error("Failed to decode Google device code response")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to start login"})
+		return
+	}
+	if device.Interval <= 0 {
+		device.Interval = 5
+	}
+
+	login := &YouTubeLogin{
+		ID:              newJobID(),
+		Status:          youtubeLoginPending,
+		UserCode:        device.UserCode,
+		VerificationURL: device.VerificationURL,
+		ExpiresAt:       time.Now().Add(time.Duration(device.ExpiresIn) * time.Second),
+	}
+	youtubeLoginsMu.Lock()
+	for id, l := range youtubeLogins {
+		if l.Status != youtubeLoginPending && time.Now().After(l.ExpiresAt) {
+			delete(youtubeLogins, id)
+		}
+	}
+	youtubeLogins[login.ID] = login
+	copied := *login
+	youtubeLoginsMu.Unlock()
+
+	go pollYouTubeLogin(login, device.DeviceCode, time.Duration(device.Interval)*time.Second)
+	c.JSON(http.StatusAccepted, copied)
+}
+
+// getYouTubeLogin retorna o andamento de um login pelo fluxo de dispositivo.
+func getYouTubeLogin(c *gin.Context) {
+	youtubeLoginsMu.Lock()
+	login, ok := youtubeLogins[c.Param("id")]
+	var copied YouTubeLogin
+	if ok {
+		copied = *login
+	}
+	youtubeLoginsMu.Unlock()
+
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Login not found"})
+		return
+	}
+	c.JSON(http.StatusOK, copied)
+}
+
+// listYouTubeAccounts lista as contas vinculadas (sem os tokens).
+func listYouTubeAccounts(c *gin.Context) {
+	youtubeAccountsMu.Lock()
+	loadYouTubeAccounts()
+	list := make([]YouTubeAccountInfo, 0, len(youtubeAccounts))
+	for _, account := range youtubeAccounts {
+		list = append(list, youtubeAccountInfo(account))
+	}
+	youtubeAccountsMu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LinkedAt.Before(list[j].LinkedAt)
+	})
+	c.JSON(http.StatusOK, gin.H{"accounts": list})
+}
+
+// deleteYouTubeAccount desvincula a conta e apaga seus tokens e cookies.
+func deleteYouTubeAccount(c *gin.Context) {
+	youtubeAccountsMu.Lock()
+	defer youtubeAccountsMu.Unlock()
+	loadYouTubeAccounts()
+
+	id := c.Param("id")
+	if _, ok := youtubeAccounts[id]; !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "YouTube account not found"})
+		return
+	}
+	if err := os.Remove(youtubeCookiesPath(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.WithError(err).Errorf("Failed to delete cookies of YouTube account %s", id)
+	}
+	delete(youtubeAccounts, id)
+	saveYouTubeAccounts()
+	c.Status(http.StatusNoContent)
+}
+
+// putYouTubeCookies grava o cookies.txt (formato Netscape, exportado do navegador com a
+// conta logada) passado ao yt-dlp nos downloads da conta. O corpo é o próprio arquivo.
+func putYouTubeCookies(c *gin.Context) {
+	id := c.Param("id")
+	if !youtubeAccountLinked(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "YouTube account not found"})
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, youtubeCookiesLimit+1))
+	if err != nil || len(data) > youtubeCookiesLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cookies file"})
+		return
+	}
+	valid := false
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "#") && len(strings.Split(strings.TrimRight(line, "\r"), "\t")) == 7 {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cookies must be in Netscape cookies.txt format"})
+		return
+	}
+
+	path := youtubeCookiesPath(id)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		log.WithError(err).Error("Failed to create cookies directory")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save cookies"})
+		return
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		log.WithError(err).Errorf("Failed to save cookies of YouTube account %s", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save cookies"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// deleteYouTubeCookies apaga o cookies.txt da conta.
+func deleteYouTubeCookies(c *gin.Context) {
+	id := c.Param("id")
+	if !youtubeAccountLinked(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "YouTube account not found"})
+		return
+	}
+	if err := os.Remove(youtubeCookiesPath(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.WithError(err).Errorf("Failed to delete cookies of YouTube account %s", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete cookies"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTestYouTubeAccounts troca as contas vinculadas por accounts durante o teste.
+func useTestYouTubeAccounts(t *testing.T, accounts map[string]*YouTubeAccount) {
+	t.Helper()
+	useTestDataDir(t)
+	youtubeAccountsMu.Lock()
+	previous, previousLoaded := youtubeAccounts, youtubeAccountsLoaded
+	youtubeAccounts, youtubeAccountsLoaded = accounts, true
+	youtubeAccountsMu.Unlock()
+	t.Cleanup(func() {
+		youtubeAccountsMu.Lock()
+		youtubeAccounts, youtubeAccountsLoaded = previous, previousLoaded
+		youtubeAccountsMu.Unlock()
+	})
+}
+
+func TestYouTubeAccountToken(t *testing.T) {
+	tests := []struct {
+		name         string
+		account      YouTubeAccount
+		status       int
+		body         string
+		wantToken    string
+		wantErr      string
+		wantRefresh  string
+		wantRequests int
+	}{
+		{
+			name:      "valid token is reused",
+			account:   YouTubeAccount{AccessToken: "valid", RefreshToken: "refresh1", ExpiresAt: time.Now().Add(time.Hour)},
+			wantToken: "valid", wantRefresh: "refresh1",
+		},
+		{
+			name:    "expiring token is refreshed",
+			account: YouTubeAccount{AccessToken: "old", RefreshToken: "refresh1", ExpiresAt: time.Now().Add(30 * time.Second)},
+			status:  http.StatusOK, body: `{"access_token":"fresh","expires_in":3600}`,
+			wantToken: "fresh", wantRefresh: "refresh1", wantRequests: 1,
+		},
+		{
+			name:    "rotated refresh token is kept",
+			account: YouTubeAccount{AccessToken: "old", RefreshToken: "refresh1"},
+			status:  http.StatusOK, body: `{"access_token":"fresh","refresh_token":"refresh2","expires_in":3600}`,
+			wantToken: "fresh", wantRefresh: "refresh2", wantRequests: 1,
+		},
+		{
+			name:    "revoked refresh token fails",
+			account: YouTubeAccount{AccessToken: "old", RefreshToken: "refresh1"},
+			status:  http.StatusBadRequest, body: `{"error":"invalid_grant"}`,
+			wantErr: "invalid_grant", wantRefresh: "refresh1", wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := tt.account
+			account.ID = "channel1"
+			useTestYouTubeAccounts(t, map[string]*YouTubeAccount{"channel1": &account})
+			requests := 0
+			useTestTransport(t, func(req *http.Request) (*http.Response, error) {
+				requests++
+				body, _ := io.ReadAll(req.Body)
+				form, _ := url.ParseQuery(string(body))
+				if req.URL.Host != "oauth2.googleapis.com" || form.Get("grant_type") != "refresh_token" || form.Get("refresh_token") != "refresh1" {
+					t.Errorf("unexpected token request %s %v", req.URL, form)
+				}
+				return jsonResponse(tt.status, tt.body), nil
+			})
+
+			token, err := youtubeAccountToken("channel1")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("youtubeAccountToken error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil || token != tt.wantToken {
+				t.Errorf("youtubeAccountToken = %q, %v; want %q", token, err, tt.wantToken)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("token endpoint called %d times, want %d", requests, tt.wantRequests)
+			}
+			youtubeAccountsMu.Lock()
+			defer youtubeAccountsMu.Unlock()
+			if got := youtubeAccounts["channel1"].RefreshToken; got != tt.wantRefresh {
+				t.Errorf("refresh token = %q, want %q", got, tt.wantRefresh)
+			}
+		})
+	}
+
+	t.Run("unlinked account", func(t *testing.T) {
+		useTestYouTubeAccounts(t, map[string]*YouTubeAccount{})
+		if _, err := youtubeAccountToken("missing"); err == nil {
+			t.Error("youtubeAccountToken succeeded for an unlinked account")
+		}
+	})
+}
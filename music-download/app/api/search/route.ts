@@ -10,97 +10,86 @@ import {
   YouTubePlaylist,
   YouTubeTrack,
 } from "@/interfaces";
-import fs from "fs";
 import { NextRequest, NextResponse } from "next/server";
-import path from "path";
 
-type CombinedRawResponse = {
-  spotify: {
-    albums?: Array<{
-      id: string;
-      name: string;
-      album_type: string;
-      artists?: Array<{
-        name: string;
-        external_urls: { spotify: string };
-      }>;
-      release_date: string;
-      total_tracks: number;
-      images?: Array<{ url: string }>;
-      external_urls: { spotify: string };
-    }>;
-    artists?: Array<{
-      id: string;
-      name: string;
-      genres: string[];
-      popularity: number;
-      followers: { total: number };
-      images?: Array<{ url: string }>;
-      external_urls: { spotify: string };
-    }>;
-    tracks?: Array<{
-      id: string;
-      name: string;
-      album: {
-        name: string;
-        images?: Array<{ url: string }>;
-      };
-      artists?: Array<{ name: string }>;
-      duration_ms: number;
-      external_urls: { spotify: string };
-      popularity: number;
-      explicit: boolean;
-      preview_url: string | null;
-    }>;
-    playlists?: Array<{
-      id: string;
-      name: string;
-      description: string;
-      images?: Array<{ url: string }>;
-      owner: { display_name: string };
-      tracks: { total: number };
-      external_urls: { spotify: string };
-      public: boolean;
-    }>;
-    pagination?: {
-      has_next_album: boolean;
-      has_previous_album: boolean;
-      has_next_artist: boolean;
-      has_previous_artist: boolean;
-      has_next_track: boolean;
-      has_previous_track: boolean;
-      has_next_playlist: boolean;
-      has_previous_playlist: boolean;
-      total_albums: number;
-      total_artists: number;
-      total_tracks: number;
-      total_playlists: number;
-    };
-  };
-  youtube?: {
-    playlists?: Array<{
-      id: { playlistId: string };
-      snippet: {
-        title: string;
-        description: string;
-        channelTitle: string;
-        publishedAt: string;
-        thumbnails: { high: { url: string } };
-      };
-    }>;
-    videos?: Array<{
-      id: { videoId: string };
-      snippet: {
-        title: string;
-        channelTitle: string;
-        publishedAt: string;
-        thumbnails: { high: { url: string } };
-      };
-    }>;
-    nextPageToken?: string;
+// Esquema normalizado devolvido por GET /v2/search na API de download
+type SearchImage = { url: string; width?: number; height?: number };
+type SearchRef = { id?: string; name: string; url?: string };
+
+type SearchResponseV2 = {
+  version: number;
+  query: string;
+  tracks: Array<{
+    id: string;
+    platform: "spotify" | "youtube";
+    name: string;
+    artists: SearchRef[];
+    album?: SearchRef;
+    duration_ms?: number;
+    explicit?: boolean;
+    popularity?: number;
+    preview_url?: string;
+    isrc?: string;
+    published_at?: string;
+    images: SearchImage[];
+    url: string;
+  }>;
+  albums: Array<{
+    id: string;
+    platform: "spotify" | "youtube";
+    name: string;
+    album_type?: string;
+    artists: SearchRef[];
+    release_date?: string;
+    total_tracks?: number;
+    images: SearchImage[];
+    url: string;
+  }>;
+  artists: Array<{
+    id: string;
+    platform: "spotify" | "youtube";
+    name: string;
+    genres?: string[];
+    popularity?: number;
+    followers?: number;
+    images: SearchImage[];
+    url: string;
+  }>;
+  playlists: Array<{
+    id: string;
+    platform: "spotify" | "youtube";
+    name: string;
+    description?: string;
+    owner?: string;
+    track_count?: number;
+    public?: boolean;
+    published_at?: string;
+    images: SearchImage[];
+    url: string;
+  }>;
+  pagination: {
+    limit: number;
+    offset: number;
+    total: { tracks: number; albums: number; artists: number; playlists: number };
+    has_next: boolean;
+    has_previous: boolean;
+    next_cursor?: string;
   };
 };
 
+const SUPPORTED_VERSION = 2;
+
+const FILTER_PARAMS = [
+  "year",
+  "genre",
+  "artist",
+  "album",
+  "market",
+  "min_duration",
+  "max_duration",
+  "explicit",
+];
+
 export async function GET(request: NextRequest): Promise<NextResponse> {
   try {
     const url = new URL(request.url);
@@ -108,10 +97,8 @@ export async function GET(request: NextRequest): Promise<NextResponse> {
     const query = searchParams.get("query");
     const typeParam = searchParams.get("type") ?? "artist,track,playlist,album";
     const limitParam = searchParams.get("limit") ?? "10";
-    const offsetParam = searchParams.get("offset") ?? "0";
-    const youtubeType = searchParams.get("youtube_type") ?? "video,playlist";
-    const youtubeMaxResults = searchParams.get("maxResults") ?? "10";
-    const pageTokenParam = searchParams.get("pageToken") ?? "";
+    // Cursor opaco da API (pagination.next_cursor), com a posição das duas plataformas
+    const cursorParam = searchParams.get("pageToken") ?? "";
 
     if (!query) {
       return NextResponse.json(
@@ -124,16 +111,22 @@ export async function GET(request: NextRequest): Promise<NextResponse> {
       query,
       type: typeParam,
       limit: limitParam,
-      offset: offsetParam,
-      youtube_type: youtubeType,
-      maxResults: youtubeMaxResults,
     });
-    if (pageTokenParam) {
-      params.append("pageToken", pageTokenParam);
+    // A API não aceita offset com o YouTube entre as origens: as páginas seguintes vêm
+    // só pelo cursor
+    if (cursorParam) {
+      params.append("cursor", cursorParam);
+    }
+    // Filtros estruturados repassados como estão (ver searchfilters.go na API)
+    for (const filter of FILTER_PARAMS) {
+      const value = searchParams.get(filter);
+      if (value) {
+        params.append(filter, value);
+      }
     }
 
     const fetchResponse = await fetch(
-      `${process.env.API_DOWNLOAD_URL}/search?${params.toString()}`
+      `${process.env.API_DOWNLOAD_URL}/v2/search?${params.toString()}`
     );
     if (!fetchResponse.ok) {
       return NextResponse.json(
@@ -142,170 +135,113 @@ export async function GET(request: NextRequest): Promise<NextResponse> {
       );
     }
 
-    const combinedResponse: CombinedRawResponse = await fetchResponse.json();
-
-    // Opcional: gravar para debug local (em dev somente)
-    try {
-      const debugPath = path.join(process.cwd(), "combinedResponse.json");
-      fs.writeFileSync(debugPath, JSON.stringify(combinedResponse, null, 2));
-    } catch {
-      // ignorar falha de escrita em produção
+    const result: SearchResponseV2 = await fetchResponse.json();
+    if (result.version !== SUPPORTED_VERSION) {
+      console.warn(
+        `Unexpected search schema version ${result.version} (expected ${SUPPORTED_VERSION})`
+      );
     }
 
-    // === Montar objetos de album (Spotify) ===
-    const rawAlbums = combinedResponse.spotify.albums ?? [];
-    const albums: Album[] = rawAlbums.map((album) => ({
+    const images = (list: SearchImage[]) => list.map((img) => ({ url: img.url }));
+
+    const albums: Album[] = result.albums.map((album) => ({
       id: album.id,
       name: album.name,
-      album_type: album.album_type,
-      artists: (album.artists ?? []).map((artist) => ({
+      album_type: album.album_type ?? "",
+      artists: album.artists.map((artist) => ({
         name: artist.name,
-        external_urls: { spotify: artist.external_urls.spotify },
+        external_urls: { spotify: artist.url ?? "" },
       })),
-      release_date: album.release_date,
-      total_tracks: album.total_tracks,
-      images: (album.images ?? []).map((img) => ({ url: img.url })),
-      external_urls: { spotify: album.external_urls.spotify },
-      platform: "spotify",
+      release_date: album.release_date ?? "",
+      total_tracks: album.total_tracks ?? 0,
+      images: images(album.images),
+      external_urls: { spotify: album.url },
+      platform: album.platform,
     }));
 
-    // === Montar objetos de artist (Spotify) ===
-    const rawArtists = combinedResponse.spotify.artists ?? [];
-    const artists: Artist[] = rawArtists.map((artist) => ({
+    const artists: Artist[] = result.artists.map((artist) => ({
       id: artist.id,
       name: artist.name,
-      genres: artist.genres,
-      popularity: artist.popularity,
-      followers: { total: artist.followers.total },
-      images: (artist.images ?? []).map((img) => ({ url: img.url })),
-      external_urls: { spotify: artist.external_urls.spotify },
-      platform: "spotify",
+      genres: artist.genres ?? [],
+      popularity: artist.popularity ?? 0,
+      followers: { total: artist.followers ?? 0 },
+      images: images(artist.images),
+      external_urls: { spotify: artist.url },
+      platform: artist.platform,
     }));
 
-    // === Montar objetos de track (Spotify) ===
-    const rawSpotifyTracks = combinedResponse.spotify.tracks ?? [];
-    const spotifyTracks: SpotifyTrack[] = rawSpotifyTracks.map((track) => ({
-      id: track.id,
-      name: track.name,
-      album: {
-        name: track.album.name,
-        images: (track.album.images ?? []).map((img) => ({ url: img.url })),
-      },
-      artists: (track.artists ?? []).map((artist) => ({ name: artist.name })),
-      duration_ms: track.duration_ms,
-      external_urls: { spotify: track.external_urls.spotify },
-      popularity: track.popularity,
-      explicit: track.explicit,
-      preview_url: track.preview_url,
-      platform: "spotify",
-    }));
-
-    // === Montar objetos de track (YouTube) ===
-    let youtubeTracks: YouTubeTrack[] = [];
-    if (
-      combinedResponse.youtube &&
-      Array.isArray(combinedResponse.youtube.videos)
-    ) {
-      youtubeTracks = combinedResponse.youtube.videos.map((video) => ({
-        id: video.id.videoId,
-        name: video.snippet.title,
-        artists: [{ name: video.snippet.channelTitle }],
-        external_urls: {
-          youtube: `https://www.youtube.com/watch?v=${video.id.videoId}`,
+    const tracks: Track[] = result.tracks.map((track) => {
+      if (track.platform === "youtube") {
+        const youtubeTrack: YouTubeTrack = {
+          id: track.id,
+          name: track.name,
+          artists: track.artists.map((artist) => ({ name: artist.name })),
+          external_urls: { youtube: track.url },
+          platform: "youtube",
+          thumbnail: track.images[0]?.url ?? "",
+          channelTitle: track.artists[0]?.name ?? "",
+          publishedAt: track.published_at ?? "",
+        };
+        return youtubeTrack;
+      }
+      const spotifyTrack: SpotifyTrack = {
+        id: track.id,
+        name: track.name,
+        album: {
+          name: track.album?.name ?? "",
+          images: images(track.images),
         },
-        platform: "youtube",
-        thumbnail: video.snippet.thumbnails.high.url,
-        channelTitle: video.snippet.channelTitle,
-        publishedAt: video.snippet.publishedAt,
-      }));
-    }
-
-    const tracks: Track[] = [...spotifyTracks, ...youtubeTracks];
+        artists: track.artists.map((artist) => ({ name: artist.name })),
+        duration_ms: track.duration_ms ?? 0,
+        external_urls: { spotify: track.url },
+        popularity: track.popularity ?? 0,
+        explicit: track.explicit ?? false,
+        preview_url: track.preview_url ?? null,
+        platform: "spotify",
+      };
+      return spotifyTrack;
+    });
 
-    // === Montar objetos de playlist (Spotify) ===
-    const rawSpotifyPlaylists = combinedResponse.spotify.playlists ?? [];
-    const spotifyPlaylists: SpotifyPlaylist[] = rawSpotifyPlaylists.map(
-      (playlist) => ({
+    const playlists: Playlist[] = result.playlists.map((playlist) => {
+      if (playlist.platform === "youtube") {
+        const youtubePlaylist: YouTubePlaylist = {
+          id: playlist.id,
+          name: playlist.name,
+          description: playlist.description ?? "",
+          images: images(playlist.images),
+          owner: { name: playlist.owner ?? "" },
+          tracks: { total: playlist.track_count ?? 0 }, // YouTube Search não retorna total real
+          external_urls: { youtube: playlist.url },
+          platform: "youtube",
+          channelTitle: playlist.owner ?? "",
+          publishedAt: playlist.published_at ?? "",
+        };
+        return youtubePlaylist;
+      }
+      const spotifyPlaylist: SpotifyPlaylist = {
         id: playlist.id,
         name: playlist.name,
-        description: playlist.description,
-        images: (playlist.images ?? []).map((img) => ({ url: img.url })),
-        owner: { name: playlist.owner.display_name },
-        tracks: { total: playlist.tracks.total },
-        external_urls: { spotify: playlist.external_urls.spotify },
+        description: playlist.description ?? "",
+        images: images(playlist.images),
+        owner: { name: playlist.owner ?? "" },
+        tracks: { total: playlist.track_count ?? 0 },
+        external_urls: { spotify: playlist.url },
         platform: "spotify",
-        public: playlist.public,
-      })
-    );
-
-    // === Montar objetos de playlist (YouTube) ===
-    let youtubePlaylists: YouTubePlaylist[] = [];
-    if (Array.isArray(combinedResponse.youtube?.playlists)) {
-      youtubePlaylists = combinedResponse.youtube.playlists.map((playlist) => ({
-        id: playlist.id.playlistId,
-        name: playlist.snippet.title,
-        description: playlist.snippet.description,
-        images: [{ url: playlist.snippet.thumbnails.high.url }],
-        owner: { name: playlist.snippet.channelTitle },
-        tracks: { total: 0 }, // YouTube Search não retorna total real
-        external_urls: {
-          youtube: `https://www.youtube.com/playlist?list=${playlist.id.playlistId}`,
-        },
-        platform: "youtube",
-        channelTitle: playlist.snippet.channelTitle,
-        publishedAt: playlist.snippet.publishedAt,
-      }));
-    }
-
-    const playlists: Playlist[] = [...spotifyPlaylists, ...youtubePlaylists];
-
-    // === Paginação unificada ===
-    const spotifyPagination = combinedResponse.spotify.pagination ?? {
-      has_next_album: false,
-      has_previous_album: false,
-      has_next_artist: false,
-      has_previous_artist: false,
-      has_next_track: false,
-      has_previous_track: false,
-      has_next_playlist: false,
-      has_previous_playlist: false,
-      total_albums: 0,
-      total_artists: 0,
-      total_playlists: 0,
-      total_tracks: 0,
-    };
-
-    const youtubeTotalResults: number = Array.isArray(
-      combinedResponse.youtube?.videos
-    )
-      ? combinedResponse.youtube.videos.length
-      : 0;
-    const youtubePlaylistCount: number = Array.isArray(
-      combinedResponse.youtube?.playlists
-    )
-      ? combinedResponse.youtube.playlists.length
-      : 0;
+        public: playlist.public ?? false,
+      };
+      return spotifyPlaylist;
+    });
 
     const pagination: Pagination = {
-      total_albums: spotifyPagination.total_albums,
-      total_artists: spotifyPagination.total_artists,
-      total_tracks: spotifyPagination.total_tracks + youtubeTotalResults,
-      total_playlists: spotifyPagination.total_playlists + youtubePlaylistCount,
-      limit: Number(limitParam),
-      offset: Number(offsetParam),
-      has_next:
-        spotifyPagination.has_next_album ||
-        spotifyPagination.has_next_artist ||
-        spotifyPagination.has_next_playlist ||
-        spotifyPagination.has_next_track ||
-        (combinedResponse.youtube?.videos?.length ?? 0) > 0,
-      has_previous:
-        spotifyPagination.has_previous_album ||
-        spotifyPagination.has_previous_artist ||
-        spotifyPagination.has_previous_playlist ||
-        spotifyPagination.has_previous_track ||
-        Number(offsetParam) > 0,
-      next_token: combinedResponse.youtube?.nextPageToken,
+      total_albums: result.pagination.total.albums,
+      total_artists: result.pagination.total.artists,
+      total_tracks: result.pagination.total.tracks,
+      total_playlists: result.pagination.total.playlists,
+      limit: result.pagination.limit,
+      offset: result.pagination.offset,
+      has_next: result.pagination.has_next,
+      has_previous: result.pagination.has_previous,
+      next_token: result.pagination.next_cursor,
     };
 
     const searchResponse: SearchResponse = {
@@ -38,6 +38,9 @@ function useMusicDownload() {
   const [isSearching, setIsSearching] = useState<boolean>(false);
   const [searchLimit, setSearchLimit] = useState<number>(10);
   const [searchOffset, setSearchOffset] = useState<number>(0);
+  // Cursor de cada página já visitada (índice = página - 1); a primeira não tem cursor.
+  // A API só pagina o YouTube por cursor, então não dá para pular direto por offset.
+  const [pageCursors, setPageCursors] = useState<string[]>([""]);
   const [platformFilter, setPlatformFilter] = useState<
     "all" | "spotify" | "youtube"
   >("all");
@@ -63,7 +66,7 @@ function useMusicDownload() {
   const [processedArtists, setProcessedArtists] = useState<ProcessedItem[]>([]);
   const [isProcessing, setIsProcessing] = useState<boolean>(false);
 
-  const handleSearch = async (newOffset?: number) => {
+  const handleSearch = async (newOffset?: number, cursor?: string) => {
     if (!searchQuery.trim()) {
       toast.error("Consulta de busca obrigatória", {
         description: "Por favor, insira um termo de busca.",
@@ -72,6 +75,7 @@ function useMusicDownload() {
     }
 
     const offset = newOffset ?? searchOffset;
+    const pageCursor = offset === 0 ? "" : cursor ?? "";
     setIsSearching(true);
 
     try {
@@ -79,8 +83,10 @@ function useMusicDownload() {
         query: searchQuery,
         type: searchType,
         limit: searchLimit.toString(),
-        offset: offset.toString(),
       });
+      if (pageCursor) {
+        params.append("pageToken", pageCursor);
+      }
 
       const response = await fetch(`/api/search?${params}`);
       const data: SearchResponse = await response.json();
@@ -88,6 +94,15 @@ function useMusicDownload() {
       if (response.ok) {
         setSearchResults(data);
         setSearchOffset(offset);
+        const page = Math.floor(offset / searchLimit);
+        setPageCursors((cursors) => {
+          const next = offset === 0 ? [""] : cursors.slice(0, page + 1);
+          next[page] = pageCursor;
+          if (data.pagination.next_token) {
+            next[page + 1] = data.pagination.next_token;
+          }
+          return next;
+        });
 
         const totalResults =
           (data.artists?.length ?? 0) +
@@ -435,18 +450,19 @@ function useMusicDownload() {
   };
 
   const handlePageChange = (page: number) => {
-    const newOffset = (page - 1) * searchLimit;
-    handleSearch(newOffset);
+    const cursor = pageCursors[page - 1];
+    if (page > 1 && !cursor) {
+      return;
+    }
+    handleSearch((page - 1) * searchLimit, cursor);
   };
 
   const handleNextPage = () => {
-    const newOffset = searchOffset + searchLimit;
-    handleSearch(newOffset);
+    handlePageChange(getCurrentPage() + 1);
   };
 
   const handlePreviousPage = () => {
-    const newOffset = Math.max(0, searchOffset - searchLimit);
-    handleSearch(newOffset);
+    handlePageChange(Math.max(1, getCurrentPage() - 1));
   };
 
   return {